@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/database"
+	"github.com/kaelCoding/toyBE/internal/loyalty"
+	"github.com/kaelCoding/toyBE/internal/migrations"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/services"
+	"github.com/kaelCoding/toyBE/internal/utils"
+	"gorm.io/gorm"
+)
+
+const commandList = "migrate, create-admin, reset-password, run-demotions, seed-demo-data, rehash-passwords"
+
+// runCommand handles the maintenance subcommands; without one the binary
+// starts the HTTP server.
+func runCommand(db *gorm.DB, emails *services.Emailer, name string, args []string) error {
+	switch name {
+	case "migrate":
+		return runMigrate(db, args)
+	case "create-admin":
+		return runCreateAdmin(db, args)
+	case "reset-password":
+		return runResetPassword(db, args)
+	case "run-demotions":
+		return runDemotions(db, args)
+	case "seed-demo-data":
+		return runSeedDemoData(db, args)
+	case "rehash-passwords":
+		return runRehashPasswords(db, emails, args)
+	default:
+		return fmt.Errorf("unknown command %q (available: %s)", name, commandList)
+	}
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptIfEmpty asks for a value on stdin when it was not given as a flag.
+func promptIfEmpty(value *string, label string) error {
+	if *value != "" {
+		return nil
+	}
+	fmt.Printf("%s: ", label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("reading %s: %w", strings.ToLower(label), err)
+	}
+	*value = strings.TrimSpace(line)
+	if *value == "" {
+		return fmt.Errorf("%s is required", strings.ToLower(label))
+	}
+	return nil
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// Luôn có cả chữ và số để thỏa chính sách mật khẩu.
+	return "demo" + hex.EncodeToString(b) + "7", nil
+}
+
+func runCreateAdmin(db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := fs.String("username", "", "admin username (prompted when omitted)")
+	email := fs.String("email", "", "admin email (prompted when omitted)")
+	password := fs.String("password", "", "admin password (prompted when omitted)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := promptIfEmpty(username, "Username"); err != nil {
+		return err
+	}
+	if err := promptIfEmpty(email, "Email"); err != nil {
+		return err
+	}
+	if err := promptIfEmpty(password, "Password"); err != nil {
+		return err
+	}
+
+	if msg := auth.ValidateUsername(*username); msg != "" {
+		return fmt.Errorf("username %s", msg)
+	}
+	if _, err := mail.ParseAddress(*email); err != nil {
+		return fmt.Errorf("email is not valid: %w", err)
+	}
+	if msg := auth.ValidatePassword(*password, *username, *email); msg != "" {
+		return fmt.Errorf("password %s", msg)
+	}
+
+	admin, err := database.CreateAdmin(db, *username, *email, *password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created admin %s <%s> with ID %d.\n", admin.Username, admin.Email, admin.ID)
+	return nil
+}
+
+func runResetPassword(db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the account")
+	username := fs.String("username", "", "username of the account, if no email is given")
+	password := fs.String("password", "", "new password (prompted when omitted)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var user models.User
+	var err error
+	switch {
+	case *email != "":
+		err = db.Where("LOWER(email) = LOWER(?)", *email).First(&user).Error
+	case *username != "":
+		err = db.Where("LOWER(username) = LOWER(?)", *username).First(&user).Error
+	default:
+		return errors.New("either -email or -username is required")
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("user not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := promptIfEmpty(password, "New password"); err != nil {
+		return err
+	}
+	if msg := auth.ValidatePassword(*password, user.Username, user.Email); msg != "" {
+		return fmt.Errorf("password %s", msg)
+	}
+
+	hash, err := utils.GenerateFromPassword(*password, utils.DefaultHashParams)
+	if err != nil {
+		return err
+	}
+	if err := db.Model(&user).Update("password", hash).Error; err != nil {
+		return err
+	}
+	if err := auth.ClearFailedLogins(db, user.ID); err != nil {
+		return err
+	}
+	if err := auth.LogoutAll(db, user.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Password reset for %s <%s>; all sessions were signed out.\n", user.Username, user.Email)
+	return nil
+}
+
+func runDemotions(db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("run-demotions", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "list the demotions without applying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	demotions, err := loyalty.ApplyDemotions(db, *dryRun)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER ID\tUSERNAME\tEXPIRED AT\tVIP")
+	for _, d := range demotions {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d -> %d\n", d.UserID, d.Username, d.ExpiredAt.Format("2006-01-02"), d.FromLevel, d.ToLevel)
+	}
+	w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: %d user(s) would be demoted.\n", len(demotions))
+	} else {
+		fmt.Printf("Demoted %d user(s).\n", len(demotions))
+	}
+	return nil
+}
+
+func runSeedDemoData(db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("seed-demo-data", flag.ContinueOnError)
+	password := fs.String("customer-password", "", "password for the demo customer (random when omitted)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *password == "" {
+		generated, err := randomPassword()
+		if err != nil {
+			return err
+		}
+		*password = generated
+	} else if msg := auth.ValidatePassword(*password, "", ""); msg != "" {
+		return fmt.Errorf("password %s", msg)
+	}
+
+	if err := database.SeedDemoData(db, *password); err != nil {
+		return err
+	}
+	fmt.Printf("Demo data seeded. Customer login: %s / %s (only set if the account was new).\n", database.DemoCustomerEmail, *password)
+	return nil
+}
+
+// runRehashPasswords flags the hashes made with outdated HashParams. Argon2
+// is one-way, so they cannot be rewritten offline: the login handler rehashes
+// each password the next time its owner signs in, and -send-reset queues a
+// reset link for every affected user to hurry that along.
+func runRehashPasswords(db *gorm.DB, emails *services.Emailer, args []string) error {
+	fs := flag.NewFlagSet("rehash-passwords", flag.ContinueOnError)
+	sendReset := fs.Bool("send-reset", false, "email a password reset link to every affected user")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: rehash-passwords [-send-reset]")
+		fmt.Fprintln(fs.Output(), "Argon2 hashes cannot be rehashed offline without the plain passwords. This command counts")
+		fmt.Fprintln(fs.Output(), "the users whose hash uses outdated parameters; each is rehashed on that user's next login,")
+		fmt.Fprintln(fs.Output(), "or sooner with -send-reset, which emails a password reset link.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var outdated []models.User
+	var users []models.User
+	err := db.Select("id", "username", "email", "password").FindInBatches(&users, 500, func(tx *gorm.DB, batch int) error {
+		for _, u := range users {
+			if utils.NeedsRehash(u.Password, utils.DefaultHashParams) {
+				outdated = append(outdated, u)
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d user(s) have password hashes with outdated parameters; they are upgraded on next login.\n", len(outdated))
+	if !*sendReset {
+		return nil
+	}
+
+	for _, u := range outdated {
+		if err := emails.EnqueuePasswordReset(db, u.Email); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Queued %d password reset email(s); the server's job worker sends them.\n", len(outdated))
+	return nil
+}
+
+func runMigrate(db *gorm.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [-steps N] | status")
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := migrations.Up(db)
+		for _, m := range ran {
+			fmt.Printf("applied   %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(ran) == 0 {
+			fmt.Println("Schema is up to date.")
+		}
+		return nil
+
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return fmt.Errorf("-steps must be at least 1")
+		}
+		reverted, err := migrations.Down(db, *steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted  %d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrations.Statuses(db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate action %q (expected up, down or status)", args[0])
+	}
+}
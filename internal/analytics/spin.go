@@ -0,0 +1,184 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"gorm.io/gorm"
+)
+
+// Location is the shop's calendar: report days run from midnight to midnight
+// Vietnam time, not UTC. Vietnam has no DST, so a fixed +07:00 offset under
+// the same name is a faithful stand-in when the tz database is missing.
+var Location = loadLocation("Asia/Ho_Chi_Minh", 7*60*60)
+
+func loadLocation(name string, offset int) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	return time.FixedZone(name, offset)
+}
+
+// Range is a half-open [From, To) window of spin dates.
+type Range struct {
+	From       time.Time
+	To         time.Time
+	CampaignID *uint
+}
+
+func (r Range) scope(db *gorm.DB) *gorm.DB {
+	db = db.Where("spin_logs.spin_date >= ? AND spin_logs.spin_date < ?", r.From, r.To)
+	if r.CampaignID != nil {
+		db = db.Where("spin_logs.campaign_id = ?", *r.CampaignID)
+	}
+	return db
+}
+
+type DailySpins struct {
+	Date  string `json:"date"`
+	Spins int64  `json:"spins"`
+}
+
+// SpinsPerDay counts spins for each day in the range, including empty days.
+func SpinsPerDay(db *gorm.DB, r Range) ([]DailySpins, error) {
+	var rows []struct {
+		Day   time.Time
+		Spins int64
+	}
+	err := r.scope(db.Model(&models.SpinLog{})).
+		Select("DATE(spin_logs.spin_date AT TIME ZONE ?) AS day, COUNT(*) AS spins", Location.String()).
+		Group("day").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Day.Format("2006-01-02")] = row.Spins
+	}
+
+	var days []DailySpins
+	for day := r.From; day.Before(r.To); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		days = append(days, DailySpins{Date: key, Spins: counts[key]})
+	}
+	return days, nil
+}
+
+type RewardReport struct {
+	RewardID              uint        `json:"rewardId"`
+	Name                  string      `json:"name"`
+	CampaignID            *uint       `json:"campaignId"`
+	ConfiguredProbability float64     `json:"configuredProbability"`
+	Spins                 int64       `json:"spins"`
+	ObservedShare         float64     `json:"observedShare"`
+	RemainingQuantity     int         `json:"remainingQuantity"`
+	UnitCost              money.Money `json:"unitCost"`
+	EstimatedCost         money.Money `json:"estimatedCost"`
+	RedeemedDiscount      money.Money `json:"redeemedDiscount"`
+}
+
+func poolKey(campaignID *uint) uint {
+	if campaignID == nil {
+		return 0
+	}
+	return *campaignID
+}
+
+// unitCost is what one payout of the reward costs the shop: the configured
+// cost when set, otherwise the face value of a fixed-amount voucher.
+func unitCost(reward models.Reward) money.Money {
+	if !reward.Cost.IsZero() {
+		return money.FromVND(reward.Cost.Amount)
+	}
+	if reward.VoucherType == models.VoucherTypeFixed {
+		return money.FromVND(reward.AmountOff.Amount)
+	}
+	return money.FromVND(0)
+}
+
+// RewardDistribution compares how often each reward was won in the range with
+// its configured share of its pool, alongside stock left and payout cost.
+func RewardDistribution(db *gorm.DB, r Range) ([]RewardReport, error) {
+	var rewards []models.Reward
+	query := db.Unscoped().Order("campaign_id asc, id asc")
+	if r.CampaignID != nil {
+		query = query.Where("campaign_id = ?", *r.CampaignID)
+	}
+	if err := query.Find(&rewards).Error; err != nil {
+		return nil, err
+	}
+
+	var spinRows []struct {
+		RewardID uint
+		Spins    int64
+	}
+	err := r.scope(db.Model(&models.SpinLog{})).
+		Select("spin_logs.reward_id, COUNT(*) AS spins").
+		Group("spin_logs.reward_id").
+		Scan(&spinRows).Error
+	if err != nil {
+		return nil, err
+	}
+	spins := make(map[uint]int64, len(spinRows))
+	for _, row := range spinRows {
+		spins[row.RewardID] = row.Spins
+	}
+
+	var redeemedRows []struct {
+		RewardID uint
+		Discount int64
+	}
+	err = db.Table("user_vouchers").
+		Select("user_vouchers.reward_id, COALESCE(SUM(orders.voucher_discount_minor), 0) AS discount").
+		Joins("JOIN orders ON orders.id = user_vouchers.redeemed_order_id").
+		Where("user_vouchers.status = ?", models.VoucherStatusRedeemed).
+		Where("user_vouchers.redeemed_at >= ? AND user_vouchers.redeemed_at < ?", r.From, r.To).
+		Group("user_vouchers.reward_id").
+		Scan(&redeemedRows).Error
+	if err != nil {
+		return nil, err
+	}
+	redeemed := make(map[uint]int64, len(redeemedRows))
+	for _, row := range redeemedRows {
+		redeemed[row.RewardID] = row.Discount
+	}
+
+	weights := make(map[uint]float64)
+	poolSpins := make(map[uint]int64)
+	for _, reward := range rewards {
+		if !reward.DeletedAt.Valid {
+			weights[poolKey(reward.CampaignID)] += reward.Probability
+		}
+		poolSpins[poolKey(reward.CampaignID)] += spins[reward.ID]
+	}
+
+	var reports []RewardReport
+	for _, reward := range rewards {
+		if reward.DeletedAt.Valid && spins[reward.ID] == 0 {
+			continue
+		}
+		pool := poolKey(reward.CampaignID)
+		cost := unitCost(reward)
+		report := RewardReport{
+			RewardID:          reward.ID,
+			Name:              reward.Name,
+			CampaignID:        reward.CampaignID,
+			Spins:             spins[reward.ID],
+			RemainingQuantity: reward.Quantity,
+			UnitCost:          cost,
+			EstimatedCost:     cost.Mul(spins[reward.ID]),
+			RedeemedDiscount:  money.FromVND(redeemed[reward.ID]),
+		}
+		if weights[pool] > 0 && !reward.DeletedAt.Valid {
+			report.ConfiguredProbability = reward.Probability / weights[pool]
+		}
+		if poolSpins[pool] > 0 {
+			report.ObservedShare = float64(spins[reward.ID]) / float64(poolSpins[pool])
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
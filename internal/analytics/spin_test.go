@@ -0,0 +1,51 @@
+package analytics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/analytics"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+// Một lượt quay lúc 03:00 sáng giờ Việt Nam thuộc về ngày Việt Nam đó, dù
+// theo UTC nó vẫn là tối hôm trước.
+func TestSpinsPerDayBucketsByShopDay(t *testing.T) {
+	db := testdb.Open(t)
+	user := models.User{Username: "night-owl", Email: "owl@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{UserID: user.ID, TotalAmount: money.FromVND(100000)}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	reward := models.Reward{Name: "Sticker", Quantity: -1, Probability: 1}
+	if err := db.Create(&reward).Error; err != nil {
+		t.Fatal(err)
+	}
+	spunAt := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	if err := db.Create(&models.SpinLog{OrderID: order.ID, UserID: user.ID, RewardID: reward.ID, SpinDate: spunAt}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	r := analytics.Range{
+		From: time.Date(2026, 3, 1, 0, 0, 0, 0, analytics.Location),
+		To:   time.Date(2026, 3, 3, 0, 0, 0, 0, analytics.Location),
+	}
+	days, err := analytics.SpinsPerDay(db, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []analytics.DailySpins{{Date: "2026-03-01", Spins: 0}, {Date: "2026-03-02", Spins: 1}}
+	if len(days) != len(want) {
+		t.Fatalf("got %v, want %v", days, want)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("day %d: got %v, want %v", i, days[i], want[i])
+		}
+	}
+}
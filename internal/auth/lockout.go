@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MaxFailedLogins = 5
+	LockoutDuration = 15 * time.Minute
+)
+
+// LockedFor reports how long the account stays locked, or zero.
+func LockedFor(user *models.User) time.Duration {
+	if user.LockedUntil == nil {
+		return 0
+	}
+	if remaining := time.Until(*user.LockedUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// RecordFailedLogin counts a wrong password and locks the account once
+// MaxFailedLogins is reached, returning the lock duration in that case.
+func RecordFailedLogin(db *gorm.DB, userID uint) (time.Duration, error) {
+	var lockedFor time.Duration
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "failed_login_attempts").First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		attempts := user.FailedLoginAttempts + 1
+		if attempts < MaxFailedLogins {
+			return tx.Model(&user).Update("failed_login_attempts", attempts).Error
+		}
+
+		lockedFor = LockoutDuration
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"failed_login_attempts": 0,
+			"locked_until":          time.Now().Add(LockoutDuration),
+		}).Error
+	})
+	return lockedFor, err
+}
+
+func ClearFailedLogins(db *gorm.DB, userID uint) error {
+	return db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error
+}
+
+// UnknownEmailLockedFor is LockedFor for an email with no account.
+func UnknownEmailLockedFor(db *gorm.DB, email string) (time.Duration, error) {
+	var failure models.LoginFailure
+	err := db.Where("email = ?", strings.ToLower(email)).Limit(1).Find(&failure).Error
+	if err != nil || failure.LockedUntil == nil {
+		return 0, err
+	}
+	if remaining := time.Until(*failure.LockedUntil); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+// RecordUnknownEmailFailure counts a login attempt for an email with no
+// account the same way RecordFailedLogin counts a wrong password.
+func RecordUnknownEmailFailure(db *gorm.DB, email string) (time.Duration, error) {
+	var lockedFor time.Duration
+	err := db.Transaction(func(tx *gorm.DB) error {
+		failure := models.LoginFailure{Email: strings.ToLower(email)}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&failure).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", failure.Email).First(&failure).Error; err != nil {
+			return err
+		}
+
+		attempts := failure.FailedAttempts + 1
+		if attempts < MaxFailedLogins {
+			return tx.Model(&failure).Update("failed_attempts", attempts).Error
+		}
+
+		lockedFor = LockoutDuration
+		return tx.Model(&failure).Updates(map[string]interface{}{
+			"failed_attempts": 0,
+			"locked_until":    time.Now().Add(LockoutDuration),
+		}).Error
+	})
+	return lockedFor, err
+}
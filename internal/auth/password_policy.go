@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidateUsername returns a message describing why username is not
+// allowed, or "" when it is fine.
+func ValidateUsername(username string) string {
+	if !usernamePattern.MatchString(username) {
+		return "may only contain letters, digits, '.', '_' and '-'"
+	}
+	return ""
+}
+
+// ValidatePassword enforces the password policy: at least 8 characters with
+// a letter and a digit, and not containing the username or email.
+func ValidatePassword(password, username, email string) string {
+	if len(password) < 8 {
+		return "must be at least 8 characters"
+	}
+	if len(password) > 128 {
+		return "must be at most 128 characters"
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return "must contain at least one letter and one digit"
+	}
+
+	lower := strings.ToLower(password)
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return "must not contain your username"
+	}
+	if local, _, found := strings.Cut(strings.ToLower(email), "@"); found && len(local) >= 3 && strings.Contains(lower, local) {
+		return "must not contain your email address"
+	}
+	return ""
+}
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/rbac"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken        = errors.New("invalid or expired token")
+	ErrRevokedToken        = errors.New("token has been revoked")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+)
+
+type TokenPair struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	ExpiresIn    int       `json:"expiresIn"`
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func init() {
+	// iat carries microseconds so that it orders correctly against
+	// tokens_valid_after even when both fall in the same second.
+	jwt.TimePrecision = time.Microsecond
+}
+
+// Tokens issues and checks access tokens with the configured signing secret.
+// Build one at startup and hand it to the handlers that need it.
+type Tokens struct {
+	secret []byte
+}
+
+// NewTokens reads the signing secret; config.Validate guarantees it is set.
+func NewTokens(cfg config.AuthConfig) *Tokens {
+	return &Tokens{secret: []byte(cfg.JWTSecret)}
+}
+
+func (t *Tokens) issueAccessToken(user *models.User, roles []string) (string, time.Time, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(AccessTokenTTL)
+	claims := &models.CustomJWTClaims{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Admin:    user.Admin,
+		Roles:    roles,
+		MFA:      user.TOTPEnabled,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+func createRefreshToken(tx *gorm.DB, userID uint, familyID, userAgent, ip string) (string, *models.RefreshToken, error) {
+	raw, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+	record := models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, &record, nil
+}
+
+// newPair signs an access token carrying the user's current roles. Role
+// changes therefore take effect on the next refresh, within AccessTokenTTL.
+func (t *Tokens) newPair(db *gorm.DB, user *models.User, refresh string) (*TokenPair, error) {
+	roles, err := rbac.RolesFor(db, user)
+	if err != nil {
+		return nil, err
+	}
+	access, expiresAt, err := t.issueAccessToken(user, roles)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresAt:    expiresAt,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Login starts a new refresh token family for the user.
+func (t *Tokens) Login(db *gorm.DB, user *models.User, userAgent, ip string) (*TokenPair, error) {
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	raw, _, err := createRefreshToken(db, user.ID, familyID, userAgent, ip)
+	if err != nil {
+		return nil, err
+	}
+	return t.newPair(db, user, raw)
+}
+
+// Refresh rotates a refresh token: the presented token is retired and a new
+// one in the same family is returned with a fresh access token. Presenting a
+// token that was already rotated is treated as theft and revokes the family.
+func (t *Tokens) Refresh(db *gorm.DB, raw, userAgent, ip string) (*TokenPair, error) {
+	var pair *TokenPair
+	reused := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", hashToken(raw)).First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			if current.ReplacedByID != nil {
+				reused = true
+			}
+			return ErrInvalidRefreshToken
+		}
+		if time.Now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		next, record, err := createRefreshToken(tx, user.ID, current.FamilyID, userAgent, ip)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&current).Updates(map[string]interface{}{"revoked_at": now, "replaced_by_id": record.ID}).Error; err != nil {
+			return err
+		}
+
+		pair, err = t.newPair(tx, &user, next)
+		return err
+	})
+
+	if reused {
+		if revokeErr := revokeFamilyOf(db, raw); revokeErr != nil {
+			return nil, fmt.Errorf("revoking reused refresh token family: %w", revokeErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+func revokeFamilyOf(db *gorm.DB, raw string) error {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// ParseAccessToken checks the signature and expiry of an access token.
+func (t *Tokens) ParseAccessToken(tokenString string) (*models.CustomJWTClaims, error) {
+	claims := &models.CustomJWTClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return t.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// CheckRevoked rejects access tokens that were logged out individually or
+// issued before the user's last "log out all devices". Both are answered by
+// one query since it runs on every authenticated request.
+func CheckRevoked(db *gorm.DB, claims *models.CustomJWTClaims) error {
+	var state struct {
+		TokensValidAfter *time.Time
+		Revoked          bool
+	}
+	result := db.Model(&models.User{}).
+		Select("tokens_valid_after, EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = ? AND deleted_at IS NULL) AS revoked", claims.RegisteredClaims.ID).
+		Where("id = ?", claims.ID).
+		Limit(1).
+		Scan(&state)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 || state.Revoked {
+		return ErrRevokedToken
+	}
+	if state.TokensValidAfter != nil && claims.IssuedAt != nil &&
+		claims.IssuedAt.Time.Before(*state.TokensValidAfter) {
+		return ErrRevokedToken
+	}
+	return nil
+}
+
+// Logout revokes the presented access token and, when given, its refresh token.
+func Logout(db *gorm.DB, claims *models.CustomJWTClaims, rawRefresh string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if claims.RegisteredClaims.ID != "" && claims.ExpiresAt != nil {
+			revoked := models.RevokedAccessToken{
+				JTI:       claims.RegisteredClaims.ID,
+				UserID:    claims.ID,
+				ExpiresAt: claims.ExpiresAt.Time,
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+				return err
+			}
+		}
+		if rawRefresh == "" {
+			return nil
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("token_hash = ? AND user_id = ? AND revoked_at IS NULL", hashToken(rawRefresh), claims.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// LogoutAll revokes every refresh token of the user and invalidates all
+// access tokens issued up to now.
+func LogoutAll(db *gorm.DB, userID uint) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := InvalidateAccessTokens(tx, userID); err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// InvalidateAccessTokens makes every access token issued to the user so far
+// fail CheckRevoked. The cut-off is bumped one microsecond past now, the
+// precision of both iat and timestamptz, so a token signed in the same
+// microsecond cannot slip through.
+func InvalidateAccessTokens(tx *gorm.DB, userID uint) error {
+	validAfter := time.Now().Truncate(time.Microsecond).Add(time.Microsecond)
+	return tx.Model(&models.User{}).Where("id = ?", userID).Update("tokens_valid_after", validAfter).Error
+}
+
+// PurgeExpired deletes deny-list entries and refresh tokens that can no
+// longer be presented, and unknown-email lockouts that have run out.
+func PurgeExpired(db *gorm.DB) error {
+	now := time.Now()
+	if err := db.Unscoped().Where("expires_at < ?", now).Delete(&models.RevokedAccessToken{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("updated_at < ?", now.Add(-LockoutDuration)).Delete(&models.LoginFailure{}).Error; err != nil {
+		return err
+	}
+	return db.Unscoped().Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error
+}
@@ -0,0 +1,57 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+func TestCheckRevoked(t *testing.T) {
+	tokens := auth.NewTokens(config.AuthConfig{JWTSecret: "test-secret"})
+	db := testdb.Open(t)
+	user := models.User{Username: "devices", Email: "devices@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func() *models.CustomJWTClaims {
+		t.Helper()
+		pair, err := tokens.Login(db, &user, "test", "127.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := tokens.ParseAccessToken(pair.AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claims
+	}
+
+	before := issue()
+	if err := auth.CheckRevoked(db, before); err != nil {
+		t.Fatalf("fresh token: %v", err)
+	}
+
+	// Cùng một giây với lần đăng xuất vẫn phải bị chặn.
+	if err := auth.LogoutAll(db, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.CheckRevoked(db, before); !errors.Is(err, auth.ErrRevokedToken) {
+		t.Errorf("token issued before logout-all: got %v, want ErrRevokedToken", err)
+	}
+
+	after := issue()
+	if err := auth.CheckRevoked(db, after); err != nil {
+		t.Fatalf("token issued after logout-all: %v", err)
+	}
+	if err := auth.Logout(db, after, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.CheckRevoked(db, after); !errors.Is(err, auth.ErrRevokedToken) {
+		t.Errorf("logged-out token: got %v, want ErrRevokedToken", err)
+	}
+}
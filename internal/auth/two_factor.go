@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	backupCodeCount   = 10
+	LoginChallengeTTL = 5 * time.Minute
+)
+
+var (
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrTwoFactorNotPending  = errors.New("two-factor setup has not been started")
+)
+
+const backupAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+func newBackupCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = backupAlphabet[int(b[i])%len(backupAlphabet)]
+	}
+	return string(b[:4]) + "-" + string(b[4:]), nil
+}
+
+// RegenerateBackupCodes replaces the user's backup codes and returns the new
+// plaintext codes; only their argon2 hashes are kept.
+func RegenerateBackupCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.BackupCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, backupCodeCount)
+	for i := 0; i < backupCodeCount; i++ {
+		code, err := newBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := utils.GenerateFromPassword(code, utils.DefaultHashParams)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.BackupCode{UserID: userID, CodeHash: hash}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func useBackupCode(tx *gorm.DB, userID uint, code string) (bool, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	var codes []models.BackupCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND used_at IS NULL", userID).Find(&codes).Error; err != nil {
+		return false, err
+	}
+	for _, candidate := range codes {
+		match, err := utils.ComparePasswordAndHash(code, candidate.CodeHash)
+		if err != nil || !match {
+			continue
+		}
+		return true, tx.Model(&candidate).Update("used_at", time.Now()).Error
+	}
+	return false, nil
+}
+
+// isTOTPFormat reports whether code looks like an authenticator code
+// (six digits, spaces allowed), as opposed to a backup code (xxxx-xxxx).
+func isTOTPFormat(code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isBackupCodeFormat(code string) bool {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) != 9 || code[4] != '-' {
+		return false
+	}
+	for i, r := range code {
+		if i != 4 && !strings.ContainsRune(backupAlphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused backup code,
+// chosen by the code's format. A six-digit code never burns argon2 time
+// comparing against every backup code, and a backup code is never tried as
+// a TOTP.
+func VerifySecondFactor(tx *gorm.DB, userID uint, code string) error {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	switch {
+	case isTOTPFormat(code):
+		step, ok := VerifyTOTP(user.TOTPSecret, code, user.TOTPLastStep)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		return tx.Model(&user).Update("totp_last_step", step).Error
+	case isBackupCodeFormat(code):
+		used, err := useBackupCode(tx, userID, code)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	default:
+		return ErrInvalidTwoFactorCode
+	}
+}
+
+// BeginTOTPSetup stores a new, not yet enabled secret for the user.
+func BeginTOTPSetup(db *gorm.DB, user *models.User) (string, string, error) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := db.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		return "", "", err
+	}
+	return secret, ProvisioningURI(secret, user.Email), nil
+}
+
+// EnableTOTP confirms the pending secret with a code and returns fresh backup codes.
+func EnableTOTP(tx *gorm.DB, userID uint, code string) ([]string, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotPending
+	}
+
+	step, ok := VerifyTOTP(user.TOTPSecret, code, user.TOTPLastStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	if err := tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error; err != nil {
+		return nil, err
+	}
+	return RegenerateBackupCodes(tx, userID)
+}
+
+func DisableTOTP(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.BackupCode{}).Error
+}
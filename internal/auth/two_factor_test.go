@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+func currentCode(t *testing.T, secret string) (string, int64) {
+	t.Helper()
+	key, err := base32NoPad.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := time.Now().Unix() / totpPeriod
+	return totpCode(key, step), step
+}
+
+func TestSecondFactorFormats(t *testing.T) {
+	cases := []struct {
+		code         string
+		totp, backup bool
+	}{
+		{"123456", true, false},
+		{" 123 456 ", true, false},
+		{"12345", false, false},
+		{"abcd-efgh", false, true},
+		{"ABCD-EFGH", false, true},
+		{"abcdefgh", false, false},
+		{"abc1-efgh", false, false}, // 1 is not in the backup alphabet
+		{"2345-6789", false, true},
+	}
+	for _, tc := range cases {
+		if got := isTOTPFormat(tc.code); got != tc.totp {
+			t.Errorf("isTOTPFormat(%q) = %v", tc.code, got)
+		}
+		if got := isBackupCodeFormat(tc.code); got != tc.backup {
+			t.Errorf("isBackupCodeFormat(%q) = %v", tc.code, got)
+		}
+	}
+}
+
+func TestVerifyTOTPRejectsReplay(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, step := currentCode(t, secret)
+
+	got, ok := VerifyTOTP(secret, code, 0)
+	if !ok || got != step {
+		t.Fatalf("VerifyTOTP = %d, %v; want step %d", got, ok, step)
+	}
+	if _, ok := VerifyTOTP(secret, code, step); ok {
+		t.Error("a code was accepted twice")
+	}
+	if _, ok := VerifyTOTP(secret, "000000", 0); ok && code != "000000" {
+		t.Error("a wrong code was accepted")
+	}
+}
+
+func TestVerifySecondFactor(t *testing.T) {
+	db := testdb.Open(t)
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "mfa", Email: "mfa@example.com", Password: "x", TOTPSecret: secret, TOTPEnabled: true}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	codes, err := RegenerateBackupCodes(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := currentCode(t, secret)
+	if err := VerifySecondFactor(db, user.ID, code); err != nil {
+		t.Fatalf("TOTP code: %v", err)
+	}
+	if err := VerifySecondFactor(db, user.ID, code); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("replayed TOTP code: got %v", err)
+	}
+
+	if err := VerifySecondFactor(db, user.ID, codes[0]); err != nil {
+		t.Fatalf("backup code: %v", err)
+	}
+	if err := VerifySecondFactor(db, user.ID, codes[0]); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("reused backup code: got %v", err)
+	}
+	if err := VerifySecondFactor(db, user.ID, "not a code"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("malformed code: got %v", err)
+	}
+}
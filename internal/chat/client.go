@@ -19,19 +19,20 @@ const (
     maxMessageSize = 512
 )
 
-var upgrader = websocket.Upgrader{
-    ReadBufferSize:  1024,
-    WriteBufferSize: 1024,
-    CheckOrigin: func(r *http.Request) bool {
-        origin := r.Header.Get("Origin")
-        allowedOrigins := []string{"http://localhost:5173", "https://tunitoku.netlify.app", "https://tunitoku.store"}
-        for _, o := range allowedOrigins {
-            if o == origin {
-                return true
+func (h *Hub) upgrader() *websocket.Upgrader {
+    return &websocket.Upgrader{
+        ReadBufferSize:  1024,
+        WriteBufferSize: 1024,
+        CheckOrigin: func(r *http.Request) bool {
+            origin := r.Header.Get("Origin")
+            for _, o := range h.allowedOrigins {
+                if o == origin {
+                    return true
+                }
             }
-        }
-        return false
-    },
+            return false
+        },
+    }
 }
 
 type Client struct {
@@ -50,8 +51,12 @@ type ChatMessage struct {
 
 func (c *Client) readPump() {
     defer func() {
-        c.hub.unregister <- c
+        select {
+        case c.hub.unregister <- c:
+        case <-c.hub.quit:
+        }
         c.conn.Close()
+        c.hub.pumps.Done()
     }()
     c.conn.SetReadLimit(maxMessageSize)
     c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -84,6 +89,8 @@ func (c *Client) readPump() {
             receiverID = adminID
         }
 
+        // Customers write to the shop inbox; staff replies are stored under
+        // whoever actually answered.
         dbMessage := models.Message{
             SenderID:   c.userID,
             ReceiverID: receiverID,
@@ -96,22 +103,12 @@ func (c *Client) readPump() {
             continue
         }
 
-        var senderUser models.User
-        db.First(&senderUser, c.userID)
-
-        recipient, ok := c.hub.clients[receiverID]
-        if ok {
-            fullMessage, _ := json.Marshal(dbMessage)
-            select {
-            case recipient.send <- fullMessage:
-            default:
-                close(recipient.send)
-                delete(c.hub.clients, recipient.userID)
-            }
-        }
-        
         fullMessage, _ := json.Marshal(dbMessage)
-        c.send <- fullMessage
+        select {
+        case c.hub.deliver <- delivery{from: c, receiverID: receiverID, message: fullMessage}:
+        case <-c.hub.quit:
+            return
+        }
     }
 }
 
@@ -120,6 +117,7 @@ func (c *Client) writePump() {
     defer func() {
         ticker.Stop()
         c.conn.Close()
+        c.hub.pumps.Done()
     }()
     for {
         select {
@@ -149,12 +147,15 @@ func (c *Client) writePump() {
             if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
                 return
             }
+        case <-c.hub.connsClosed:
+            // Hub đã gửi close frame và đóng kết nối.
+            return
         }
     }
 }
 
 func ServeWs(hub *Hub, c *gin.Context, userID uint, isAdmin bool) {
-    conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+    conn, err := hub.upgrader().Upgrade(c.Writer, c.Request, nil)
     if err != nil {
         log.Println(err)
         return
@@ -166,7 +167,13 @@ func ServeWs(hub *Hub, c *gin.Context, userID uint, isAdmin bool) {
         userID:  userID,
         isAdmin: isAdmin,
     }
-    client.hub.register <- client
+    select {
+    case client.hub.register <- client:
+    case <-hub.quit:
+        conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(writeWait))
+        conn.Close()
+        return
+    }
 
     go client.writePump()
     go client.readPump()
@@ -1,7 +1,12 @@
 package chat
 
 import (
+	"context"
 	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 type Hub struct {
@@ -9,31 +14,113 @@ type Hub struct {
 	broadcast chan []byte
 	register chan *Client
 	unregister chan *Client
+	deliver chan delivery
+
+	allowedOrigins []string
+
+	quit chan struct{}
+	// connsClosed is closed once Run has sent every client its close frame
+	// and closed the connection; writePumps then exit.
+	connsClosed chan struct{}
+	stopped     chan struct{}
+	// pumps counts the read and write pumps of registered clients. Only Run
+	// adds to it, so Add never races with the Wait at shutdown.
+	pumps sync.WaitGroup
+}
+
+// delivery is a saved message on its way to the clients that should see it:
+// the sender, the receiver and every responder. Only Run touches the clients
+// map and sends on or closes send channels.
+type delivery struct {
+	from       *Client
+	receiverID uint
+	message    []byte
 }
 
-func NewHub() *Hub {
+func NewHub(allowedOrigins []string) *Hub {
 	return &Hub{
+		allowedOrigins: allowedOrigins,
 		broadcast:  make(chan []byte),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		deliver:    make(chan delivery),
 		clients:    make(map[uint]*Client),
+		quit:       make(chan struct{}),
+		connsClosed: make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
 }
 
 func (h *Hub) Run() {
+	defer close(h.stopped)
 	for {
 		select {
+		case <-h.quit:
+			h.stop()
+			return
 		case client := <-h.register:
 			log.Printf("Client registered: UserID %d", client.userID)
+			h.pumps.Add(2)
+			if old, ok := h.clients[client.userID]; ok {
+				// Kết nối cũ của cùng user bị thay thế: đóng nó để pump của nó dừng.
+				close(old.send)
+			}
 			h.clients[client.userID] = client
 		case client := <-h.unregister:
-			if _, ok := h.clients[client.userID]; ok {
+			// A newer connection of the same user may have replaced this one.
+			if h.clients[client.userID] == client {
 				delete(h.clients, client.userID)
 				close(client.send)
 				log.Printf("Client unregistered: UserID %d", client.userID)
 			}
+		case d := <-h.deliver:
+			for id, client := range h.clients {
+				if client != d.from && client.userID != d.receiverID && !client.isAdmin {
+					continue
+				}
+				select {
+				case client.send <- d.message:
+				default:
+					// Client không đọc kịp: ngắt nó thay vì chặn cả hub.
+					close(client.send)
+					delete(h.clients, id)
+				}
+			}
 		case message := <-h.broadcast:
 			log.Printf("Message received in hub: %s", string(message))
 		}
 	}
 }
+
+// stop sends every client a going-away close frame and closes its
+// connection, which ends its readPump; closing connsClosed ends the
+// writePumps. Send channels are closed only after both pumps of every client
+// have returned, so nothing is left reading from or writing to them.
+func (h *Hub) stop() {
+	closeFrame := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, client := range h.clients {
+		client.conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(writeWait))
+		client.conn.Close()
+	}
+	close(h.connsClosed)
+	h.pumps.Wait()
+
+	for id, client := range h.clients {
+		close(client.send)
+		delete(h.clients, id)
+	}
+	log.Println("Chat hub stopped")
+}
+
+// Shutdown stops Run, sends every connected client a going-away close frame
+// and waits until those frames are written or ctx is done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	close(h.quit)
+
+	select {
+	case <-h.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
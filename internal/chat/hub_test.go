@@ -0,0 +1,122 @@
+package chat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// newTestClient registers a client without a connection or pumps; tests read
+// its send channel directly. Hubs holding such clients are never shut down.
+func newTestClient(h *Hub, userID uint, isAdmin bool) *Client {
+	c := &Client{hub: h, send: make(chan []byte, 4), userID: userID, isAdmin: isAdmin}
+	h.register <- c
+	return c
+}
+
+func received(c *Client) []string {
+	var got []string
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				return append(got, "<closed>")
+			}
+			got = append(got, string(msg))
+		case <-time.After(50 * time.Millisecond):
+			return got
+		}
+	}
+}
+
+func TestHubDeliversToSenderReceiverAndStaff(t *testing.T) {
+	h := NewHub(nil)
+	go h.Run()
+
+	customer := newTestClient(h, 1, false)
+	other := newTestClient(h, 2, false)
+	staff := newTestClient(h, 3, true)
+
+	h.deliver <- delivery{from: staff, receiverID: customer.userID, message: []byte("hi")}
+
+	if got := received(customer); len(got) != 1 || got[0] != "hi" {
+		t.Errorf("customer got %v", got)
+	}
+	if got := received(staff); len(got) != 1 {
+		t.Errorf("sender got %v, want its own echo", got)
+	}
+	if got := received(other); len(got) != 0 {
+		t.Errorf("unrelated customer got %v", got)
+	}
+}
+
+// Khi cùng một người mở kết nối thứ hai, việc đóng kết nối cũ không được làm
+// mất kết nối mới.
+func TestHubUnregisterIgnoresReplacedClient(t *testing.T) {
+	h := NewHub(nil)
+	go h.Run()
+
+	first := newTestClient(h, 1, false)
+	second := newTestClient(h, 1, false)
+	h.unregister <- first
+
+	h.deliver <- delivery{from: second, receiverID: 1, message: []byte("still here")}
+	if got := received(second); len(got) != 1 || got[0] != "still here" {
+		t.Errorf("replacement connection got %v", got)
+	}
+}
+
+func TestShutdownSendsGoingAwayAndWaitsForPumps(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const origin = "https://shop.test"
+	h := NewHub([]string{origin})
+	go h.Run()
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) { ServeWs(h, c, 1, false) })
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", http.Header{"Origin": {origin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Gửi lặp lại cho tới khi client nhận được, tức là hub đã đăng ký nó.
+	registered := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-registered:
+				return
+			case h.deliver <- delivery{receiverID: 1, message: []byte("ping")}:
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("client never registered: %v", err)
+	}
+	close(registered)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("client read %v, want a going-away close", err)
+	}
+}
@@ -0,0 +1,409 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MinJWTSecretLength is the shortest HS256 signing secret we accept.
+const MinJWTSecretLength = 32
+
+type Config struct {
+	Server       ServerConfig       `json:"server"`
+	Database     DatabaseConfig     `json:"database"`
+	Auth         AuthConfig         `json:"auth"`
+	Email        EmailConfig        `json:"email"`
+	R2           R2Config           `json:"r2"`
+	ExchangeRate ExchangeRateConfig `json:"exchangeRate"`
+	Payments     PaymentsConfig     `json:"payments"`
+	RateLimit    RateLimitConfig    `json:"rateLimit"`
+}
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+type ServerConfig struct {
+	// Environment is EnvDevelopment or EnvProduction (APP_ENV). Only
+	// development relaxes checks meant to catch a half-configured deploy.
+	Environment string   `json:"environment"`
+	Port        string   `json:"port"`
+	CORSOrigins []string `json:"corsOrigins"`
+	// TrustedProxies are the IPs or CIDRs of reverse proxies whose
+	// X-Forwarded-For is believed. Empty trusts none, so the client IP used
+	// for rate limiting is always the connecting address.
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+type DatabaseConfig struct {
+	URL string `json:"url"`
+	// AutoMigrate applies pending migrations when the server starts instead
+	// of refusing to serve. Meant for single-instance deploys.
+	AutoMigrate bool `json:"autoMigrate"`
+}
+
+type AuthConfig struct {
+	JWTSecret       string `json:"jwtSecret"`
+	RequireStaff2FA bool   `json:"requireStaff2fa"`
+}
+
+const (
+	EmailTransportResend = "resend"
+	EmailTransportSMTP   = "smtp"
+	EmailTransportFile   = "file"
+	EmailTransportMemory = "memory"
+)
+
+type EmailConfig struct {
+	// Transport is one of the EmailTransport constants. Left empty it becomes
+	// resend when an API key is set, or the file outbox in development;
+	// anywhere else it must be chosen explicitly.
+	Transport      string `json:"transport"`
+	ResendAPIKey   string `json:"resendApiKey"`
+	FromEmail      string `json:"fromEmail"`
+	RecipientEmail string `json:"recipientEmail"`
+	FrontendURL    string `json:"frontendUrl"`
+	SMTPHost       string `json:"smtpHost"`
+	SMTPPort       string `json:"smtpPort"`
+	SMTPUsername   string `json:"smtpUsername"`
+	SMTPPassword   string `json:"smtpPassword"`
+	OutboxDir      string `json:"outboxDir"`
+}
+
+type R2Config struct {
+	AccountID       string `json:"accountId"`
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	BucketName      string `json:"bucketName"`
+	PublicURL       string `json:"publicUrl"`
+}
+
+// Validate checks the credentials needed for uploads. Only the server uploads,
+// so CLI commands run without R2 configured.
+func (r2 R2Config) Validate() error {
+	if r2.AccountID == "" || r2.AccessKeyID == "" || r2.SecretAccessKey == "" || r2.BucketName == "" || r2.PublicURL == "" {
+		return fmt.Errorf("R2_ACCOUNT_ID, R2_ACCESS_KEY_ID, R2_SECRET_ACCESS_KEY, R2_BUCKET_NAME and R2_PUBLIC_URL must all be set")
+	}
+	return nil
+}
+
+type ExchangeRateConfig struct {
+	Provider string `json:"provider"`
+	URL      string `json:"url"`
+	File     string `json:"file"`
+	Cron     string `json:"cron"`
+}
+
+type PaymentsConfig struct {
+	VNPayTmnCode    string `json:"vnpayTmnCode"`
+	VNPayHashSecret string `json:"vnpayHashSecret"`
+	VNPayPayURL     string `json:"vnpayPayUrl"`
+	ReturnURL       string `json:"returnUrl"`
+	FakeSecret      string `json:"fakeSecret"`
+}
+
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+type RateLimitConfig struct {
+	// Store is memory (per instance) or redis (shared between instances).
+	Store    string `json:"store"`
+	RedisURL string `json:"redisUrl"`
+}
+
+func defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Environment: EnvProduction,
+			Port:        "8080",
+			CORSOrigins: []string{
+				"http://localhost:5173",
+				"https://tunitoku.netlify.app",
+				"https://tunitoku.store",
+			},
+		},
+		Email: EmailConfig{
+			FrontendURL: "https://tunitoku.store",
+			SMTPPort:    "587",
+			OutboxDir:   "outbox",
+		},
+		ExchangeRate: ExchangeRateConfig{
+			URL:  "https://open.er-api.com/v6/latest/JPY",
+			Cron: "0 */6 * * *",
+		},
+		Payments: PaymentsConfig{
+			VNPayPayURL: "https://sandbox.vnpayment.vn/paymentv2/vpcpay.html",
+		},
+		RateLimit: RateLimitConfig{
+			Store: RateLimitStoreMemory,
+		},
+	}
+}
+
+// Load builds the configuration from the built-in defaults, then the JSON
+// file at path (skipped when path is empty), then environment variables,
+// and validates the result.
+func Load(path string) (*Config, error) {
+	cfg := defaults()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	cfg.applyEnv()
+	cfg.normalize()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (cfg *Config) applyEnv() {
+	strs := map[string]*string{
+		"APP_ENV":                &cfg.Server.Environment,
+		"PORT":                   &cfg.Server.Port,
+		"DATABASE_URL":           &cfg.Database.URL,
+		"JWT_SECRET":             &cfg.Auth.JWTSecret,
+		"RESEND_API_KEY":         &cfg.Email.ResendAPIKey,
+		"RESEND_FROM_EMAIL":      &cfg.Email.FromEmail,
+		"RECIPIENT_EMAIL":        &cfg.Email.RecipientEmail,
+		"FRONTEND_URL":           &cfg.Email.FrontendURL,
+		"EMAIL_TRANSPORT":        &cfg.Email.Transport,
+		"SMTP_HOST":              &cfg.Email.SMTPHost,
+		"SMTP_PORT":              &cfg.Email.SMTPPort,
+		"SMTP_USERNAME":          &cfg.Email.SMTPUsername,
+		"SMTP_PASSWORD":          &cfg.Email.SMTPPassword,
+		"EMAIL_OUTBOX_DIR":       &cfg.Email.OutboxDir,
+		"R2_ACCOUNT_ID":          &cfg.R2.AccountID,
+		"R2_ACCESS_KEY_ID":       &cfg.R2.AccessKeyID,
+		"R2_SECRET_ACCESS_KEY":   &cfg.R2.SecretAccessKey,
+		"R2_BUCKET_NAME":         &cfg.R2.BucketName,
+		"R2_PUBLIC_URL":          &cfg.R2.PublicURL,
+		"EXCHANGE_RATE_PROVIDER": &cfg.ExchangeRate.Provider,
+		"EXCHANGE_RATE_URL":      &cfg.ExchangeRate.URL,
+		"EXCHANGE_RATE_FILE":     &cfg.ExchangeRate.File,
+		"EXCHANGE_RATE_CRON":     &cfg.ExchangeRate.Cron,
+		"VNPAY_TMN_CODE":         &cfg.Payments.VNPayTmnCode,
+		"VNPAY_HASH_SECRET":      &cfg.Payments.VNPayHashSecret,
+		"VNPAY_PAY_URL":          &cfg.Payments.VNPayPayURL,
+		"PAYMENT_RETURN_URL":     &cfg.Payments.ReturnURL,
+		"FAKE_PAYMENT_SECRET":    &cfg.Payments.FakeSecret,
+		"RATE_LIMIT_STORE":       &cfg.RateLimit.Store,
+		"REDIS_URL":              &cfg.RateLimit.RedisURL,
+	}
+	for name, field := range strs {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			*field = v
+		}
+	}
+	// EMAIL_FROM dùng cho mọi transport; RESEND_FROM_EMAIL là tên cũ.
+	if v := os.Getenv("EMAIL_FROM"); v != "" {
+		cfg.Email.FromEmail = v
+	}
+
+	if v := os.Getenv("CORS_ORIGINS"); v != "" {
+		cfg.Server.CORSOrigins = strings.Split(v, ",")
+	}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		cfg.Server.TrustedProxies = strings.Split(v, ",")
+	}
+	if v := os.Getenv("AUTO_MIGRATE"); v != "" {
+		cfg.Database.AutoMigrate = v == "true"
+	}
+	if v := os.Getenv("REQUIRE_STAFF_2FA"); v != "" {
+		cfg.Auth.RequireStaff2FA = v == "true"
+	}
+}
+
+func (cfg *Config) normalize() {
+	origins := cfg.Server.CORSOrigins[:0]
+	for _, o := range cfg.Server.CORSOrigins {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	cfg.Server.CORSOrigins = origins
+	proxies := cfg.Server.TrustedProxies[:0]
+	for _, p := range cfg.Server.TrustedProxies {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	cfg.Server.TrustedProxies = proxies
+	cfg.Email.FrontendURL = strings.TrimRight(cfg.Email.FrontendURL, "/")
+	if cfg.Email.Transport == "" {
+		switch {
+		case cfg.Email.ResendAPIKey != "":
+			cfg.Email.Transport = EmailTransportResend
+		case cfg.Server.Environment == EnvDevelopment:
+			cfg.Email.Transport = EmailTransportFile
+		}
+	}
+	cfg.R2.PublicURL = strings.TrimRight(cfg.R2.PublicURL, "/")
+}
+
+func checkURL(name, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute http(s) URL, got %q", name, raw)
+	}
+	return nil
+}
+
+// Validate reports every problem at once so a bad deploy can be fixed in
+// one go rather than one restart per missing variable.
+func (cfg *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if cfg.Server.Environment != EnvDevelopment && cfg.Server.Environment != EnvProduction {
+		fail("APP_ENV must be %s or %s, got %q", EnvDevelopment, EnvProduction, cfg.Server.Environment)
+	}
+	if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		fail("PORT must be a number between 1 and 65535, got %q", cfg.Server.Port)
+	}
+	if len(cfg.Server.CORSOrigins) == 0 {
+		fail("CORS_ORIGINS must list at least one origin")
+	}
+	for _, o := range cfg.Server.CORSOrigins {
+		if err := checkURL("CORS origin", o); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, p := range cfg.Server.TrustedProxies {
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				fail("TRUSTED_PROXIES entry %q is not an IP or CIDR", p)
+			}
+		}
+	}
+
+	if cfg.Database.URL == "" {
+		fail("DATABASE_URL must be set")
+	}
+
+	switch {
+	case cfg.Auth.JWTSecret == "":
+		fail("JWT_SECRET must be set")
+	case len(cfg.Auth.JWTSecret) < MinJWTSecretLength:
+		fail("JWT_SECRET must be at least %d characters", MinJWTSecretLength)
+	}
+
+	switch cfg.Email.Transport {
+	case "":
+		fail("EMAIL_TRANSPORT must be set outside development: resend or smtp, or file or memory to keep emails local")
+	case EmailTransportFile, EmailTransportMemory:
+	case EmailTransportResend:
+		if cfg.Email.ResendAPIKey == "" || cfg.Email.FromEmail == "" {
+			fail("RESEND_API_KEY and EMAIL_FROM must be set for the resend transport")
+		}
+	case EmailTransportSMTP:
+		if cfg.Email.SMTPHost == "" || cfg.Email.FromEmail == "" {
+			fail("SMTP_HOST and EMAIL_FROM must be set for the smtp transport")
+		}
+		if port, err := strconv.Atoi(cfg.Email.SMTPPort); err != nil || port < 1 || port > 65535 {
+			fail("SMTP_PORT must be a number between 1 and 65535, got %q", cfg.Email.SMTPPort)
+		}
+	default:
+		fail("unknown EMAIL_TRANSPORT %q", cfg.Email.Transport)
+	}
+	if cfg.Email.FromEmail != "" {
+		if _, err := mail.ParseAddress(cfg.Email.FromEmail); err != nil {
+			fail("EMAIL_FROM is not a valid address: %v", err)
+		}
+	}
+	if cfg.Email.RecipientEmail != "" {
+		if _, err := mail.ParseAddress(cfg.Email.RecipientEmail); err != nil {
+			fail("RECIPIENT_EMAIL is not a valid address: %v", err)
+		}
+	}
+	if err := checkURL("FRONTEND_URL", cfg.Email.FrontendURL); err != nil {
+		errs = append(errs, err)
+	}
+
+	// R2 chỉ cần khi chạy server (upload ảnh); main gọi R2.Validate riêng.
+	if cfg.R2.PublicURL != "" {
+		if err := checkURL("R2_PUBLIC_URL", cfg.R2.PublicURL); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch cfg.ExchangeRate.Provider {
+	case "":
+	case "http":
+		if err := checkURL("EXCHANGE_RATE_URL", cfg.ExchangeRate.URL); err != nil {
+			errs = append(errs, err)
+		}
+	case "file":
+		if cfg.ExchangeRate.File == "" {
+			fail("EXCHANGE_RATE_FILE must be set for the file provider")
+		}
+	default:
+		fail("unknown EXCHANGE_RATE_PROVIDER %q", cfg.ExchangeRate.Provider)
+	}
+	if cfg.ExchangeRate.Provider != "" {
+		if _, err := cron.ParseStandard(cfg.ExchangeRate.Cron); err != nil {
+			fail("invalid EXCHANGE_RATE_CRON schedule %q: %v", cfg.ExchangeRate.Cron, err)
+		}
+	}
+
+	p := cfg.Payments
+	if (p.VNPayTmnCode == "") != (p.VNPayHashSecret == "") {
+		fail("VNPAY_TMN_CODE and VNPAY_HASH_SECRET must be set together")
+	}
+	if p.VNPayTmnCode != "" {
+		if err := checkURL("VNPAY_PAY_URL", p.VNPayPayURL); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.FakeSecret != "" && cfg.Server.Environment != EnvDevelopment {
+		fail("FAKE_PAYMENT_SECRET is only allowed with APP_ENV=%s", EnvDevelopment)
+	}
+	if p.ReturnURL != "" {
+		if err := checkURL("PAYMENT_RETURN_URL", p.ReturnURL); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch cfg.RateLimit.Store {
+	case RateLimitStoreMemory:
+	case RateLimitStoreRedis:
+		if u, err := url.Parse(cfg.RateLimit.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			fail("REDIS_URL must be a redis:// or rediss:// URL for the redis rate limit store")
+		}
+	default:
+		fail("unknown RATE_LIMIT_STORE %q", cfg.RateLimit.Store)
+	}
+
+	return errors.Join(errs...)
+}
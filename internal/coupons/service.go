@@ -0,0 +1,215 @@
+package coupons
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFound       = errors.New("coupon not found")
+	ErrInactive       = errors.New("coupon is not active")
+	ErrNotStarted     = errors.New("coupon is not valid yet")
+	ErrExpired        = errors.New("coupon has expired")
+	ErrUsageExceeded  = errors.New("coupon usage limit reached")
+	ErrUserLimit      = errors.New("you have already used this coupon the maximum number of times")
+	ErrMinOrderNotMet = errors.New("order does not meet the coupon minimum value")
+	ErrNotApplicable  = errors.New("coupon does not apply to any item in the cart")
+)
+
+// Breakdown is the priced cart. The VIP discount is taken from the whole
+// subtotal first; the coupon then applies to the eligible items at their
+// VIP-discounted price, so the two never discount the same đồng twice.
+type Breakdown struct {
+	Subtotal         money.Money `json:"subtotal"`
+	VIPDiscount      money.Money `json:"vipDiscount"`
+	EligibleSubtotal money.Money `json:"eligibleSubtotal"`
+	CouponDiscount   money.Money `json:"couponDiscount"`
+	Total            money.Money `json:"total"`
+	CouponCode       string      `json:"couponCode,omitempty"`
+}
+
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func FindByCode(db *gorm.DB, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := db.Preload("Categories").Preload("Products").Where("code = ?", NormalizeCode(code)).First(&coupon).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func appliesTo(coupon *models.Coupon, product models.Product) bool {
+	if len(coupon.Categories) == 0 && len(coupon.Products) == 0 {
+		return true
+	}
+	for _, p := range coupon.Products {
+		if p.ID == product.ID {
+			return true
+		}
+	}
+	for _, couponCategory := range coupon.Categories {
+		for _, productCategory := range product.Categories {
+			if couponCategory.ID == productCategory.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Price computes the cart totals. Items must have Product.Categories loaded
+// when the coupon is restricted to categories.
+func Price(items []models.CartItem, vipDiscount float64, coupon *models.Coupon) (Breakdown, error) {
+	subtotal := money.FromVND(0)
+	eligible := money.FromVND(0)
+	for _, item := range items {
+		line := item.Product.Price.Mul(int64(item.Quantity))
+		var err error
+		if subtotal, err = subtotal.Add(line); err != nil {
+			return Breakdown{}, err
+		}
+		if coupon != nil && appliesTo(coupon, item.Product) {
+			if eligible, err = eligible.Add(line); err != nil {
+				return Breakdown{}, err
+			}
+		}
+	}
+
+	breakdown := Breakdown{
+		Subtotal:         subtotal,
+		VIPDiscount:      subtotal.Percent(vipDiscount),
+		EligibleSubtotal: eligible,
+		CouponDiscount:   money.FromVND(0),
+	}
+
+	if coupon != nil {
+		breakdown.CouponCode = coupon.Code
+		if cmp, err := subtotal.Cmp(coupon.MinOrderValue); err != nil {
+			return breakdown, err
+		} else if cmp < 0 {
+			return breakdown, ErrMinOrderNotMet
+		}
+		if eligible.IsZero() {
+			return breakdown, ErrNotApplicable
+		}
+
+		discountedEligible, err := eligible.Sub(eligible.Percent(vipDiscount))
+		if err != nil {
+			return breakdown, err
+		}
+		discount := coupon.AmountOff
+		if coupon.Type == models.CouponTypePercentage {
+			discount = discountedEligible.Percent(coupon.PercentOff)
+			if !coupon.MaxDiscount.IsZero() {
+				if discount, err = money.Min(discount, coupon.MaxDiscount); err != nil {
+					return breakdown, err
+				}
+			}
+		}
+		if discount, err = money.Min(discount, discountedEligible); err != nil {
+			return breakdown, err
+		}
+		breakdown.CouponDiscount = money.FromVND(discount.Amount)
+	}
+
+	total, err := subtotal.Sub(breakdown.VIPDiscount)
+	if err == nil {
+		total, err = total.Sub(breakdown.CouponDiscount)
+	}
+	if err != nil {
+		return breakdown, err
+	}
+	breakdown.Total = total
+	return breakdown, nil
+}
+
+// CheckUsable validates the coupon's window and usage limits for userID.
+func CheckUsable(db *gorm.DB, coupon *models.Coupon, userID uint) error {
+	now := time.Now()
+	switch {
+	case !coupon.Active:
+		return ErrInactive
+	case coupon.StartsAt != nil && now.Before(*coupon.StartsAt):
+		return ErrNotStarted
+	case coupon.EndsAt != nil && now.After(*coupon.EndsAt):
+		return ErrExpired
+	case coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit:
+		return ErrUsageExceeded
+	}
+
+	if coupon.PerUserLimit > 0 {
+		var used int64
+		if err := db.Model(&models.CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", coupon.ID, userID).Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(coupon.PerUserLimit) {
+			return ErrUserLimit
+		}
+	}
+	return nil
+}
+
+// Redeem records the coupon use inside the checkout transaction. The coupon
+// row is locked so the global and per-user limits hold under concurrency.
+func Redeem(tx *gorm.DB, couponID, userID, orderID uint, discount money.Money) error {
+	var coupon models.Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, couponID).Error; err != nil {
+		return err
+	}
+	if err := CheckUsable(tx, &coupon, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&coupon).Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return err
+	}
+
+	redemption := models.CouponRedemption{
+		CouponID: couponID,
+		UserID:   userID,
+		OrderID:  orderID,
+		Discount: discount,
+	}
+	return tx.Create(&redemption).Error
+}
+
+// Release undoes the coupon redemptions of a cancelled order, so the code
+// counts against neither the global nor the per-user limit any more.
+func Release(tx *gorm.DB, orderID uint) error {
+	var redemptions []models.CouponRedemption
+	if err := tx.Where("order_id = ?", orderID).Find(&redemptions).Error; err != nil {
+		return err
+	}
+	for _, redemption := range redemptions {
+		if err := tx.Model(&models.Coupon{}).Where("id = ? AND used_count > 0", redemption.CouponID).
+			Update("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&redemption).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsUserError reports whether err should be shown to the customer as a 4xx.
+func IsUserError(err error) bool {
+	for _, target := range []error{ErrNotFound, ErrInactive, ErrNotStarted, ErrExpired, ErrUsageExceeded, ErrUserLimit, ErrMinOrderNotMet, ErrNotApplicable} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package coupons
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+var (
+	figures = models.Category{Name: "Figures"}
+	cards   = models.Category{Name: "Cards"}
+)
+
+func init() {
+	figures.ID, cards.ID = 1, 2
+}
+
+func cartItem(price int64, qty int, categories ...models.Category) models.CartItem {
+	return models.CartItem{Quantity: qty, Product: models.Product{Price: money.FromVND(price), Categories: categories}}
+}
+
+func TestPriceWithoutCoupon(t *testing.T) {
+	items := []models.CartItem{cartItem(500000, 2, figures), cartItem(100000, 1, cards)}
+	b, err := Price(items, 0.03, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Subtotal != money.FromVND(1100000) || b.VIPDiscount != money.FromVND(33000) || b.Total != money.FromVND(1067000) {
+		t.Errorf("unexpected breakdown %+v", b)
+	}
+}
+
+func TestPercentageCouponDiscountsVIPPriceAndIsCapped(t *testing.T) {
+	items := []models.CartItem{cartItem(1000000, 1, figures)}
+	coupon := &models.Coupon{Code: "TEN", Type: models.CouponTypePercentage, PercentOff: 0.10}
+
+	b, err := Price(items, 0.05, coupon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10% của giá đã giảm VIP (950.000), không phải của 1.000.000.
+	if b.CouponDiscount != money.FromVND(95000) || b.Total != money.FromVND(855000) {
+		t.Errorf("unexpected breakdown %+v", b)
+	}
+
+	coupon.MaxDiscount = money.FromVND(50000)
+	b, _ = Price(items, 0.05, coupon)
+	if b.CouponDiscount != money.FromVND(50000) {
+		t.Errorf("max discount not applied: %v", b.CouponDiscount)
+	}
+}
+
+func TestFixedCouponNeverExceedsEligibleItems(t *testing.T) {
+	items := []models.CartItem{cartItem(30000, 1, cards), cartItem(800000, 1, figures)}
+	coupon := &models.Coupon{Code: "CARDS", Type: models.CouponTypeFixed, AmountOff: money.FromVND(100000), Categories: []models.Category{cards}}
+
+	b, err := Price(items, 0, coupon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.EligibleSubtotal != money.FromVND(30000) || b.CouponDiscount != money.FromVND(30000) {
+		t.Errorf("unexpected breakdown %+v", b)
+	}
+}
+
+func TestCouponRules(t *testing.T) {
+	items := []models.CartItem{cartItem(200000, 1, figures)}
+
+	minimum := &models.Coupon{Type: models.CouponTypeFixed, AmountOff: money.FromVND(10000), MinOrderValue: money.FromVND(500000)}
+	if _, err := Price(items, 0, minimum); !errors.Is(err, ErrMinOrderNotMet) {
+		t.Errorf("min order: got %v", err)
+	}
+
+	other := &models.Coupon{Type: models.CouponTypeFixed, AmountOff: money.FromVND(10000), Categories: []models.Category{cards}}
+	if _, err := Price(items, 0, other); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("other category: got %v", err)
+	}
+
+	jpy := &models.Coupon{Type: models.CouponTypeFixed, AmountOff: money.FromVND(10000), MinOrderValue: money.FromJPY(100)}
+	if _, err := Price(items, 0, jpy); !errors.Is(err, money.ErrCurrencyMismatch) || IsUserError(err) {
+		t.Errorf("mismatched currency must be an internal error, got %v", err)
+	}
+}
+
+func TestCheckUsableWindowAndLimits(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	cases := map[string]struct {
+		coupon models.Coupon
+		want   error
+	}{
+		"inactive":    {models.Coupon{Active: false}, ErrInactive},
+		"not started": {models.Coupon{Active: true, StartsAt: &future}, ErrNotStarted},
+		"expired":     {models.Coupon{Active: true, EndsAt: &past}, ErrExpired},
+		"used up":     {models.Coupon{Active: true, UsageLimit: 3, UsedCount: 3}, ErrUsageExceeded},
+		"usable":      {models.Coupon{Active: true, StartsAt: &past, EndsAt: &future, UsageLimit: 3, UsedCount: 2}, nil},
+	}
+	for name, tc := range cases {
+		if err := CheckUsable(nil, &tc.coupon, 1); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", name, err, tc.want)
+		}
+	}
+}
@@ -1,47 +1,53 @@
 package database
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"time"
 
 	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/rbac"
 	"github.com/kaelCoding/toyBE/internal/utils"
 	"gorm.io/gorm"
 )
 
-func CreateInitialAdmin(db *gorm.DB) {
-	var admin models.User
-	result := db.Where("admin = ?", true).First(&admin)
+var ErrUserExists = errors.New("a user with this username or email already exists")
 
-	if result.Error == gorm.ErrRecordNotFound {
-		fmt.Println("No admin user found. Creating initial admin user...")
+// CreateAdmin creates a verified account holding the admin role. Callers are
+// expected to have validated the credentials against the password policy.
+func CreateAdmin(db *gorm.DB, username, email, password string) (*models.User, error) {
+	hash, err := utils.GenerateFromPassword(password, utils.DefaultHashParams)
+	if err != nil {
+		return nil, fmt.Errorf("hashing password: %w", err)
+	}
 
-		hash, err := utils.GenerateFromPassword("admin_password_123", &utils.HashParams{
-			Memory:      64 * 1024,
-			Iterations:  4,
-			Parallelism: 2,
-			SaltLength:  16,
-			KeyLength:   32,
-		})
-		if err != nil {
-			log.Fatalf("Failed to hash initial admin password: %v", err)
-		}
+	now := time.Now()
+	admin := models.User{
+		Username:        username,
+		Email:           email,
+		Password:        hash,
+		EmailVerifiedAt: &now,
+	}
 
-		newAdmin := models.User{
-			Username: "Admin",
-			Email:    "kael@gmail.com",
-			Password: hash,
-			Admin:    true,
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("LOWER(username) = LOWER(?) OR LOWER(email) = LOWER(?)", username, email).Count(&count).Error; err != nil {
+			return err
 		}
-
-		if err := db.Create(&newAdmin).Error; err != nil {
-			log.Fatalf("Failed to create initial admin user: %v", err)
+		if count > 0 {
+			return ErrUserExists
 		}
-
-		fmt.Println("Initial admin user created successfully.")
-	} else if result.Error != nil {
-		log.Fatalf("Database error when checking for admin user: %v", result.Error)
-	} else {
-		fmt.Println("Admin user already exists. Skipping creation.")
+		if err := tx.Create(&admin).Error; err != nil {
+			if _, ok := UniqueViolation(err); ok {
+				return ErrUserExists
+			}
+			return err
+		}
+		return rbac.SetRoles(tx, admin.ID, []string{rbac.RoleAdmin})
+	})
+	if err != nil {
+		return nil, err
 	}
-}
\ No newline at end of file
+	admin.Admin = true
+	return &admin, nil
+}
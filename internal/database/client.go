@@ -2,7 +2,6 @@ package database
 
 import (
     "log"
-    "os"
 
     "gorm.io/driver/postgres"
     "gorm.io/gorm"
@@ -14,12 +13,7 @@ func GetDB() *gorm.DB {
     return DB
 }
 
-func ConnectDB() {
-    dsn := os.Getenv("DATABASE_URL")
-    if dsn == "" {
-        log.Fatal("DATABASE_URL environment variable not set")
-    }
-
+func ConnectDB(dsn string) {
     db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
     if err != nil {
         log.Fatalf("failed to connect database, got error: %v", err)
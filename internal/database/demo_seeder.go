@@ -0,0 +1,118 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type demoProduct struct {
+	Name     string
+	Price    int64
+	Stock    int
+	Category string
+}
+
+var demoCategories = []models.Category{
+	{Name: "Figure", Description: "Mô hình nhân vật"},
+	{Name: "Gundam", Description: "Mô hình lắp ráp"},
+	{Name: "Blind box", Description: "Hộp mù"},
+}
+
+var demoProducts = []demoProduct{
+	{"Nendoroid Hatsune Miku", 1150000, 5, "Figure"},
+	{"Figma Link Tears of the Kingdom", 2350000, 3, "Figure"},
+	{"HG RX-78-2 Gundam", 390000, 12, "Gundam"},
+	{"MG Strike Freedom Gundam", 1450000, 4, "Gundam"},
+	{"Pop Mart Labubu The Monsters", 420000, 30, "Blind box"},
+	{"Sonny Angel Animal Series", 310000, 25, "Blind box"},
+}
+
+var demoRewards = []models.Reward{
+	{Name: "Chúc bạn may mắn lần sau", Quantity: -1, Probability: 60, VoucherType: models.VoucherTypeGift},
+	{Name: "Giảm 5% đơn tiếp theo", Quantity: 100, Probability: 30, VoucherType: models.VoucherTypePercentage, PercentOff: 5, MaxDiscount: money.FromVND(100000), ValidDays: 30},
+	{Name: "Giảm 50.000đ", Quantity: 20, Probability: 10, VoucherType: models.VoucherTypeFixed, AmountOff: money.FromVND(50000), ValidDays: 30},
+}
+
+// DemoCustomerEmail is the login of the customer account SeedDemoData creates.
+const DemoCustomerEmail = "demo@tunitoku.local"
+
+// SeedDemoData fills an empty development database with a few categories,
+// products, default spin rewards and a verified customer. Rows are matched by
+// name, so running it twice does not duplicate anything.
+func SeedDemoData(db *gorm.DB, customerPassword string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		categories := make(map[string]models.Category, len(demoCategories))
+		for _, c := range demoCategories {
+			category := c
+			if err := tx.Where(models.Category{Name: c.Name}).Attrs(models.Category{Description: c.Description}).FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+			categories[c.Name] = category
+		}
+
+		for _, p := range demoProducts {
+			var product models.Product
+			err := tx.Where("name = ?", p.Name).First(&product).Error
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			product = models.Product{
+				Name:        p.Name,
+				Description: "Sản phẩm mẫu",
+				Price:       money.FromVND(p.Price),
+				Stock:       p.Stock,
+				ImageURLs:   datatypes.JSON("[]"),
+				Categories:  []models.Category{categories[p.Category]},
+			}
+			if err := tx.Create(&product).Error; err != nil {
+				return err
+			}
+			initial := models.StockAdjustment{
+				ProductID:  product.ID,
+				Delta:      p.Stock,
+				StockAfter: p.Stock,
+				Reason:     models.StockReasonInitial,
+				Note:       "demo data",
+			}
+			if err := tx.Create(&initial).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, r := range demoRewards {
+			reward := r
+			if err := tx.Where("name = ? AND campaign_id IS NULL", r.Name).Attrs(r).FirstOrCreate(&reward).Error; err != nil {
+				return err
+			}
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", DemoCustomerEmail).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		hash, err := utils.GenerateFromPassword(customerPassword, utils.DefaultHashParams)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Create(&models.User{
+			Username:        "demo_customer",
+			Email:           DemoCustomerEmail,
+			Password:        hash,
+			EmailVerifiedAt: &now,
+		}).Error
+	})
+}
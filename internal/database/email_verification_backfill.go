@@ -0,0 +1,16 @@
+package database
+
+import "gorm.io/gorm"
+
+// GrandfatherEmailVerification marks accounts created before email
+// verification existed as verified. Every signup since then gets a
+// verify_email token, so users that never had one are the legacy accounts.
+func GrandfatherEmailVerification(db *gorm.DB) error {
+	return db.Exec(`
+		UPDATE users SET email_verified_at = created_at
+		WHERE email_verified_at IS NULL
+		  AND NOT EXISTS (
+		    SELECT 1 FROM user_tokens
+		    WHERE user_tokens.user_id = users.id AND user_tokens.purpose = 'verify_email'
+		  )`).Error
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+type legacyMoneyColumn struct {
+	Table    string
+	Column   string
+	Prefix   string
+	Currency string
+}
+
+// Before the money package, amounts were float64 columns (and a string for
+// product prices). AutoMigrate adds the new <prefix>minor/<prefix>currency
+// columns next to them; rows whose currency is still NULL predate the switch.
+var legacyMoneyColumns = []legacyMoneyColumn{
+	{"orders", "total_amount", "total_amount_", "VND"},
+	{"orders", "original_amount", "original_amount_", "VND"},
+	{"orders", "discount_applied", "discount_applied_", "VND"},
+	{"order_items", "price", "price_", "VND"},
+	{"users", "total_spent", "total_spent_", "VND"},
+	{"users", "maintenance_spending", "maintenance_spending_", "VND"},
+	{"proxy_orders", "product_price_jpy", "product_price_jpy_", "JPY"},
+	{"proxy_orders", "service_fee", "service_fee_", "VND"},
+	{"proxy_orders", "total_amount_vnd", "total_amount_vnd_", "VND"},
+	{"proxy_orders", "actual_price_jpy", "actual_price_jpy_", "JPY"},
+	{"proxy_orders", "domestic_shipping_jpy", "domestic_shipping_jpy_", "JPY"},
+	{"proxy_orders", "international_shipping_vnd", "international_shipping_vnd_", "VND"},
+	{"proxy_orders", "quoted_total_vnd", "quoted_total_vnd_", "VND"},
+}
+
+// productPriceExpr turns the legacy free-form price string into đồng. Plain
+// decimals are rounded, anything else ("150.000", "150,000 VNĐ") keeps its digits.
+const productPriceExpr = `COALESCE(CASE
+	WHEN price ~ '^\s*[0-9]+(\.[0-9]+)?\s*$' AND price !~ '^\s*[0-9]{1,3}(\.[0-9]{3})+\s*$' THEN ROUND(price::numeric)::bigint
+	ELSE NULLIF(regexp_replace(price, '[^0-9]', '', 'g'), '')::bigint
+END, 0)`
+
+func BackfillMoneyColumns(db *gorm.DB) error {
+	migrator := db.Migrator()
+
+	if migrator.HasColumn("products", "price") && migrator.HasColumn("products", "price_minor") {
+		result := db.Exec("UPDATE products SET price_minor = " + productPriceExpr + ", price_currency = 'VND' WHERE price_currency IS NULL")
+		if result.Error != nil {
+			return fmt.Errorf("backfilling products.price: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Backfilled %d product prices into integer VND", result.RowsAffected)
+		}
+	}
+
+	for _, col := range legacyMoneyColumns {
+		if !migrator.HasColumn(col.Table, col.Column) || !migrator.HasColumn(col.Table, col.Prefix+"minor") {
+			continue
+		}
+		sql := fmt.Sprintf("UPDATE %s SET %sminor = COALESCE(ROUND(%s::numeric), 0)::bigint, %scurrency = ? WHERE %scurrency IS NULL",
+			col.Table, col.Prefix, col.Column, col.Prefix, col.Prefix)
+		result := db.Exec(sql, col.Currency)
+		if result.Error != nil {
+			return fmt.Errorf("backfilling %s.%s: %w", col.Table, col.Column, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Backfilled %d rows of %s.%s into integer %s", result.RowsAffected, col.Table, col.Column, col.Currency)
+		}
+	}
+
+	return nil
+}
+
+// DropLegacyMoneyColumns removes the pre-money float and string columns once
+// BackfillMoneyColumns has copied them over.
+func DropLegacyMoneyColumns(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if migrator.HasColumn("products", "price") {
+		if err := migrator.DropColumn("products", "price"); err != nil {
+			return fmt.Errorf("dropping products.price: %w", err)
+		}
+	}
+	for _, col := range legacyMoneyColumns {
+		if !migrator.HasColumn(col.Table, col.Column) {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", col.Table, col.Column)).Error; err != nil {
+			return fmt.Errorf("dropping %s.%s: %w", col.Table, col.Column, err)
+		}
+	}
+	return nil
+}
+
+// RestoreLegacyMoneyColumns recreates the legacy columns from the integer
+// amounts, so an older binary can run against the database again.
+func RestoreLegacyMoneyColumns(db *gorm.DB) error {
+	if err := db.Exec("ALTER TABLE products ADD COLUMN IF NOT EXISTS price text").Error; err != nil {
+		return fmt.Errorf("restoring products.price: %w", err)
+	}
+	if err := db.Exec("UPDATE products SET price = price_minor::text").Error; err != nil {
+		return fmt.Errorf("restoring products.price: %w", err)
+	}
+	for _, col := range legacyMoneyColumns {
+		stmts := []string{
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s double precision", col.Table, col.Column),
+			fmt.Sprintf("UPDATE %s SET %s = %sminor", col.Table, col.Column, col.Prefix),
+		}
+		for _, stmt := range stmts {
+			if err := db.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("restoring %s.%s: %w", col.Table, col.Column, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/services"
+	"github.com/kaelCoding/toyBE/internal/utils"
+	"gorm.io/gorm"
+)
+
+func RefreshToken(db *gorm.DB, issuer *auth.Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+
+		tokens, err := issuer.Refresh(db, req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidRefreshToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+				return
+			}
+			log.Printf("Error refreshing token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresAt":    tokens.ExpiresAt,
+			"expiresIn":    tokens.ExpiresIn,
+		})
+	}
+}
+
+func Logout(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.LogoutRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+				return
+			}
+		}
+
+		claims, exists := c.Get("claims")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		if err := auth.Logout(db, claims.(*models.CustomJWTClaims), req.RefreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
+
+func LogoutAllDevices(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		if err := auth.LogoutAll(db, userID.(uint)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out all devices"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+	}
+}
+
+func VerifyEmail(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			token, err := auth.ConsumeUserToken(tx, req.Token, models.UserTokenVerifyEmail)
+			if err != nil {
+				return err
+			}
+			return tx.Model(&models.User{}).
+				Where("id = ? AND email_verified_at IS NULL", token.UserID).
+				Update("email_verified_at", time.Now()).Error
+		})
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidUserToken) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+	}
+}
+
+func ResendVerificationEmail(db *gorm.DB, emails *services.Emailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if user.EmailVerifiedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already verified"})
+			return
+		}
+
+		if err := emails.EnqueueVerificationEmail(db, user.ID); err != nil {
+			log.Printf("Failed to queue verification email for user %d: %v", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+	}
+}
+
+// ForgotPassword always answers the same way, and does the same work, so
+// neither the response nor its timing reveals which emails have accounts:
+// the account lookup happens later in the queued job.
+func ForgotPassword(db *gorm.DB, emails *services.Emailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+
+		if err := emails.EnqueuePasswordReset(db, req.Email); err != nil {
+			log.Printf("Failed to queue password reset email: %v", err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+	}
+}
+
+// ResetPassword sets the new password and signs the user out everywhere.
+// Following the emailed link also proves ownership of the address.
+func ResetPassword(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ResetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+
+		if msg := auth.ValidatePassword(req.NewPassword, "", ""); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": gin.H{"newPassword": msg}})
+			return
+		}
+
+		hash, err := utils.GenerateFromPassword(req.NewPassword, utils.DefaultHashParams)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+
+		// Mật khẩu mới, mở khoá và thu hồi phiên cùng commit hoặc cùng rollback.
+		err = db.Transaction(func(tx *gorm.DB) error {
+			token, err := auth.ConsumeUserToken(tx, req.Token, models.UserTokenResetPassword)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", token.UserID).Update("password", hash).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.User{}).
+				Where("id = ? AND email_verified_at IS NULL", token.UserID).
+				Update("email_verified_at", time.Now()).Error; err != nil {
+				return err
+			}
+			if err := auth.ClearFailedLogins(tx, token.UserID); err != nil {
+				return err
+			}
+			return auth.LogoutAll(tx, token.UserID)
+		})
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidUserToken) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset link"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password has been reset. Please log in again."})
+	}
+}
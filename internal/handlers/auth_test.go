@@ -0,0 +1,140 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/jobs"
+	"github.com/kaelCoding/toyBE/internal/mail"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/services"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+	"github.com/kaelCoding/toyBE/internal/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	testOutbox        = &mail.MemoryOutbox{}
+	testEmails        = services.NewEmailer(config.EmailConfig{FrontendURL: "https://shop.test"}, testOutbox)
+	registerEmailJobs sync.Once
+)
+
+// useOutbox registers the email jobs against testOutbox, once per binary, and
+// empties it for the test.
+func useOutbox(t *testing.T) *mail.MemoryOutbox {
+	t.Helper()
+	registerEmailJobs.Do(func() { services.RegisterEmailJobs(testEmails) })
+	testOutbox.Reset()
+	return testOutbox
+}
+
+func drainJobs(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	if _, err := jobs.NewWorker(db, 1).Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForgotPasswordAnswersAlikeAndMatchesEmailCaseInsensitively(t *testing.T) {
+	db := testdb.Open(t)
+	outbox := useOutbox(t)
+	user := createUser(t, db, "forgetful")
+
+	router := gin.New()
+	router.POST("/forgot", handlers.ForgotPassword(db, testEmails))
+
+	known := postJSON(router, "/forgot", models.ForgotPasswordRequest{Email: "Forgetful@Example.com"})
+	unknown := postJSON(router, "/forgot", models.ForgotPasswordRequest{Email: "nobody@example.com"})
+	if known.Code != http.StatusOK || unknown.Code != http.StatusOK || known.Body.String() != unknown.Body.String() {
+		t.Fatalf("responses differ: %d %s / %d %s", known.Code, known.Body, unknown.Code, unknown.Body)
+	}
+	if sent := outbox.Messages(); len(sent) != 0 {
+		t.Fatalf("email sent before the job ran: %v", sent)
+	}
+
+	drainJobs(t, db)
+	sent := outbox.Messages()
+	if len(sent) != 1 || sent[0].To[0] != user.Email {
+		t.Fatalf("sent %v, want one reset email to %s", sent, user.Email)
+	}
+
+	var tokens int64
+	db.Model(&models.UserToken{}).Where("user_id = ? AND purpose = ?", user.ID, models.UserTokenResetPassword).Count(&tokens)
+	if tokens != 1 {
+		t.Errorf("reset tokens = %d, want 1", tokens)
+	}
+}
+
+// Email chưa đăng ký phải bị khóa sau cùng số lần sai như tài khoản thật.
+func TestLoginLocksUnknownEmailsLikeRealOnes(t *testing.T) {
+	db := testdb.Open(t)
+	hash, err := utils.GenerateFromPassword("correct horse battery", utils.DefaultHashParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := models.User{Username: "real", Email: "real@example.com", Password: hash}
+	if err := db.Create(&known).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/login", handlers.LoginUser(db, auth.NewTokens(config.AuthConfig{JWTSecret: "test-secret"})))
+
+	attempt := func(email string) (int, string) {
+		w := postJSON(router, "/login", models.Login{Email: email, Password: "wrong password"})
+		return w.Code, w.Header().Get("Retry-After")
+	}
+	for i := 1; i <= auth.MaxFailedLogins+1; i++ {
+		realCode, realRetry := attempt("real@example.com")
+		ghostCode, ghostRetry := attempt("Ghost@example.com")
+		if realCode != ghostCode || (realRetry == "") != (ghostRetry == "") {
+			t.Fatalf("attempt %d: real %d (Retry-After %q), unknown %d (Retry-After %q)", i, realCode, realRetry, ghostCode, ghostRetry)
+		}
+		want := http.StatusUnauthorized
+		if i >= auth.MaxFailedLogins {
+			want = http.StatusTooManyRequests
+		}
+		if ghostCode != want {
+			t.Fatalf("attempt %d: got %d, want %d", i, ghostCode, want)
+		}
+	}
+}
+
+func TestResetPasswordUnlocksAndEndsEverySession(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "resetter")
+	lockedUntil := time.Now().Add(time.Hour)
+	db.Model(&user).Updates(map[string]interface{}{"failed_login_attempts": 3, "locked_until": lockedUntil})
+	session := models.RefreshToken{UserID: user.ID, TokenHash: "hash", FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.CreateUserToken(db, user.ID, models.UserTokenResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/reset-password", handlers.ResetPassword(db))
+	w := postJSON(router, "/reset-password", models.ResetPasswordRequest{Token: token, NewPassword: "N3w-passphrase!"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset: got %d: %s", w.Code, w.Body)
+	}
+
+	var saved models.User
+	db.First(&saved, user.ID)
+	if saved.FailedLoginAttempts != 0 || saved.LockedUntil != nil || saved.TokensValidAfter == nil {
+		t.Errorf("reset left attempts=%d lockedUntil=%v tokensValidAfter=%v", saved.FailedLoginAttempts, saved.LockedUntil, saved.TokensValidAfter)
+	}
+	db.First(&session, session.ID)
+	if session.RevokedAt == nil {
+		t.Error("reset must revoke existing refresh tokens")
+	}
+}
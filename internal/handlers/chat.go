@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/kaelCoding/toyBE/internal/chat"
 	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/rbac"
 	"gorm.io/gorm"
 )
 
@@ -25,8 +26,9 @@ func ChatEndpoint(hub *chat.Hub, db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		log.Printf("Setting up WebSocket for user ID: %d, IsAdmin: %v", user.ID, user.Admin)
-		chat.ServeWs(hub, c, user.ID, user.Admin)
+		isResponder := rbac.Has(contextRoles(c), rbac.PermChatRespond)
+		log.Printf("Setting up WebSocket for user ID: %d, IsResponder: %v", user.ID, isResponder)
+		chat.ServeWs(hub, c, user.ID, isResponder)
 	}
 }
 
@@ -47,7 +49,7 @@ func GetChatHistory(db *gorm.DB) gin.HandlerFunc {
 		var messages []models.Message
 		var queryUserID uint
 
-		if user.Admin {
+		if rbac.Has(contextRoles(c), rbac.PermChatRespond) {
 			otherUserID := c.Query("userId")
 			if otherUserID == "" {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "userId query parameter is required for admin"})
@@ -64,13 +66,9 @@ func GetChatHistory(db *gorm.DB) gin.HandlerFunc {
 			queryUserID = user.ID
 		}
 
-		var adminUser models.User
-		db.Where("admin = ?", true).First(&adminUser)
-		adminID := adminUser.ID
-
+		// The customer's side of the shop inbox, whichever staff member answered.
 		err := db.Preload("Sender").Preload("Receiver").
-			Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)",
-				queryUserID, adminID, adminID, queryUserID).
+			Where("sender_id = ? OR receiver_id = ?", queryUserID, queryUserID).
 			Order("timestamp asc").Find(&messages).Error
 
 		if err != nil {
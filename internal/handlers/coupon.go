@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/coupons"
+	"github.com/kaelCoding/toyBE/internal/database"
+	"github.com/kaelCoding/toyBE/internal/loyalty"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"gorm.io/gorm"
+)
+
+func ApplyCoupon(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var req models.ApplyCouponRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		var cart models.Cart
+		if err := db.Where("user_id = ?", user.ID).Preload("CartItems.Product.Categories").First(&cart).Error; err != nil || len(cart.CartItems) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+			return
+		}
+
+		coupon, err := coupons.FindByCode(db, req.Code)
+		if err == nil {
+			err = coupons.CheckUsable(db, coupon, user.ID)
+		}
+		if err != nil {
+			if coupons.IsUserError(err) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate coupon"})
+			return
+		}
+
+		breakdown, err := coupons.Price(cart.CartItems, loyalty.GetVIPLevelInfo(user.VIPLevel).Discount, coupon)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "breakdown": breakdown})
+			return
+		}
+
+		c.JSON(http.StatusOK, breakdown)
+	}
+}
+
+func GetCoupons(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var list []models.Coupon
+		if err := db.Preload("Categories").Preload("Products").Order("created_at desc").Find(&list).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve coupons"})
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+func applyCouponRequest(db *gorm.DB, coupon *models.Coupon, req models.CouponRequest) error {
+	coupon.Code = coupons.NormalizeCode(req.Code)
+	coupon.Description = req.Description
+	coupon.Type = req.Type
+	coupon.PercentOff = req.PercentOff
+	coupon.AmountOff = money.FromVND(req.AmountOff)
+	coupon.MaxDiscount = money.FromVND(req.MaxDiscount)
+	coupon.MinOrderValue = money.FromVND(req.MinOrderValue)
+	coupon.UsageLimit = req.UsageLimit
+	coupon.PerUserLimit = req.PerUserLimit
+	coupon.StartsAt = req.StartsAt
+	coupon.EndsAt = req.EndsAt
+	if req.Active != nil {
+		coupon.Active = *req.Active
+	}
+
+	coupon.Categories = nil
+	if len(req.CategoryIDs) > 0 {
+		if err := db.Find(&coupon.Categories, req.CategoryIDs).Error; err != nil {
+			return err
+		}
+		if len(coupon.Categories) != len(req.CategoryIDs) {
+			return errors.New("one or more categories not found")
+		}
+	}
+
+	coupon.Products = nil
+	if len(req.ProductIDs) > 0 {
+		if err := db.Find(&coupon.Products, req.ProductIDs).Error; err != nil {
+			return err
+		}
+		if len(coupon.Products) != len(req.ProductIDs) {
+			return errors.New("one or more products not found")
+		}
+	}
+	return nil
+}
+
+func validateCouponRequest(req models.CouponRequest) string {
+	if req.Type == models.CouponTypePercentage && req.PercentOff <= 0 {
+		return "percentOff must be between 0 and 1 for percentage coupons"
+	}
+	if req.Type == models.CouponTypeFixed && req.AmountOff <= 0 {
+		return "amountOff must be positive for fixed amount coupons"
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && req.EndsAt.Before(*req.StartsAt) {
+		return "endsAt must be after startsAt"
+	}
+	return ""
+}
+
+func AddCoupon(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CouponRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon data: " + err.Error()})
+			return
+		}
+		if msg := validateCouponRequest(req); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		coupon := models.Coupon{Active: true}
+		if err := applyCouponRequest(db, &coupon, req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&coupon).Error; err != nil {
+			if _, ok := database.UniqueViolation(err); ok {
+				c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add coupon"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Coupon added successfully", "coupon": coupon})
+	}
+}
+
+func UpdateCoupon(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		couponID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+			return
+		}
+
+		var coupon models.Coupon
+		if err := db.First(&coupon, couponID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+			return
+		}
+
+		var req models.CouponRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon data: " + err.Error()})
+			return
+		}
+		if msg := validateCouponRequest(req); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := applyCouponRequest(db, &coupon, req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit("Categories", "Products", "UsedCount").Save(&coupon).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&coupon).Association("Categories").Replace(coupon.Categories); err != nil {
+				return err
+			}
+			return tx.Model(&coupon).Association("Products").Replace(coupon.Products)
+		})
+		if err != nil {
+			if _, ok := database.UniqueViolation(err); ok {
+				c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coupon"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Coupon updated successfully", "coupon": coupon})
+	}
+}
+
+func DeleteCoupon(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		couponID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+			return
+		}
+
+		if err := db.Delete(&models.Coupon{}, uint(couponID)).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete coupon"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Coupon deleted successfully"})
+	}
+}
+
+func GetCouponRedemptions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		couponID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+			return
+		}
+
+		var redemptions []models.CouponRedemption
+		if err := db.Where("coupon_id = ?", couponID).Order("created_at desc").Find(&redemptions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve coupon redemptions"})
+			return
+		}
+		c.JSON(http.StatusOK, redemptions)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+func TestAddCoupon(t *testing.T) {
+	db := testdb.Open(t)
+	router := gin.New()
+	router.POST("/coupons", handlers.AddCoupon(db))
+	router.DELETE("/coupons/:id", handlers.DeleteCoupon(db))
+
+	inactive := false
+	req := models.CouponRequest{Code: "summer", Type: models.CouponTypeFixed, AmountOff: 50000, Active: &inactive}
+	if w := postJSON(router, "/coupons", req); w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", w.Code, w.Body)
+	}
+	var stored models.Coupon
+	db.Where("code = ?", "SUMMER").First(&stored)
+	if stored.Active {
+		t.Error("a coupon created with active=false was stored active")
+	}
+
+	if w := postJSON(router, "/coupons", req); w.Code != http.StatusConflict {
+		t.Fatalf("duplicate code: got %d, want 409", w.Code)
+	}
+
+	del := httptest.NewRequest(http.MethodDelete, "/coupons/"+itoa(stored.ID), nil)
+	router.ServeHTTP(httptest.NewRecorder(), del)
+	if w := postJSON(router, "/coupons", req); w.Code != http.StatusCreated {
+		t.Fatalf("reusing a deleted coupon's code: got %d: %s", w.Code, w.Body)
+	}
+
+	req.Code, req.Active = "DEFAULT", nil
+	postJSON(router, "/coupons", req)
+	var defaulted models.Coupon
+	db.Where("code = ?", "DEFAULT").First(&defaulted)
+	if !defaulted.Active {
+		t.Error("a coupon created without active should be active")
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/services"
+)
+
+func GetEmailTemplates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"templates": services.EmailTemplateNames()})
+	}
+}
+
+// PreviewEmailTemplate renders a template with sample data. ?format=html or
+// ?format=text returns the body as the browser would show it; the default
+// is JSON with subject, html and text.
+func PreviewEmailTemplate(emails *services.Emailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		data, err := emails.SampleEmailData(name)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Email template not found"})
+			return
+		}
+
+		rendered, err := services.RenderEmail(name, data)
+		if err != nil {
+			log.Printf("Error rendering email template %s: %v", name, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render email template"})
+			return
+		}
+
+		switch c.DefaultQuery("format", "json") {
+		case "html":
+			c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+		case "text":
+			c.String(http.StatusOK, rendered.Text)
+		case "json":
+			c.JSON(http.StatusOK, rendered)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html, text or json"})
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/rates"
+	"gorm.io/gorm"
+)
+
+func GetCurrentExchangeRate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rate, err := rates.Current(db)
+		if errors.Is(err, rates.ErrNoRate) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No exchange rate has been set"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve exchange rate"})
+			return
+		}
+		c.JSON(http.StatusOK, rate)
+	}
+}
+
+func GetExchangeRateHistory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, pageSize := parsePagination(c)
+		query := db.Model(&models.ExchangeRate{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count exchange rates"})
+			return
+		}
+
+		var history []models.ExchangeRate
+		if err := query.Order("effective_at desc, id desc").Scopes(paginate(page, pageSize)).Find(&history).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve exchange rates"})
+			return
+		}
+
+		c.JSON(http.StatusOK, paginatedResponse{Data: history, Page: page, PageSize: pageSize, Total: total})
+	}
+}
+
+func AddExchangeRate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ExchangeRateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange rate data: " + err.Error()})
+			return
+		}
+
+		feePercent := rates.DefaultServiceFeePercent
+		current, err := rates.Current(db)
+		if err != nil && !errors.Is(err, rates.ErrNoRate) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve current exchange rate"})
+			return
+		}
+		if current != nil {
+			feePercent = current.ServiceFeePercent
+		}
+
+		rate := models.ExchangeRate{
+			BaseCurrency:      "JPY",
+			QuoteCurrency:     "VND",
+			Rate:              req.Rate,
+			ServiceFeePercent: feePercent,
+			Source:            models.RateSourceManual,
+			EffectiveAt:       time.Now(),
+		}
+		if req.ServiceFeePercent != nil {
+			rate.ServiceFeePercent = *req.ServiceFeePercent
+		}
+		if req.EffectiveAt != nil {
+			rate.EffectiveAt = *req.EffectiveAt
+		}
+		if userID, exists := c.Get("userID"); exists {
+			adminID := userID.(uint)
+			rate.CreatedByID = &adminID
+		}
+
+		if err := db.Create(&rate).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add exchange rate"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Exchange rate added successfully", "rate": rate})
+	}
+}
+
+func UpdateExchangeRate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange rate ID"})
+			return
+		}
+
+		var req models.ExchangeRateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange rate data: " + err.Error()})
+			return
+		}
+
+		var rate models.ExchangeRate
+		if err := db.First(&rate, rateID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Exchange rate not found"})
+			return
+		}
+
+		// Rates that priced an order are part of its record and must not change.
+		var used int64
+		if err := db.Model(&models.ProxyOrder{}).Where("exchange_rate_id = ?", rate.ID).Count(&used).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if used > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Exchange rate is already used by proxy orders; add a new rate instead"})
+			return
+		}
+
+		rate.Rate = req.Rate
+		if req.ServiceFeePercent != nil {
+			rate.ServiceFeePercent = *req.ServiceFeePercent
+		}
+		if req.EffectiveAt != nil {
+			rate.EffectiveAt = *req.EffectiveAt
+		}
+		rate.Source = models.RateSourceManual
+
+		if err := db.Save(&rate).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exchange rate"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Exchange rate updated successfully", "rate": rate})
+	}
+}
+
+func DeleteExchangeRate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exchange rate ID"})
+			return
+		}
+
+		var used int64
+		if err := db.Model(&models.ProxyOrder{}).Where("exchange_rate_id = ?", rateID).Count(&used).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if used > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Exchange rate is already used by proxy orders"})
+			return
+		}
+
+		if err := db.Delete(&models.ExchangeRate{}, uint(rateID)).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exchange rate"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Exchange rate deleted successfully"})
+	}
+}
+
+func RefreshExchangeRate(db *gorm.DB, provider rates.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if provider == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "No exchange rate provider is configured"})
+			return
+		}
+
+		rate, err := rates.Refresh(c.Request.Context(), db, provider)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to refresh exchange rate: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Exchange rate refreshed successfully", "rate": rate})
+	}
+}
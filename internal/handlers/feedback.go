@@ -8,18 +8,20 @@ import (
     "github.com/kaelCoding/toyBE/internal/services" 
 )
 
-func SendFeedbackHandler(c *gin.Context) {
-    var feedback models.Feedback
+func SendFeedbackHandler(emails *services.Emailer) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var feedback models.Feedback
 
-    if err := c.ShouldBindJSON(&feedback); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feedback data: " + err.Error()})
-        return
-    }
+        if err := c.ShouldBindJSON(&feedback); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feedback data: " + err.Error()})
+            return
+        }
 
-    if err := services.SendFeedbackEmail(feedback); err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send feedback email: " + err.Error()})
-        return
-    }
+        if err := emails.SendFeedbackEmail(c.Request.Context(), feedback); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send feedback email: " + err.Error()})
+            return
+        }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Feedback received successfully and email sent."})
+        c.JSON(http.StatusOK, gin.H{"message": "Feedback received successfully and email sent."})
+    }
 }
\ No newline at end of file
@@ -0,0 +1,58 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/ratelimit"
+	"gorm.io/gorm"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// asUser stands in for AuthMiddleware.
+func asUser(userID uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("userID", userID)
+		c.Next()
+	}
+}
+
+func createUser(t *testing.T, db *gorm.DB, name string) models.User {
+	t.Helper()
+	user := models.User{Username: name, Email: name + "@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func postJSON(router http.Handler, path string, body any) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// spinOrder allows three wrong spin tokens, with a fresh limiter per call.
+func spinOrder(db *gorm.DB) gin.HandlerFunc {
+	return handlers.SpinOrder(db, ratelimit.NewMemoryStore(), ratelimit.Policy{
+		Name: "spin-failures", Limit: ratelimit.Limit{Requests: 3, Per: time.Hour}, Key: ratelimit.ByUser,
+	})
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/inventory"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+)
+
+func AdjustProductStock(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var req models.AdjustStockRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		if !inventory.ManualReasons[req.Reason] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason code. Allowed: restock, return, damaged, correction"})
+			return
+		}
+
+		var adminID *uint
+		if id, exists := c.Get("userID"); exists {
+			uid := id.(uint)
+			adminID = &uid
+		}
+
+		var adjustment *models.StockAdjustment
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			adjustment, err = inventory.AdjustStock(tx, uint(productID), req.Delta, req.Reason, req.Note, adminID)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+				return
+			}
+			if errors.Is(err, inventory.ErrInsufficientStock) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Stock cannot go below zero"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust stock"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock adjusted successfully", "adjustment": adjustment})
+	}
+}
+
+func GetStockAdjustments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var adjustments []models.StockAdjustment
+		if err := db.Where("product_id = ?", productID).Order("created_at desc").Find(&adjustments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, adjustments)
+	}
+}
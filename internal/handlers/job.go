@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/jobs"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+)
+
+func GetJobs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, pageSize := parsePagination(c)
+		query := db.Model(&models.Job{})
+		if statuses := statusFilter(c); len(statuses) > 0 {
+			query = query.Where("status IN ?", statuses)
+		}
+		if jobType := c.Query("type"); jobType != "" {
+			query = query.Where("type = ?", jobType)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count jobs"})
+			return
+		}
+
+		var list []models.Job
+		if err := query.Order("id desc").Scopes(paginate(page, pageSize)).Find(&list).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, paginatedResponse{Data: list, Page: page, PageSize: pageSize, Total: total})
+	}
+}
+
+func GetJobSummary(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rows []struct {
+			Status string `json:"status"`
+			Count  int64  `json:"count"`
+		}
+		if err := db.Model(&models.Job{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize jobs"})
+			return
+		}
+
+		summary := gin.H{
+			models.JobStatusPending:   int64(0),
+			models.JobStatusRunning:   int64(0),
+			models.JobStatusSucceeded: int64(0),
+			models.JobStatusDead:      int64(0),
+		}
+		for _, row := range rows {
+			summary[row.Status] = row.Count
+		}
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+func GetJobByID(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+			return
+		}
+
+		var job models.Job
+		if err := db.First(&job, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job"})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+func RetryJob(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+			return
+		}
+
+		job, err := jobs.Retry(db, uint(id))
+		if err != nil {
+			switch {
+			case errors.Is(err, jobs.ErrNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			case errors.Is(err, jobs.ErrNotRetryable):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Job queued for retry", "job": job})
+	}
+}
@@ -1,18 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"math/rand"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/kaelCoding/toyBE/internal/database"
+	"github.com/kaelCoding/toyBE/internal/coupons"
+	"github.com/kaelCoding/toyBE/internal/inventory"
 	"github.com/kaelCoding/toyBE/internal/loyalty"
 	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/orders"
+	"github.com/kaelCoding/toyBE/internal/payments"
 	"github.com/kaelCoding/toyBE/internal/services"
+	"github.com/kaelCoding/toyBE/internal/vouchers"
 )
 
 func generateShippingCode() string {
@@ -36,126 +43,365 @@ func GetAllOrders(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func CreateOrderFromCart(c *gin.Context) {
-	var req models.CartCheckoutRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
-		return
-	}
+func GetMyOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
 
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
+		page, pageSize := parsePagination(c)
+		query := db.Model(&models.Order{}).Where("user_id = ?", userID)
+		if statuses := statusFilter(c); len(statuses) > 0 {
+			query = query.Where("status IN ?", statuses)
+		}
 
-	db := database.GetDB()
-	var user models.User
-	if err := db.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-	
-	var cart models.Cart
-	if err := db.Where("user_id = ?", userID).Preload("CartItems.Product").First(&cart).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
-		return
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count orders"})
+			return
+		}
+
+		var userOrders []models.Order
+		if err := query.Preload("OrderItems.Product").Order("created_at desc").Scopes(paginate(page, pageSize)).Find(&userOrders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+			return
+		}
+
+		c.JSON(http.StatusOK, paginatedResponse{Data: userOrders, Page: page, PageSize: pageSize, Total: total})
 	}
+}
+
+func GetMyOrderByID(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
 
-	if len(cart.CartItems) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
-		return
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var order models.Order
+		if err := db.Preload("OrderItems.Product").Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+			return
+		}
+
+		c.JSON(http.StatusOK, order)
 	}
+}
 
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			log.Printf("Recovered from panic: %v", r)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal error occurred"})
-		}
-	}()
-
-	var orderItems []models.OrderItem
-	var originalAmount float64 = 0
-
-	for _, item := range cart.CartItems {
-		price, _ := strconv.ParseFloat(item.Product.Price, 64)
-		itemTotal := price * float64(item.Quantity)
-		originalAmount += itemTotal
-		
-		orderItems = append(orderItems, models.OrderItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     price,
+func UpdateOrderStatus(db *gorm.DB, registry payments.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req models.UpdateOrderStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		if !orders.IsValidStatus(req.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown order status: " + req.Status})
+			return
+		}
+
+		var changedBy *uint
+		if id, exists := c.Get("userID"); exists {
+			uid := id.(uint)
+			changedBy = &uid
+		}
+
+		var order *models.Order
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if req.Status == models.OrderStatusPaid {
+				// Khóa đơn trước khi kiểm tra để cổng thanh toán không xác nhận song song.
+				var existing models.Order
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "payment_method").First(&existing, orderID).Error; err != nil {
+					return err
+				}
+				if registry.IsGatewayMethod(existing.PaymentMethod) {
+					return errGatewayPaymentOnly
+				}
+			}
+
+			var err error
+			order, err = orders.Transition(tx, uint(orderID), req.Status, changedBy, req.Note)
+			return err
 		})
-	}
+		if errors.Is(err, errGatewayPaymentOnly) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Online payments are marked paid only by the payment gateway confirmation"})
+			return
+		}
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+				return
+			}
+			if errors.Is(err, orders.ErrInvalidTransition) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":   "Cannot move order from " + order.Status + " to " + req.Status,
+					"allowed": orders.AllowedTransitions(order.Status),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+			return
+		}
 
-	vipInfo := loyalty.GetVIPLevelInfo(user.VIPLevel)
-	discountAmount := originalAmount * vipInfo.Discount
-	finalAmount := originalAmount - discountAmount
-
-	shippingCode := generateShippingCode()
-
-	order := models.Order{
-		UserID:          user.ID,
-		OriginalAmount:  originalAmount,
-		DiscountApplied: discountAmount,
-		TotalAmount:     finalAmount,
-		Status:          "completed", 
-		CustomerName:    req.CustomerName, 
-		CustomerPhone:   req.CustomerPhone,
-		CustomerAddress: req.CustomerAddress,
-		CustomerEmail:   user.Email, 
-		PaymentMethod:   req.PaymentMethod,
-		OrderItems:      orderItems,
-		ShippingCode:    shippingCode,
+		c.JSON(http.StatusOK, gin.H{"message": "Order status updated successfully", "order": order})
 	}
+}
 
-	if err := tx.Create(&order).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
-		return
-	}
+func GetOrderStatusHistory(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
 
-	if err := loyalty.UpdateUserLoyaltyStatus(tx, user.ID, order.TotalAmount); err != nil {
-		tx.Rollback()
-		log.Printf("Failed to update loyalty status for user %d: %v", user.ID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update loyalty status"})
-		return
-	}
+		var history []models.OrderStatusHistory
+		if err := db.Where("order_id = ?", orderID).Order("created_at asc").Find(&history).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order status history"})
+			return
+		}
 
-	if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear cart"})
-		return
+		c.JSON(http.StatusOK, history)
 	}
+}
+
+func CreateOrderFromCart(db *gorm.DB, registry payments.Registry, emails *services.Emailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CartCheckoutRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			return
+		}
+		req.PaymentMethod = payments.NormalizeMethod(req.PaymentMethod)
+		if !registry.IsValidMethod(req.PaymentMethod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported payment method: " + req.PaymentMethod})
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if user.EmailVerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address before checking out", "code": "email_not_verified"})
+			return
+		}
 	
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
-	}
+		var cart models.Cart
+		if err := db.Where("user_id = ?", userID).Preload("CartItems.Product.Categories").First(&cart).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+			return
+		}
+
+		if len(cart.CartItems) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+			return
+		}
+
+		var coupon *models.Coupon
+		if req.CouponCode != "" {
+			var err error
+			coupon, err = coupons.FindByCode(db, req.CouponCode)
+			if err == nil {
+				err = coupons.CheckUsable(db, coupon, user.ID)
+			}
+			if err != nil {
+				if coupons.IsUserError(err) {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate coupon"})
+				return
+			}
+		}
+
+		vipInfo := loyalty.GetVIPLevelInfo(user.VIPLevel)
+		totals, err := coupons.Price(cart.CartItems, vipInfo.Discount, coupon)
+		if err != nil {
+			if coupons.IsUserError(err) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Pricing cart %d failed: %v", cart.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price cart"})
+			return
+		}
+
+		var voucher *models.UserVoucher
+		voucherDiscount := money.FromVND(0)
+		if req.VoucherCode != "" {
+			var err error
+			voucher, err = vouchers.FindUsable(db, user.ID, req.VoucherCode)
+			if err != nil {
+				if errors.Is(err, vouchers.ErrNotFound) || errors.Is(err, vouchers.ErrNotUsable) {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate voucher"})
+				return
+			}
+			if voucherDiscount, err = vouchers.Discount(voucher, totals.Total); err != nil {
+				log.Printf("Pricing voucher %s failed: %v", voucher.Code, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply voucher"})
+				return
+			}
+		}
+		totalAmount, err := totals.Total.Sub(voucherDiscount)
+		if err != nil {
+			log.Printf("Pricing cart %d failed: %v", cart.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price cart"})
+			return
+		}
+
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+				log.Printf("Recovered from panic: %v", r)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "An internal error occurred"})
+			}
+		}()
+
+		var orderItems []models.OrderItem
+		for _, item := range cart.CartItems {
+			orderItems = append(orderItems, models.OrderItem{
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+				Price:     item.Product.Price,
+			})
+		}
+
+		shippingCode := generateShippingCode()
+
+		order := models.Order{
+			UserID:          user.ID,
+			OriginalAmount:  totals.Subtotal,
+			DiscountApplied: totals.VIPDiscount,
+			CouponDiscount:  totals.CouponDiscount,
+			VoucherDiscount: voucherDiscount,
+			TotalAmount:     totalAmount,
+			Status:          models.OrderStatusPendingPayment,
+			CustomerName:    req.CustomerName, 
+			CustomerPhone:   req.CustomerPhone,
+			CustomerAddress: req.CustomerAddress,
+			CustomerEmail:   user.Email, 
+			PaymentMethod:   req.PaymentMethod,
+			OrderItems:      orderItems,
+			ShippingCode:    shippingCode,
+		}
+		if err := orders.SetAmountDue(&order, user.VIPLevel); err != nil {
+			log.Printf("Pricing cart %d failed: %v", cart.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price cart"})
+			return
+		}
+		if coupon != nil {
+			order.CouponID = &coupon.ID
+			order.CouponCode = coupon.Code
+		}
+		if voucher != nil {
+			order.VoucherID = &voucher.ID
+			order.VoucherCode = voucher.Code
+		}
 
-	go func() {
-		var fullOrder models.Order
-		if err := db.Preload("User").Preload("OrderItems.Product").First(&fullOrder, order.ID).Error; err != nil {
-			log.Printf("Error fetching full order details for email (OrderID: %d): %v", order.ID, err)
+		if err := tx.Create(&order).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order: " + err.Error()})
 			return
 		}
-		
-		if err := services.SendOrderConfirmationEmail(fullOrder); err != nil {
-			log.Printf("Failed to send order confirmation email to admin (OrderID: %d): %v", fullOrder.ID, err)
+
+		if err := orders.RecordInitialStatus(tx, &order, &user.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record order status"})
+			return
 		}
-		if fullOrder.CustomerEmail != "" {
-			if err := services.SendInvoiceToCustomer(fullOrder, fullOrder.CustomerEmail); err != nil {
-				log.Printf("Failed to send invoice email to customer (OrderID: %d): %v", fullOrder.ID, err)
+
+		if coupon != nil {
+			if err := coupons.Redeem(tx, coupon.ID, user.ID, order.ID, totals.CouponDiscount); err != nil {
+				tx.Rollback()
+				if coupons.IsUserError(err) {
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem coupon"})
+				return
+			}
+		}
+
+		if voucher != nil {
+			if err := vouchers.Redeem(tx, voucher.ID, user.ID, order.ID); err != nil {
+				tx.Rollback()
+				if errors.Is(err, vouchers.ErrNotUsable) {
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+					return
+				}
+				if errors.Is(err, vouchers.ErrNotFound) {
+					c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem voucher"})
+				return
 			}
 		}
-	}()
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Order created successfully from cart. Confirmation emails are being sent.",
-		"order":   order,
-	})
-}
\ No newline at end of file
+		if outOfStock, err := inventory.ReserveCartItems(tx, cart.CartItems, order.ID); err != nil {
+			tx.Rollback()
+			if errors.Is(err, inventory.ErrInsufficientStock) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Some items in your cart are out of stock", "items": outOfStock})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve stock"})
+			return
+		}
+
+		if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear cart"})
+			return
+		}
+
+		if err := emails.EnqueueOrderEmails(tx, order); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue confirmation emails"})
+			return
+		}
+	
+		if err := tx.Commit().Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Order created successfully from cart. Confirmation emails are being sent.",
+			"order":   order,
+		})
+	}
+}
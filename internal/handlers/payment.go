@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/payments"
+	"gorm.io/gorm"
+)
+
+func CreateOrderPayment(db *gorm.DB, registry payments.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+			return
+		}
+
+		var req models.CreatePaymentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		provider, err := registry.Get(req.Provider)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported payment provider"})
+			return
+		}
+
+		var order models.Order
+		if err := db.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+
+		if payments.NormalizeMethod(order.PaymentMethod) != provider.Name() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order was placed with payment method " + order.PaymentMethod})
+			return
+		}
+
+		if order.Status != models.OrderStatusPendingPayment {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order is not awaiting payment"})
+			return
+		}
+
+		payment, err := payments.NewPayment(db, provider, order.UserID, &order.ID, nil, order.AmountDue)
+		if errors.Is(err, payments.ErrPaymentPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another payment for this order is still in progress"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+			return
+		}
+
+		paymentURL, err := provider.RedirectURL(c.Request.Context(), payment, fmt.Sprintf("Thanh toan don hang %d", order.ID), c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create payment URL"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"payment": payment, "paymentUrl": paymentURL})
+	}
+}
+
+func CreateProxyOrderPayment(db *gorm.DB, registry payments.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy order ID"})
+			return
+		}
+
+		var req models.CreatePaymentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		provider, err := registry.Get(req.Provider)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported payment provider"})
+			return
+		}
+
+		var proxyOrder models.ProxyOrder
+		if err := db.Where("id = ? AND user_id = ?", orderID, userID).First(&proxyOrder).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Proxy order not found"})
+			return
+		}
+
+		if payments.NormalizeMethod(proxyOrder.PaymentMethod) != provider.Name() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Order was placed with payment method " + proxyOrder.PaymentMethod})
+			return
+		}
+
+		if proxyOrder.Status != models.ProxyStatusQuoted {
+			c.JSON(http.StatusConflict, gin.H{"error": "Proxy order can only be paid after it has been quoted"})
+			return
+		}
+
+		payment, err := payments.NewPayment(db, provider, proxyOrder.UserID, nil, &proxyOrder.ID, proxyOrder.QuotedTotalVND)
+		if errors.Is(err, payments.ErrPaymentPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another payment for this order is still in progress"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment"})
+			return
+		}
+
+		paymentURL, err := provider.RedirectURL(c.Request.Context(), payment, fmt.Sprintf("Thanh toan don dat ho %d", proxyOrder.ID), c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to create payment URL"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"payment": payment, "paymentUrl": paymentURL})
+	}
+}
+
+// PaymentIPN receives the gateway's server-to-server confirmation. Responses
+// follow the VNPay convention: HTTP 200 with an RspCode the gateway acts on.
+func PaymentIPN(db *gorm.DB, registry payments.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, err := registry.Get(c.Param("provider"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"RspCode": "99", "Message": "Unknown provider"})
+			return
+		}
+
+		if err := c.Request.ParseForm(); err != nil {
+			c.JSON(http.StatusOK, gin.H{"RspCode": "99", "Message": "Invalid request"})
+			return
+		}
+
+		payment, err := payments.HandleCallback(db, provider, c.Request.Form)
+		switch {
+		case errors.Is(err, payments.ErrInvalidSignature):
+			c.JSON(http.StatusOK, gin.H{"RspCode": "97", "Message": "Invalid signature"})
+		case errors.Is(err, payments.ErrPaymentNotFound):
+			c.JSON(http.StatusOK, gin.H{"RspCode": "01", "Message": "Order not found"})
+		case errors.Is(err, payments.ErrAmountMismatch):
+			c.JSON(http.StatusOK, gin.H{"RspCode": "04", "Message": "Invalid amount"})
+		case errors.Is(err, payments.ErrAlreadyConfirmed):
+			c.JSON(http.StatusOK, gin.H{"RspCode": "02", "Message": "Order already confirmed"})
+		case err != nil:
+			log.Printf("Failed to process %s payment callback: %v", provider.Name(), err)
+			c.JSON(http.StatusOK, gin.H{"RspCode": "99", "Message": "Unknown error"})
+		default:
+			log.Printf("Payment %s via %s is %s", payment.TxnRef, provider.Name(), payment.Status)
+			c.JSON(http.StatusOK, gin.H{"RspCode": "00", "Message": "Confirm Success"})
+		}
+	}
+}
+
+func GetAllPayments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, pageSize := parsePagination(c)
+		query := db.Model(&models.Payment{})
+		if statuses := statusFilter(c); len(statuses) > 0 {
+			query = query.Where("status IN ?", statuses)
+		}
+		if orderID := c.Query("orderId"); orderID != "" {
+			query = query.Where("order_id = ?", orderID)
+		}
+		if proxyOrderID := c.Query("proxyOrderId"); proxyOrderID != "" {
+			query = query.Where("proxy_order_id = ?", proxyOrderID)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count payments"})
+			return
+		}
+
+		var list []models.Payment
+		if err := query.Order("created_at desc").Scopes(paginate(page, pageSize)).Find(&list).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments"})
+			return
+		}
+
+		c.JSON(http.StatusOK, paginatedResponse{Data: list, Page: page, PageSize: pageSize, Total: total})
+	}
+}
@@ -0,0 +1,109 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/payments"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+func TestCheckoutRejectsUnknownPaymentMethod(t *testing.T) {
+	router := gin.New()
+	router.POST("/cart/checkout", asUser(1), handlers.CreateOrderFromCart(nil, payments.Registry{}, nil))
+
+	w := postJSON(router, "/cart/checkout", models.CartCheckoutRequest{
+		CustomerName: "A", CustomerPhone: "0901234567", CustomerAddress: "HCM", PaymentMethod: "bitcoin",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400", w.Code)
+	}
+}
+
+// Số tiền thanh toán là số đã chốt lúc đặt hàng, và cổng phải khớp phương thức của đơn.
+func TestCreateOrderPaymentChargesStoredAmountDue(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "amountdue")
+	order := models.Order{
+		UserID: user.ID, TotalAmount: money.FromVND(300000), ShippingFee: money.FromVND(50000), AmountDue: money.FromVND(350000),
+		Status: models.OrderStatusPendingPayment, PaymentMethod: "fake",
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Lên VIP sau khi đặt hàng không làm đổi số tiền phải trả.
+	db.Model(&user).Update("VIPLevel", 3)
+
+	registry := payments.Registry{"fake": payments.NewFakeProvider("secret", ""), "vnpay": &payments.VNPayProvider{}}
+	router := gin.New()
+	router.POST("/orders/:id/payments", asUser(user.ID), handlers.CreateOrderPayment(db, registry))
+
+	if w := postJSON(router, "/orders/"+itoa(order.ID)+"/payments", models.CreatePaymentRequest{Provider: "vnpay"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("provider not matching the order: got %d, want 400", w.Code)
+	}
+
+	w := postJSON(router, "/orders/"+itoa(order.ID)+"/payments", models.CreatePaymentRequest{Provider: "fake"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create payment: got %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Payment models.Payment `json:"payment"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.Payment.Amount != money.FromVND(350000) {
+		t.Errorf("charged %v, want the stored amount due", body.Payment.Amount)
+	}
+}
+
+func TestPaymentIPNRepliesAlreadyConfirmedOnRetry(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "ipn")
+	order := models.Order{UserID: user.ID, TotalAmount: money.FromVND(300000), Status: models.OrderStatusPendingPayment, PaymentMethod: "fake"}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	fake := payments.NewFakeProvider("secret", "")
+	registry := payments.Registry{"fake": fake}
+	payment, err := payments.NewPayment(db, fake, user.ID, &order.ID, nil, money.FromVND(350000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/payments/:provider/ipn", handlers.PaymentIPN(db, registry))
+	router.PUT("/orders/:id/status", handlers.UpdateOrderStatus(db, registry))
+
+	ipn := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/payments/fake/ipn?"+fake.SignedCallback(payment, true).Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var body struct{ RspCode string }
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return body.RspCode
+	}
+	if code := ipn(); code != "00" {
+		t.Fatalf("first callback: RspCode %s, want 00", code)
+	}
+	if code := ipn(); code != "02" {
+		t.Fatalf("repeated callback: RspCode %s, want 02", code)
+	}
+
+	// Đơn thanh toán qua cổng không được đánh dấu "paid" thủ công.
+	other := models.Order{UserID: user.ID, TotalAmount: money.FromVND(300000), Status: models.OrderStatusPendingPayment, PaymentMethod: "fake"}
+	db.Create(&other)
+	req := httptest.NewRequest(http.MethodPut, "/orders/"+itoa(other.ID)+"/status", strings.NewReader(`{"status":"paid"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("manual paid on gateway order: got %d, want 409", w.Code)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/kaelCoding/toyBE/internal/database"
 	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
 	"github.com/kaelCoding/toyBE/internal/pkg/r2"
 	"gorm.io/gorm"
 )
@@ -33,6 +34,7 @@ func createProductResponse(db *gorm.DB, product *models.Product) (map[string]int
         "name":          product.Name,
         "description":   product.Description,
         "price":         product.Price,
+        "stock":         product.Stock,
         "categories":    product.Categories, // Trả về mảng categories
         "image_urls":    imageURLs,         
         "CreatedAt":     product.CreatedAt,
@@ -63,6 +65,21 @@ func AddProduct(c *gin.Context) {
         return
     }
 
+    parsedPrice, err := money.Parse(price, money.VND)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Price must be a whole number of VND"})
+        return
+    }
+
+    stock := 0
+    if stockStr := c.PostForm("stock"); stockStr != "" {
+        stock, err = strconv.Atoi(stockStr)
+        if err != nil || stock < 0 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Stock must be a non-negative integer"})
+            return
+        }
+    }
+
     // ... (logic xử lý file ảnh giữ nguyên) ...
     form, err := c.MultipartForm()
     if err != nil {
@@ -123,7 +140,8 @@ func AddProduct(c *gin.Context) {
     product := models.Product{
         Name:        name,
         Description: description,
-        Price:       price,
+        Price:       parsedPrice,
+        Stock:       stock,
         ImageURLs:   imageURLsJSON,
     }
 
@@ -132,6 +150,23 @@ func AddProduct(c *gin.Context) {
         return
     }
 
+    if stock > 0 {
+        initial := models.StockAdjustment{
+            ProductID:  product.ID,
+            Delta:      stock,
+            StockAfter: stock,
+            Reason:     models.StockReasonInitial,
+        }
+        if userID, exists := c.Get("userID"); exists {
+            adminID := userID.(uint)
+            initial.AdminID = &adminID
+        }
+        if err := db.Create(&initial).Error; err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record initial stock: " + err.Error()})
+            return
+        }
+    }
+
     // Gán categories cho sản phẩm
     if err := db.Model(&product).Association("Categories").Append(&categories); err != nil {
          c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to associate categories: " + err.Error()})
@@ -250,7 +285,12 @@ func UpdateProduct(c *gin.Context) {
 
     existingProduct.Name = c.PostForm("name")
     existingProduct.Description = c.PostForm("description")
-    existingProduct.Price = c.PostForm("price")
+    price, err := money.Parse(c.PostForm("price"), money.VND)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Price must be a whole number of VND"})
+        return
+    }
+    existingProduct.Price = price
 
     // THAY ĐỔI: Nhận mảng category IDs
     categoryIDsStr := c.PostFormArray("category_ids")
@@ -303,7 +343,7 @@ func UpdateProduct(c *gin.Context) {
     }
 
     // Lưu các trường product cơ bản
-    if err := db.Save(&existingProduct).Error; err != nil {
+    if err := db.Omit("Stock").Save(&existingProduct).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
         return
     }
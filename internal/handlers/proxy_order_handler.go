@@ -2,21 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/orders"
+	"github.com/kaelCoding/toyBE/internal/payments"
+	"github.com/kaelCoding/toyBE/internal/rates"
 	"github.com/kaelCoding/toyBE/internal/services"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// Khai báo hằng số tỷ giá và phí dịch vụ
-const (
-	mercariExchangeRate     = 185.0 // Tỷ giá mới: 1 JPY = 185 VND
-	mercariServiceFeePercent = 0.05  // Phí dịch vụ 5%
-)
+// errGatewayPaymentOnly rejects marking an order paid by hand when its
+// payment method is one the gateway confirms.
+var errGatewayPaymentOnly = errors.New("gateway payments are confirmed by the gateway only")
 
 type CreateProxyOrderRequest struct {
 	MercariURL         string   `json:"mercariURL" binding:"required"`
@@ -38,13 +43,18 @@ type CreateProxyOrderRequest struct {
 	// TotalAmountVND     float64  `json:"totalAmountVND"`
 }
 
-func CreateProxyOrder(db *gorm.DB) gin.HandlerFunc {
+func CreateProxyOrder(db *gorm.DB, registry payments.Registry, emails *services.Emailer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req CreateProxyOrderRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
 			return
 		}
+		req.PaymentMethod = payments.NormalizeMethod(req.PaymentMethod)
+		if !registry.IsValidMethod(req.PaymentMethod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported payment method: " + req.PaymentMethod})
+			return
+		}
 
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -64,15 +74,31 @@ func CreateProxyOrder(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Tỷ giá và phí dịch vụ lấy từ bảng exchange_rates, đơn hàng lưu lại ID tỷ giá đã dùng
+		rate, err := rates.Current(db)
+		if errors.Is(err, rates.ErrNoRate) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Proxy ordering is unavailable until an exchange rate is set"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve exchange rate"})
+			return
+		}
+
 		// --- LOGIC TÍNH GIÁ MỚI TẠI BACKEND ---
 		// 1. Tính giá cơ bản (quy đổi)
-		basePriceVND := req.ProductPriceJPY * mercariExchangeRate
-		// 2. Tính phí dịch vụ (5% của giá cơ bản)
-		serviceFeeVND := basePriceVND * mercariServiceFeePercent
+		priceJPY := money.FromFloat(req.ProductPriceJPY, money.JPY)
+		basePriceVND := priceJPY.Convert(rate.Rate, money.VND)
+		// 2. Tính phí dịch vụ (theo % của giá cơ bản)
+		serviceFeeVND := basePriceVND.Percent(rate.ServiceFeePercent)
 		// 3. Tính tổng tiền cho 1 sản phẩm (chưa ship)
-		singleItemTotalVND := basePriceVND + serviceFeeVND
+		singleItemTotalVND, err := basePriceVND.Add(serviceFeeVND)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price proxy order"})
+			return
+		}
 		// 4. Tính tổng tiền cuối cùng (theo số lượng)
-		finalTotalAmountVND := singleItemTotalVND * float64(req.Quantity)
+		finalTotalAmountVND := singleItemTotalVND.Mul(int64(req.Quantity))
 		// --- KẾT THÚC LOGIC TÍNH GIÁ ---
 
 		proxyOrder := models.ProxyOrder{
@@ -80,7 +106,7 @@ func CreateProxyOrder(db *gorm.DB) gin.HandlerFunc {
 			MercariURL:         req.MercariURL,
 			MercariItemID:      req.MercariItemID,
 			ProductName:        req.ProductName,
-			ProductPriceJPY:    req.ProductPriceJPY,
+			ProductPriceJPY:    priceJPY,
 			ProductCondition:   req.ProductCondition,
 			ProductDescription: req.ProductDescription,
 			ImageURLs:          datatypes.JSON(imageURLsJSON),
@@ -89,37 +115,316 @@ func CreateProxyOrder(db *gorm.DB) gin.HandlerFunc {
 			CustomerAddress:    req.CustomerAddress,
 			CustomerEmail:      req.CustomerEmail,
 			// Lưu trữ các giá trị đã tính toán
-			ExchangeRate:       mercariExchangeRate,
+			ExchangeRate:       rate.Rate,
+			ExchangeRateID:     &rate.ID,
+			ServiceFeePercent:  rate.ServiceFeePercent,
 			ServiceFee:         serviceFeeVND, // Phí dịch vụ cho 1 sản phẩm
 			TotalAmountVND:     finalTotalAmountVND, // Tổng tiền cuối cùng
-			Status:             "pending_quote",
+			Status:             models.ProxyStatusPendingQuote,
 			Quantity:           req.Quantity,
 			PaymentMethod:      req.PaymentMethod,
 		}
 
-		if err := db.Create(&proxyOrder).Error; err != nil {
+		// Email được đưa vào hàng đợi cùng transaction với đơn hàng.
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&proxyOrder).Error; err != nil {
+				return err
+			}
+			return emails.EnqueueProxyOrderEmails(tx, proxyOrder)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create proxy order"})
 			return
 		}
 
-		go func() {
-			db.Preload("User").First(&proxyOrder, proxyOrder.ID)
-			
-			// Gọi hàm email mới
-			if err := services.SendProxyOrderConfirmationEmail(proxyOrder); err != nil {
-				log.Printf("Failed to send proxy order confirmation email to admin (OrderID: %d): %v", proxyOrder.ID, err)
-			}
-			if proxyOrder.CustomerEmail != "" {
-				// Gọi hàm email mới
-				if err := services.SendProxyInvoiceToCustomer(proxyOrder, proxyOrder.CustomerEmail); err != nil {
-					log.Printf("Failed to send proxy invoice email to customer (OrderID: %d): %v", proxyOrder.ID, err)
-				}
-			}
-		}()
-
 		c.JSON(http.StatusCreated, gin.H{
 			"message": "Proxy order created successfully. Confirmation emails are being sent.",
 			"order":   proxyOrder,
 		})
 	}
-}
\ No newline at end of file
+}
+
+func GetMyProxyOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		page, pageSize := parsePagination(c)
+		query := db.Model(&models.ProxyOrder{}).Where("user_id = ?", userID)
+		if statuses := statusFilter(c); len(statuses) > 0 {
+			query = query.Where("status IN ?", statuses)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count proxy orders"})
+			return
+		}
+
+		var proxyOrders []models.ProxyOrder
+		if err := query.Order("created_at desc").Scopes(paginate(page, pageSize)).Find(&proxyOrders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve proxy orders"})
+			return
+		}
+
+		c.JSON(http.StatusOK, paginatedResponse{Data: proxyOrders, Page: page, PageSize: pageSize, Total: total})
+	}
+}
+
+func GetMyProxyOrderByID(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy order ID"})
+			return
+		}
+
+		var proxyOrder models.ProxyOrder
+		if err := db.Where("id = ? AND user_id = ?", orderID, userID).First(&proxyOrder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Proxy order not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve proxy order"})
+			return
+		}
+
+		c.JSON(http.StatusOK, proxyOrder)
+	}
+}
+
+func GetAllProxyOrders(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, pageSize := parsePagination(c)
+		query := db.Model(&models.ProxyOrder{})
+		if statuses := statusFilter(c); len(statuses) > 0 {
+			query = query.Where("status IN ?", statuses)
+		}
+		if userID := c.Query("userId"); userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+		if q := c.Query("q"); q != "" {
+			searchTerm := "%" + q + "%"
+			query = query.Where("LOWER(product_name) LIKE LOWER(?) OR LOWER(customer_name) LIKE LOWER(?) OR mercari_item_id = ?", searchTerm, searchTerm, q)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count proxy orders"})
+			return
+		}
+
+		var proxyOrders []models.ProxyOrder
+		if err := query.Preload("User").Order("created_at desc").Scopes(paginate(page, pageSize)).Find(&proxyOrders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve proxy orders"})
+			return
+		}
+
+		c.JSON(http.StatusOK, paginatedResponse{Data: proxyOrders, Page: page, PageSize: pageSize, Total: total})
+	}
+}
+
+func GetProxyOrderByID(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy order ID"})
+			return
+		}
+
+		var proxyOrder models.ProxyOrder
+		if err := db.Preload("User").First(&proxyOrder, orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Proxy order not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve proxy order"})
+			return
+		}
+
+		c.JSON(http.StatusOK, proxyOrder)
+	}
+}
+
+// applyProxyQuote prices the order with the real Mercari price, the domestic
+// Japan shipping and the weight-based international shipping.
+func applyProxyQuote(order *models.ProxyOrder, req models.ProxyQuoteRequest) error {
+	actualPriceJPY := money.FromJPY(req.ActualPriceJPY)
+	domesticShippingJPY := money.FromJPY(req.DomesticShippingJPY)
+	itemPriceVND := actualPriceJPY.Convert(order.ExchangeRate, money.VND)
+	feePercent := order.ServiceFeePercent
+	if order.ExchangeRateID == nil {
+		// Đơn cũ được tạo trước khi có bảng tỷ giá
+		feePercent = rates.DefaultServiceFeePercent
+	}
+	serviceFeeVND := itemPriceVND.Percent(feePercent)
+	domesticShippingVND := domesticShippingJPY.Convert(order.ExchangeRate, money.VND)
+	internationalShippingVND := services.ProxyShippingRatePerKg.Scale(req.WeightKg)
+	itemsVND, err := itemPriceVND.Add(serviceFeeVND)
+	if err != nil {
+		return err
+	}
+	quotedTotalVND, err := money.Sum(itemsVND.Mul(int64(order.Quantity)), domesticShippingVND, internationalShippingVND)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	order.ActualPriceJPY = actualPriceJPY
+	order.DomesticShippingJPY = domesticShippingJPY
+	order.WeightKg = req.WeightKg
+	order.ServiceFee = serviceFeeVND
+	order.InternationalShippingVND = internationalShippingVND
+	order.QuotedTotalVND = quotedTotalVND
+	order.QuotedAt = &now
+	order.AdminNote = req.Note
+	return nil
+}
+
+// proxyQuoteColumns are the only columns a quote writes, so it cannot undo a
+// concurrent status change or payment on the same row.
+var proxyQuoteColumns = []string{
+	"actual_price_jpy_minor", "actual_price_jpy_currency",
+	"domestic_shipping_jpy_minor", "domestic_shipping_jpy_currency",
+	"weight_kg", "service_fee_minor", "service_fee_currency",
+	"international_shipping_vnd_minor", "international_shipping_vnd_currency",
+	"quoted_total_vnd_minor", "quoted_total_vnd_currency",
+	"quoted_at", "admin_note", "status", "updated_at",
+}
+
+func QuoteProxyOrder(db *gorm.DB, emails *services.Emailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy order ID"})
+			return
+		}
+
+		var req models.ProxyQuoteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quote data: " + err.Error()})
+			return
+		}
+
+		var proxyOrder models.ProxyOrder
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&proxyOrder, orderID).Error; err != nil {
+				return err
+			}
+			if !orders.CanProxyTransition(proxyOrder.Status, models.ProxyStatusQuoted) {
+				return orders.ErrInvalidTransition
+			}
+
+			if err := applyProxyQuote(&proxyOrder, req); err != nil {
+				return err
+			}
+			if err := payments.ExpirePendingProxyPayments(tx, proxyOrder.ID); err != nil {
+				return err
+			}
+			proxyOrder.Status = models.ProxyStatusQuoted
+			if err := tx.Model(&proxyOrder).Select(proxyQuoteColumns).Updates(&proxyOrder).Error; err != nil {
+				return err
+			}
+			if proxyOrder.CustomerEmail == "" {
+				return nil
+			}
+			return emails.EnqueueProxyQuoteEmail(tx, proxyOrder)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Proxy order not found"})
+			case errors.Is(err, orders.ErrInvalidTransition):
+				c.JSON(http.StatusConflict, gin.H{"error": "Proxy order can no longer be quoted in status " + proxyOrder.Status})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save quote"})
+			}
+			return
+		}
+
+		if proxyOrder.CustomerEmail == "" {
+			c.JSON(http.StatusOK, gin.H{"message": "Quote saved, but the order has no customer email to send it to", "emailSent": false, "order": proxyOrder})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Quote sent successfully", "emailSent": true, "order": proxyOrder})
+	}
+}
+
+func UpdateProxyOrderStatus(db *gorm.DB, registry payments.Registry, emails *services.Emailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proxy order ID"})
+			return
+		}
+
+		var req models.UpdateProxyOrderStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		if !orders.IsValidProxyStatus(req.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown proxy order status: " + req.Status})
+			return
+		}
+		if req.Status == models.ProxyStatusQuoted {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Use the quote endpoint to send a quote"})
+			return
+		}
+
+		var proxyOrder models.ProxyOrder
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&proxyOrder, orderID).Error; err != nil {
+				return err
+			}
+			// Đơn thanh toán online không được bỏ qua bước paid.
+			if registry.IsGatewayMethod(proxyOrder.PaymentMethod) &&
+				(req.Status == models.ProxyStatusPaid ||
+					proxyOrder.Status == models.ProxyStatusQuoted && req.Status == models.ProxyStatusPurchasedInJapan) {
+				return errGatewayPaymentOnly
+			}
+			if !orders.CanProxyTransition(proxyOrder.Status, req.Status) {
+				return orders.ErrInvalidTransition
+			}
+
+			proxyOrder.Status = req.Status
+			proxyOrder.AdminNote = req.Note
+			if err := tx.Model(&proxyOrder).Updates(map[string]interface{}{"status": req.Status, "admin_note": req.Note}).Error; err != nil {
+				return err
+			}
+			if proxyOrder.CustomerEmail == "" {
+				return nil
+			}
+			return emails.EnqueueProxyStatusEmail(tx, proxyOrder)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Proxy order not found"})
+			case errors.Is(err, errGatewayPaymentOnly):
+				c.JSON(http.StatusConflict, gin.H{"error": "Online payments are marked paid only by the payment gateway confirmation"})
+			case errors.Is(err, orders.ErrInvalidTransition):
+				c.JSON(http.StatusConflict, gin.H{
+					"error":   "Cannot move proxy order from " + proxyOrder.Status + " to " + req.Status,
+					"allowed": orders.AllowedProxyTransitions(proxyOrder.Status),
+				})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update proxy order status"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Proxy order status updated successfully", "order": proxyOrder})
+	}
+}
@@ -0,0 +1,171 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/payments"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+var sampleQuote = models.ProxyQuoteRequest{ActualPriceJPY: 7800, DomesticShippingJPY: 750, WeightKg: 0.8, Note: "seal"}
+
+func TestQuoteProxyOrder(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "proxybuyer")
+
+	router := gin.New()
+	router.POST("/proxy-orders/:id/quote", handlers.QuoteProxyOrder(db, testEmails))
+
+	if w := postJSON(router, "/proxy-orders/999/quote", sampleQuote); w.Code != http.StatusNotFound {
+		t.Fatalf("missing order: got %d, want 404", w.Code)
+	}
+
+	order := models.ProxyOrder{
+		UserID:          user.ID,
+		MercariURL:      "https://jp.mercari.com/item/m1",
+		MercariItemID:   "m1",
+		ProductName:     "BOX",
+		ProductPriceJPY: money.FromJPY(8000),
+		CustomerName:    "A",
+		ExchangeRate:    175,
+		Status:          models.ProxyStatusPendingQuote,
+		Quantity:        1,
+		PaymentMethod:   "bank_transfer",
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := postJSON(router, "/proxy-orders/"+itoa(order.ID)+"/quote", sampleQuote)
+	if w.Code != http.StatusOK {
+		t.Fatalf("quote: got %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		EmailSent bool `json:"emailSent"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.EmailSent {
+		t.Error("an order without a customer email must not report the quote as emailed")
+	}
+
+	var saved models.ProxyOrder
+	db.First(&saved, order.ID)
+	if saved.Status != models.ProxyStatusQuoted || saved.ActualPriceJPY != money.FromJPY(7800) || saved.QuotedAt == nil {
+		t.Errorf("quote not stored: status=%s actual=%d quotedAt=%v", saved.Status, saved.ActualPriceJPY.Amount, saved.QuotedAt)
+	}
+	if saved.CustomerName != "A" || saved.PaymentMethod != "bank_transfer" {
+		t.Errorf("quote touched non-quote columns: %+v", saved)
+	}
+
+	db.Model(&saved).Update("status", models.ProxyStatusCancelled)
+	if w := postJSON(router, "/proxy-orders/"+itoa(order.ID)+"/quote", sampleQuote); w.Code != http.StatusConflict {
+		t.Fatalf("cancelled order: got %d, want 409", w.Code)
+	}
+}
+
+// Email báo giá đi qua hàng đợi job, không gửi ngay trong request.
+func TestQuoteProxyOrderQueuesCustomerEmail(t *testing.T) {
+	db := testdb.Open(t)
+	outbox := useOutbox(t)
+	user := createUser(t, db, "quotemail")
+
+	order := models.ProxyOrder{
+		UserID:          user.ID,
+		MercariURL:      "https://jp.mercari.com/item/m2",
+		MercariItemID:   "m2",
+		ProductName:     "BOX",
+		ProductPriceJPY: money.FromJPY(8000),
+		CustomerName:    "A",
+		CustomerEmail:   "buyer@example.com",
+		ExchangeRate:    175,
+		Status:          models.ProxyStatusPendingQuote,
+		Quantity:        1,
+		PaymentMethod:   "bank_transfer",
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/proxy-orders/:id/quote", handlers.QuoteProxyOrder(db, testEmails))
+	if w := postJSON(router, "/proxy-orders/"+itoa(order.ID)+"/quote", sampleQuote); w.Code != http.StatusOK {
+		t.Fatalf("quote: got %d: %s", w.Code, w.Body)
+	}
+	if sent := outbox.Messages(); len(sent) != 0 {
+		t.Fatalf("email sent before the job ran: %v", sent)
+	}
+
+	drainJobs(t, db)
+	if sent := outbox.Messages(); len(sent) != 1 || sent[0].To[0] != order.CustomerEmail {
+		t.Fatalf("sent %v, want one quote email to %s", sent, order.CustomerEmail)
+	}
+}
+
+// Báo giá lại phải vô hiệu link thanh toán cũ, và đơn online không được bỏ qua bước paid.
+func TestRequoteExpiresPaymentsAndGatewayCannotSkipPaid(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "requote")
+	fake := payments.NewFakeProvider("secret", "")
+	registry := payments.Registry{"fake": fake}
+
+	order := models.ProxyOrder{
+		UserID:          user.ID,
+		MercariURL:      "https://jp.mercari.com/item/m3",
+		MercariItemID:   "m3",
+		ProductName:     "BOX",
+		ProductPriceJPY: money.FromJPY(8000),
+		CustomerName:    "A",
+		ExchangeRate:    175,
+		Status:          models.ProxyStatusPendingQuote,
+		Quantity:        1,
+		PaymentMethod:   "fake",
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/proxy-orders/:id/quote", handlers.QuoteProxyOrder(db, testEmails))
+	router.POST("/proxy-orders/:id/status", handlers.UpdateProxyOrderStatus(db, registry, testEmails))
+
+	url := "/proxy-orders/" + itoa(order.ID)
+	if w := postJSON(router, url+"/quote", sampleQuote); w.Code != http.StatusOK {
+		t.Fatalf("quote: got %d: %s", w.Code, w.Body)
+	}
+	db.First(&order, order.ID)
+	payment, err := payments.NewPayment(db, fake, user.ID, nil, &order.ID, order.QuotedTotalVND)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requote := sampleQuote
+	requote.ActualPriceJPY = 9000
+	if w := postJSON(router, url+"/quote", requote); w.Code != http.StatusOK {
+		t.Fatalf("re-quote: got %d: %s", w.Code, w.Body)
+	}
+	var stale models.Payment
+	db.First(&stale, payment.ID)
+	if stale.Status != models.PaymentStatusExpired {
+		t.Errorf("payment for the old quote is %s, want expired", stale.Status)
+	}
+
+	// Callback muộn cho giá cũ không được đánh dấu đơn đã thanh toán.
+	if _, err := payments.HandleCallback(db, fake, fake.SignedCallback(payment, true)); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&order, order.ID)
+	if order.Status != models.ProxyStatusQuoted {
+		t.Errorf("paying the old quote moved the order to %s", order.Status)
+	}
+
+	skip := models.UpdateProxyOrderStatusRequest{Status: models.ProxyStatusPurchasedInJapan}
+	if w := postJSON(router, url+"/status", skip); w.Code != http.StatusConflict {
+		t.Fatalf("gateway order skipping paid: got %d, want 409", w.Code)
+	}
+}
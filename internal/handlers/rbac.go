@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/rbac"
+	"gorm.io/gorm"
+)
+
+func contextRoles(c *gin.Context) []string {
+	roles, _ := c.Get("roles")
+	list, _ := roles.([]string)
+	return list
+}
+
+// StaffOnlyMiddleware admits any user holding at least one role. The
+// individual admin routes then check their own permission. With
+// RequireStaff2FA set, staff sessions must also have passed TOTP.
+func StaffOnlyMiddleware(authCfg config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(contextRoles(c)) == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied: requires staff privileges"})
+			return
+		}
+		if staffMissingMFA(c, authCfg) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication must be enabled for staff accounts", "code": "two_factor_required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// StaffMFAMiddleware guards routes shared by customers and staff, such as
+// chat: customers pass through, staff need TOTP like on the admin routes.
+func StaffMFAMiddleware(authCfg config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(contextRoles(c)) > 0 && staffMissingMFA(c, authCfg) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication must be enabled for staff accounts", "code": "two_factor_required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func staffMissingMFA(c *gin.Context, authCfg config.AuthConfig) bool {
+	if !authCfg.RequireStaff2FA {
+		return false
+	}
+	claims, _ := c.Get("claims")
+	jwtClaims, ok := claims.(*models.CustomJWTClaims)
+	return !ok || !jwtClaims.MFA
+}
+
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rbac.Has(contextRoles(c), permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied: requires permission " + permission})
+			return
+		}
+		c.Next()
+	}
+}
+
+func GetRoles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, rbac.Roles())
+	}
+}
+
+func GetUserRoles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		roles, err := rbac.RolesFor(db, &user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve roles"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"userId": user.ID, "roles": roles})
+	}
+}
+
+func AssignUserRoles(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var req models.AssignRolesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+
+		seen := make(map[string]bool)
+		var roles []string
+		for _, role := range req.Roles {
+			if !rbac.IsValidRole(role) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + role})
+				return
+			}
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+
+		if currentID, _ := c.Get("userID"); currentID == uint(userID) && !seen[rbac.RoleAdmin] {
+			c.JSON(http.StatusConflict, gin.H{"error": "You cannot remove your own admin role"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Select("id").First(&models.User{}, userID).Error; err != nil {
+				return err
+			}
+			if err := rbac.SetRoles(tx, uint(userID), roles); err != nil {
+				return err
+			}
+			// Access tokens carry roles, so the old ones must stop working now.
+			return auth.InvalidateAccessTokens(tx, uint(userID))
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign roles"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Roles updated. The user's current sessions must refresh their token to continue.", "userId": userID, "roles": roles})
+	}
+}
@@ -0,0 +1,42 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/rbac"
+)
+
+func TestStaffMFAMiddleware(t *testing.T) {
+	cases := []struct {
+		name  string
+		roles []string
+		mfa   bool
+		want  int
+	}{
+		{"customer", nil, false, http.StatusOK},
+		{"staff with TOTP", []string{rbac.RoleAdmin}, true, http.StatusOK},
+		{"staff without TOTP", []string{rbac.RoleAdmin}, false, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		router := gin.New()
+		router.GET("/chat/history", func(c *gin.Context) {
+			c.Set("roles", tc.roles)
+			c.Set("claims", &models.CustomJWTClaims{Roles: tc.roles, MFA: tc.mfa})
+			c.Next()
+		}, handlers.StaffMFAMiddleware(config.AuthConfig{RequireStaff2FA: true}), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/chat/history", nil))
+		if w.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
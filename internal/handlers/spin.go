@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -8,12 +9,24 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/orders"
+	"github.com/kaelCoding/toyBE/internal/ratelimit"
 	"github.com/kaelCoding/toyBE/internal/services"
+	"github.com/kaelCoding/toyBE/internal/vouchers"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func SpinByShippingCode(db *gorm.DB) gin.HandlerFunc {
+// SpinOrder counts only wrong spin tokens against failures, so guessing is
+// throttled while a customer with a valid token can always spin.
+func SpinOrder(db *gorm.DB, limiter ratelimit.Store, failures ratelimit.Policy) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
 		var req models.SpinRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
@@ -21,14 +34,21 @@ func SpinByShippingCode(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		var order models.Order
-		if err := db.Where("shipping_code = ?", req.ShippingCode).First(&order).Error; err != nil {
-			log.Printf("Failed to find order with shipping code %s: %v", req.ShippingCode, err)
-			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid or expired shipping code."})
+		if err := db.Preload("OrderItems.Product.Categories").Where("spin_token = ? AND user_id = ?", req.SpinToken, userID).First(&order).Error; err != nil {
+			log.Printf("Spin attempt with unknown token by user %v: %v", userID, err)
+			if result, err := failures.Take(c, limiter); err != nil {
+				log.Printf("Rate limiter error for %s: %v", failures.Name, err)
+			} else if !result.Allowed {
+				c.Header("Retry-After", ratelimit.RetryAfterSeconds(result.RetryAfter.Seconds()))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed spin attempts. Please try again later."})
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid or expired spin token."})
 			return
 		}
 
 		if order.HasSpun {
-			c.JSON(http.StatusConflict, gin.H{"error": "This shipping code has already been used to spin."})
+			c.JSON(http.StatusConflict, gin.H{"error": "This order has already been used to spin."})
 			return
 		}
 
@@ -40,25 +60,67 @@ func SpinByShippingCode(db *gorm.DB) gin.HandlerFunc {
 			}
 		}()
 
-		reward, err := services.SpinWheel(tx)
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock order."})
+			return
+		}
+		if order.HasSpun {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "This order has already been used to spin."})
+			return
+		}
+		// Đơn có thể đã bị hoàn tiền sau khi nhận mã quay.
+		if order.Status != orders.SpinQualifyingStatus || order.SpinToken == nil {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "This order no longer qualifies for a spin."})
+			return
+		}
+
+		campaign, err := services.SelectCampaign(tx, order)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to select spin campaign."})
+			return
+		}
+		var campaignID *uint
+		if campaign != nil {
+			campaignID = &campaign.ID
+		}
+
+		spin, err := services.SpinWheel(tx, campaignID)
+		if errors.Is(err, services.ErrNoRewardsAvailable) && campaignID != nil {
+			// Quà của chiến dịch đã hết thì quay vòng quay mặc định.
+			campaignID = nil
+			spin, err = services.SpinWheel(tx, nil)
+		}
 		if err != nil {
 			tx.Rollback()
+			if errors.Is(err, services.ErrNoRewardsAvailable) {
+				c.JSON(http.StatusConflict, gin.H{"error": "No rewards are available right now."})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to spin the wheel."})
 			return
 		}
+		reward := &spin.Reward
 
-		order.HasSpun = true
-		if err := tx.Save(&order).Error; err != nil {
+		if err := tx.Model(&order).Update("has_spun", true).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status."})
 			return
 		}
 
 		spinLog := models.SpinLog{
-			OrderID:  order.ID,
-			UserID:   order.UserID,
-			RewardID: reward.ID,
-			SpinDate: time.Now(),
+			OrderID:    order.ID,
+			UserID:     order.UserID,
+			RewardID:   reward.ID,
+			CampaignID: campaignID,
+			SpinDate:   time.Now(),
+
+			Seed:        spin.Seed,
+			Roll:        spin.Roll,
+			TotalWeight: spin.TotalWeight,
 		}
 		if err := tx.Create(&spinLog).Error; err != nil {
 			tx.Rollback()
@@ -66,6 +128,13 @@ func SpinByShippingCode(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		voucher, err := vouchers.Issue(tx, spinLog, *reward)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue voucher."})
+			return
+		}
+
 		if err := tx.Commit().Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction."})
@@ -75,6 +144,7 @@ func SpinByShippingCode(db *gorm.DB) gin.HandlerFunc {
 		c.JSON(http.StatusOK, models.SpinResponse{
 			Message: "Congratulations! You won a reward.",
 			Reward:  *reward,
+			Voucher: voucher,
 		})
 	}
 }
@@ -96,8 +166,7 @@ func UpdateShippingCode(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		order.ShippingCode = req.ShippingCode
-		if err := db.Save(&order).Error; err != nil {
+		if err := db.Model(&order).Update("shipping_code", req.ShippingCode).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shipping code"})
 			return
 		}
@@ -117,6 +186,24 @@ func GetRewards(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// checkRewardCampaign rejects a reward pointing at a campaign that does not
+// exist (or was deleted), which would otherwise leave it in no pool at all.
+func checkRewardCampaign(c *gin.Context, db *gorm.DB, campaignID *uint) bool {
+	if campaignID == nil {
+		return true
+	}
+	err := db.Select("id").First(&models.SpinCampaign{}, *campaignID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Spin campaign not found"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check spin campaign"})
+		return false
+	}
+	return true
+}
+
 func AddReward(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var newReward models.Reward
@@ -124,9 +211,12 @@ func AddReward(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reward data: " + err.Error()})
 			return
 		}
+		if !checkRewardCampaign(c, db, newReward.CampaignID) {
+			return
+		}
 
 		if err := db.Create(&newReward).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add new reward: " + err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add new reward"})
 			return
 		}
 		c.JSON(http.StatusCreated, gin.H{"message": "Reward added successfully", "reward": newReward})
@@ -152,11 +242,21 @@ func UpdateReward(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid update data: " + err.Error()})
 			return
 		}
+		if !checkRewardCampaign(c, db, updatedData.CampaignID) {
+			return
+		}
 
 		existingReward.Name = updatedData.Name
 		existingReward.Value = updatedData.Value
 		existingReward.Quantity = updatedData.Quantity
 		existingReward.Probability = updatedData.Probability
+		existingReward.CampaignID = updatedData.CampaignID
+		existingReward.VoucherType = updatedData.VoucherType
+		existingReward.PercentOff = updatedData.PercentOff
+		existingReward.AmountOff = updatedData.AmountOff
+		existingReward.MaxDiscount = updatedData.MaxDiscount
+		existingReward.ValidDays = updatedData.ValidDays
+		existingReward.Cost = updatedData.Cost
 
 		if err := db.Save(&existingReward).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reward"})
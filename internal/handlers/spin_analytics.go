@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/analytics"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"gorm.io/gorm"
+)
+
+// parseAnalyticsRange reads ?from=&to= (YYYY-MM-DD, both inclusive) and an
+// optional ?campaignId=. Dates are shop-local days; defaults to the last 30.
+func parseAnalyticsRange(c *gin.Context) (analytics.Range, error) {
+	now := time.Now().In(analytics.Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, analytics.Location)
+	r := analytics.Range{
+		From: today.AddDate(0, 0, -29),
+		To:   today.AddDate(0, 0, 1),
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, analytics.Location)
+		if err != nil {
+			return r, errors.New("from must be a date in YYYY-MM-DD format")
+		}
+		r.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, analytics.Location)
+		if err != nil {
+			return r, errors.New("to must be a date in YYYY-MM-DD format")
+		}
+		r.To = t.AddDate(0, 0, 1)
+	}
+	if !r.From.Before(r.To) {
+		return r, errors.New("from must not be after to")
+	}
+	if r.To.Sub(r.From) > 366*24*time.Hour {
+		return r, errors.New("date range cannot exceed one year")
+	}
+
+	if raw := c.Query("campaignId"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			return r, errors.New("invalid campaignId")
+		}
+		uid := uint(id)
+		r.CampaignID = &uid
+	}
+	return r, nil
+}
+
+// writeCSV renders the whole file before answering so an encoding failure
+// still turns into a 500 instead of a truncated download.
+func writeCSV(c *gin.Context, filename string, header []string, rows [][]string) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(header)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		log.Printf("Failed to write %s: %v", filename, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export CSV"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+func GetDailySpins(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, err := parseAnalyticsRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		days, err := analytics.SpinsPerDay(db, r)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute daily spins"})
+			return
+		}
+
+		if c.Query("format") == "csv" {
+			rows := make([][]string, 0, len(days))
+			for _, d := range days {
+				rows = append(rows, []string{d.Date, strconv.FormatInt(d.Spins, 10)})
+			}
+			writeCSV(c, "spins-per-day.csv", []string{"date", "spins"}, rows)
+			return
+		}
+		c.JSON(http.StatusOK, days)
+	}
+}
+
+func GetRewardDistribution(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, err := parseAnalyticsRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reports, err := analytics.RewardDistribution(db, r)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute reward distribution"})
+			return
+		}
+
+		if c.Query("format") == "csv" {
+			rows := make([][]string, 0, len(reports))
+			for _, rep := range reports {
+				campaign := ""
+				if rep.CampaignID != nil {
+					campaign = strconv.FormatUint(uint64(*rep.CampaignID), 10)
+				}
+				rows = append(rows, []string{
+					strconv.FormatUint(uint64(rep.RewardID), 10),
+					rep.Name,
+					campaign,
+					strconv.FormatFloat(rep.ConfiguredProbability, 'f', 4, 64),
+					strconv.FormatInt(rep.Spins, 10),
+					strconv.FormatFloat(rep.ObservedShare, 'f', 4, 64),
+					strconv.Itoa(rep.RemainingQuantity),
+					strconv.FormatInt(rep.UnitCost.Amount, 10),
+					strconv.FormatInt(rep.EstimatedCost.Amount, 10),
+					strconv.FormatInt(rep.RedeemedDiscount.Amount, 10),
+				})
+			}
+			writeCSV(c, "reward-distribution.csv", []string{
+				"reward_id", "name", "campaign_id", "configured_probability", "spins",
+				"observed_share", "remaining_quantity", "unit_cost_vnd", "estimated_cost_vnd", "redeemed_discount_vnd",
+			}, rows)
+			return
+		}
+
+		costs := []money.Money{money.FromVND(0)}
+		spins := int64(0)
+		for _, rep := range reports {
+			costs = append(costs, rep.EstimatedCost)
+			spins += rep.Spins
+		}
+		total, err := money.Sum(costs...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Reward costs are in mixed currencies"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"from":               r.From.Format("2006-01-02"),
+			"to":                 r.To.AddDate(0, 0, -1).Format("2006-01-02"),
+			"totalSpins":         spins,
+			"totalEstimatedCost": total,
+			"rewards":            reports,
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/services"
+	"gorm.io/gorm"
+)
+
+func applySpinCampaignRequest(db *gorm.DB, campaign *models.SpinCampaign, req models.SpinCampaignRequest) error {
+	if req.StartsAt != nil && req.EndsAt != nil && req.EndsAt.Before(*req.StartsAt) {
+		return errors.New("endsAt must be after startsAt")
+	}
+
+	campaign.Name = req.Name
+	campaign.Description = req.Description
+	campaign.StartsAt = req.StartsAt
+	campaign.EndsAt = req.EndsAt
+	campaign.MinOrderValue = money.FromVND(req.MinOrderValue)
+	campaign.MaxSpinsPerUser = req.MaxSpinsPerUser
+	campaign.Priority = req.Priority
+	if req.Active != nil {
+		campaign.Active = *req.Active
+	}
+
+	campaign.Categories = nil
+	if len(req.CategoryIDs) > 0 {
+		if err := db.Find(&campaign.Categories, req.CategoryIDs).Error; err != nil {
+			return err
+		}
+		if len(campaign.Categories) != len(req.CategoryIDs) {
+			return errors.New("one or more categories not found")
+		}
+	}
+	return nil
+}
+
+func GetSpinCampaigns(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var campaigns []models.SpinCampaign
+		if err := db.Preload("Categories").Preload("Rewards").Order("priority desc, created_at desc").Find(&campaigns).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve spin campaigns"})
+			return
+		}
+		c.JSON(http.StatusOK, campaigns)
+	}
+}
+
+func AddSpinCampaign(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.SpinCampaignRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign data: " + err.Error()})
+			return
+		}
+
+		campaign := models.SpinCampaign{Active: true}
+		if err := applySpinCampaignRequest(db, &campaign, req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := db.Create(&campaign).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add spin campaign"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Spin campaign added successfully", "campaign": campaign})
+	}
+}
+
+func UpdateSpinCampaign(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaignID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+			return
+		}
+
+		var campaign models.SpinCampaign
+		if err := db.First(&campaign, campaignID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Spin campaign not found"})
+			return
+		}
+
+		var req models.SpinCampaignRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign data: " + err.Error()})
+			return
+		}
+		if err := applySpinCampaignRequest(db, &campaign, req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit("Categories", "Rewards").Save(&campaign).Error; err != nil {
+				return err
+			}
+			return tx.Model(&campaign).Association("Categories").Replace(campaign.Categories)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update spin campaign"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Spin campaign updated successfully", "campaign": campaign})
+	}
+}
+
+func DeleteSpinCampaign(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaignID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+			return
+		}
+
+		if err := db.Delete(&models.SpinCampaign{}, uint(campaignID)).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete spin campaign"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Spin campaign deleted successfully"})
+	}
+}
+
+// PreviewSpinOdds shows the effective distribution of a campaign's pool. The
+// id "default" previews the pool used when no campaign applies.
+func PreviewSpinOdds(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var campaignID *uint
+		if c.Param("id") != "default" {
+			id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+				return
+			}
+			if err := db.Select("id").First(&models.SpinCampaign{}, id).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Spin campaign not found"})
+				return
+			}
+			uid := uint(id)
+			campaignID = &uid
+		}
+
+		odds, err := services.PreviewOdds(db, campaignID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute spin odds"})
+			return
+		}
+		c.JSON(http.StatusOK, odds)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+func TestAddSpinCampaignKeepsInactive(t *testing.T) {
+	db := testdb.Open(t)
+	router := gin.New()
+	router.POST("/spin-campaigns", handlers.AddSpinCampaign(db))
+
+	inactive := false
+	w := postJSON(router, "/spin-campaigns", models.SpinCampaignRequest{Name: "Draft", Active: &inactive})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add campaign: %d %s", w.Code, w.Body)
+	}
+
+	var campaign models.SpinCampaign
+	if err := db.Where("name = ?", "Draft").First(&campaign).Error; err != nil {
+		t.Fatal(err)
+	}
+	if campaign.Active {
+		t.Error("campaign created with active=false was stored as active")
+	}
+}
+
+// Khi quỹ quà của chiến dịch đã hết, khách vẫn được quay vòng quay mặc định.
+func TestSpinFallsBackToDefaultPoolWhenCampaignRunsDry(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "lucky")
+
+	campaign := models.SpinCampaign{Name: "Tet", Active: true}
+	if err := db.Create(&campaign).Error; err != nil {
+		t.Fatal(err)
+	}
+	exhausted := models.Reward{Name: "Limited", Quantity: 0, Probability: 1, CampaignID: &campaign.ID}
+	fallback := models.Reward{Name: "Sticker", Quantity: -1, Probability: 1}
+	if err := db.Create(&exhausted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&fallback).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	token := "fallback"
+	if err := db.Create(&models.Order{UserID: user.ID, TotalAmount: money.FromVND(100000), Status: models.OrderStatusDelivered, SpinToken: &token}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/spin", asUser(user.ID), spinOrder(db))
+	if w := postJSON(router, "/spin", models.SpinRequest{SpinToken: token}); w.Code != http.StatusOK {
+		t.Fatalf("spin: %d %s", w.Code, w.Body)
+	}
+
+	var log models.SpinLog
+	if err := db.Where("user_id = ?", user.ID).First(&log).Error; err != nil {
+		t.Fatal(err)
+	}
+	if log.RewardID != fallback.ID || log.CampaignID != nil {
+		t.Errorf("spin log reward=%d campaign=%v, want reward %d from the default pool", log.RewardID, log.CampaignID, fallback.ID)
+	}
+}
+
+func TestAddRewardRejectsUnknownCampaign(t *testing.T) {
+	db := testdb.Open(t)
+	router := gin.New()
+	router.POST("/rewards", handlers.AddReward(db))
+
+	missing := uint(999)
+	w := postJSON(router, "/rewards", models.Reward{Name: "Ghost", Probability: 1, CampaignID: &missing})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("add reward with unknown campaign: %d, want 422", w.Code)
+	}
+
+	var count int64
+	db.Model(&models.Reward{}).Count(&count)
+	if count != 0 {
+		t.Errorf("rewards = %d, want 0", count)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+// Nhiều lượt quay đồng thời tranh nhau một phần thưởng giới hạn: đúng K lượt
+// trúng, tồn kho về 0 và không bao giờ âm.
+func TestConcurrentSpinsNeverOverdrawLimitedReward(t *testing.T) {
+	const spins, stock = 20, 5
+	db := testdb.Open(t)
+	user := createUser(t, db, "spinner")
+
+	reward := models.Reward{Name: "Figure", Quantity: stock, Probability: 1}
+	if err := db.Create(&reward).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := make([]string, spins)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token-%02d", i)
+		order := models.Order{
+			UserID:      user.ID,
+			TotalAmount: money.FromVND(500000),
+			Status:      models.OrderStatusDelivered,
+			SpinToken:   &tokens[i],
+		}
+		if err := db.Create(&order).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := gin.New()
+	router.POST("/spin", asUser(user.ID), spinOrder(db))
+
+	var wg sync.WaitGroup
+	codes := make([]int, spins)
+	start := make(chan struct{})
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			codes[i] = postJSON(router, "/spin", models.SpinRequest{SpinToken: tokens[i]}).Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	won, soldOut := 0, 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			won++
+		case http.StatusConflict:
+			soldOut++
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if won != stock || soldOut != spins-stock {
+		t.Errorf("won=%d soldOut=%d, want %d and %d", won, soldOut, stock, spins-stock)
+	}
+
+	if err := db.First(&reward, reward.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reward.Quantity != 0 {
+		t.Errorf("reward quantity = %d, want 0", reward.Quantity)
+	}
+
+	var logs, vouchers int64
+	db.Model(&models.SpinLog{}).Where("reward_id = ?", reward.ID).Count(&logs)
+	db.Model(&models.UserVoucher{}).Where("reward_id = ?", reward.ID).Count(&vouchers)
+	if logs != stock || vouchers != stock {
+		t.Errorf("spin logs=%d vouchers=%d, want %d each", logs, vouchers, stock)
+	}
+}
+
+func TestSpinTokenIsSingleUse(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "once")
+	if err := db.Create(&models.Reward{Name: "Sticker", Quantity: -1, Probability: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	token := "single-use"
+	if err := db.Create(&models.Order{UserID: user.ID, TotalAmount: money.FromVND(100000), Status: models.OrderStatusDelivered, SpinToken: &token}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/spin", asUser(user.ID), spinOrder(db))
+
+	if w := postJSON(router, "/spin", models.SpinRequest{SpinToken: token}); w.Code != http.StatusOK {
+		t.Fatalf("first spin: %d %s", w.Code, w.Body)
+	}
+	if w := postJSON(router, "/spin", models.SpinRequest{SpinToken: token}); w.Code != http.StatusConflict {
+		t.Fatalf("second spin: %d, want 409", w.Code)
+	}
+
+	other := createUser(t, db, "thief")
+	router = gin.New()
+	router.POST("/spin", asUser(other.ID), spinOrder(db))
+	if w := postJSON(router, "/spin", models.SpinRequest{SpinToken: token}); w.Code != http.StatusNotFound {
+		t.Fatalf("spin with someone else's token: %d, want 404", w.Code)
+	}
+}
+
+func TestSpinNeedsADeliveredOrderAndThrottlesGuesses(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "guesser")
+	if err := db.Create(&models.Reward{Name: "Sticker", Quantity: -1, Probability: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Đơn đã hoàn tiền nhưng còn giữ mã quay cũ.
+	token := "refunded"
+	if err := db.Create(&models.Order{UserID: user.ID, TotalAmount: money.FromVND(100000), Status: models.OrderStatusRefunded, SpinToken: &token}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/spin", asUser(user.ID), spinOrder(db))
+	if w := postJSON(router, "/spin", models.SpinRequest{SpinToken: token}); w.Code != http.StatusConflict {
+		t.Fatalf("spin on a refunded order: %d, want 409", w.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := postJSON(router, "/spin", models.SpinRequest{SpinToken: "wrong"}); w.Code != http.StatusNotFound {
+			t.Fatalf("wrong token %d: %d, want 404", i, w.Code)
+		}
+	}
+	w := postJSON(router, "/spin", models.SpinRequest{SpinToken: "wrong"})
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("too many wrong tokens: %d, want 429 with Retry-After", w.Code)
+	}
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/ratelimit"
+	"github.com/kaelCoding/toyBE/internal/rbac"
+	"gorm.io/gorm"
+)
+
+func currentUser(c *gin.Context, db *gorm.DB) (*models.User, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+func twoFactorLocked(c *gin.Context, user *models.User) bool {
+	lockedFor := auth.LockedFor(user)
+	if lockedFor == 0 {
+		return false
+	}
+	c.Header("Retry-After", ratelimit.RetryAfterSeconds(lockedFor.Seconds()))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Account temporarily locked after too many failed attempts. Please try again later."})
+	return true
+}
+
+// rejectSecondFactor counts a wrong password or code towards the account
+// lockout and answers 429 once it locks, message otherwise.
+func rejectSecondFactor(c *gin.Context, db *gorm.DB, userID uint, message string) {
+	lockedFor, err := auth.RecordFailedLogin(db, userID)
+	if err != nil {
+		log.Printf("Failed to record failed 2FA attempt for user %d: %v", userID, err)
+	}
+	if lockedFor > 0 {
+		c.Header("Retry-After", ratelimit.RetryAfterSeconds(lockedFor.Seconds()))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Account temporarily locked after too many failed attempts. Please try again later."})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+// LoginTwoFactor completes a login started by LoginUser for a 2FA account.
+// A wrong code leaves the challenge usable until it expires but counts
+// towards the account lockout.
+func LoginTwoFactor(db *gorm.DB, issuer *auth.Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.LoginTwoFactorRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+
+		var user models.User
+		err := db.Transaction(func(tx *gorm.DB) error {
+			challenge, err := auth.ConsumeUserToken(tx, req.ChallengeToken, models.UserTokenLogin2FA)
+			if err != nil {
+				return err
+			}
+			if err := tx.First(&user, challenge.UserID).Error; err != nil {
+				return err
+			}
+			if auth.LockedFor(&user) > 0 {
+				return auth.ErrInvalidUserToken
+			}
+			return auth.VerifySecondFactor(tx, user.ID, req.Code)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrInvalidUserToken):
+				if !twoFactorLocked(c, &user) {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or has expired. Please log in again."})
+				}
+			case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+				rejectSecondFactor(c, db, user.ID, "Invalid two-factor code")
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify two-factor code"})
+			}
+			return
+		}
+
+		if err := auth.ClearFailedLogins(db, user.ID); err != nil {
+			log.Printf("Failed to clear failed logins for user %d: %v", user.ID, err)
+		}
+
+		tokens, err := issuer.Login(db, &user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresAt":    tokens.ExpiresAt,
+			"expiresIn":    tokens.ExpiresIn,
+		})
+	}
+}
+
+func SetupTwoFactor(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		if user.TOTPEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+
+		secret, uri, err := auth.BeginTOTPSetup(db, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"secret": secret, "provisioningUri": uri})
+	}
+}
+
+// EnableTwoFactor confirms enrollment. Existing sessions are signed out and a
+// new token pair is returned, so every live session of the account has
+// passed the second factor.
+func EnableTwoFactor(db *gorm.DB, issuer *auth.Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.TwoFactorCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		if user.TOTPEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+
+		var backupCodes []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			backupCodes, err = auth.EnableTOTP(tx, user.ID, req.Code)
+			return err
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrTwoFactorNotPending):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+			}
+			return
+		}
+
+		if err := auth.LogoutAll(db, user.ID); err != nil {
+			log.Printf("Failed to revoke sessions after enabling 2FA for user %d: %v", user.ID, err)
+		}
+		user.TOTPEnabled = true
+		tokens, err := issuer.Login(db, user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Two-factor authentication enabled. Store the backup codes somewhere safe; they are shown only once.",
+			"backupCodes":  backupCodes,
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresAt":    tokens.ExpiresAt,
+			"expiresIn":    tokens.ExpiresIn,
+		})
+	}
+}
+
+// DisableTwoFactor needs the password and a current code, both counted towards
+// the lockout like a login. Other sessions are signed out and a new token
+// pair is returned, as when enabling.
+func DisableTwoFactor(db *gorm.DB, issuer *auth.Tokens, authCfg config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.DisableTwoFactorRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		if !user.TOTPEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+			return
+		}
+		if twoFactorLocked(c, user) {
+			return
+		}
+
+		if authCfg.RequireStaff2FA {
+			roles, err := rbac.RolesFor(db, user)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check roles"})
+				return
+			}
+			if len(roles) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is required for staff accounts"})
+				return
+			}
+		}
+
+		match, err := user.VerifyPassword(req.Password)
+		if err != nil || !match {
+			rejectSecondFactor(c, db, user.ID, "Invalid password")
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := auth.VerifySecondFactor(tx, user.ID, req.Code); err != nil {
+				return err
+			}
+			if err := auth.DisableTOTP(tx, user.ID); err != nil {
+				return err
+			}
+			if err := auth.ClearFailedLogins(tx, user.ID); err != nil {
+				return err
+			}
+			return auth.LogoutAll(tx, user.ID)
+		})
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+				rejectSecondFactor(c, db, user.ID, "Invalid two-factor code")
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+			return
+		}
+
+		user.TOTPEnabled = false
+		tokens, err := issuer.Login(db, user, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Two-factor authentication disabled",
+			"token":        tokens.AccessToken,
+			"refreshToken": tokens.RefreshToken,
+			"expiresAt":    tokens.ExpiresAt,
+			"expiresIn":    tokens.ExpiresIn,
+		})
+	}
+}
+
+func RegenerateBackupCodes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.TwoFactorCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		user, ok := currentUser(c, db)
+		if !ok {
+			return
+		}
+		if !user.TOTPEnabled {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+			return
+		}
+		if twoFactorLocked(c, user) {
+			return
+		}
+
+		var codes []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := auth.VerifySecondFactor(tx, user.ID, req.Code); err != nil {
+				return err
+			}
+			var err error
+			if codes, err = auth.RegenerateBackupCodes(tx, user.ID); err != nil {
+				return err
+			}
+			return auth.ClearFailedLogins(tx, user.ID)
+		})
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+				rejectSecondFactor(c, db, user.ID, "Invalid two-factor code")
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate backup codes"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"backupCodes": codes})
+	}
+}
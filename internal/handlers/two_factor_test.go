@@ -0,0 +1,34 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+// Đoán mã 2FA ở các endpoint quản lý cũng bị tính vào khoá tài khoản.
+func TestWrongCodesOnTwoFactorSettingsLockTheAccount(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "mfaguess")
+	db.Model(&user).Updates(map[string]interface{}{"totp_secret": "JBSWY3DPEHPK3PXP", "totp_enabled": true})
+
+	router := gin.New()
+	router.POST("/2fa/backup-codes", asUser(user.ID), handlers.RegenerateBackupCodes(db))
+
+	for i := 1; i < auth.MaxFailedLogins; i++ {
+		if w := postJSON(router, "/2fa/backup-codes", models.TwoFactorCodeRequest{Code: "000000"}); w.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong code %d: got %d, want 401", i, w.Code)
+		}
+	}
+	if w := postJSON(router, "/2fa/backup-codes", models.TwoFactorCodeRequest{Code: "000000"}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("wrong code %d: got %d, want 429", auth.MaxFailedLogins, w.Code)
+	}
+	if w := postJSON(router, "/2fa/backup-codes", models.TwoFactorCodeRequest{Code: "000000"}); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked account: got %d, want 429", w.Code)
+	}
+}
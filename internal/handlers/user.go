@@ -4,49 +4,97 @@ import (
     "errors"
     "log"
     "net/http"
-    "os"
     "strings"
     "time"
-    "fmt"
 
     "github.com/gin-gonic/gin"
-    "github.com/golang-jwt/jwt/v5"
+    "github.com/kaelCoding/toyBE/internal/auth"
+    "github.com/kaelCoding/toyBE/internal/database"
     "github.com/kaelCoding/toyBE/internal/models"
+    "github.com/kaelCoding/toyBE/internal/money"
+    "github.com/kaelCoding/toyBE/internal/ratelimit"
+    "github.com/kaelCoding/toyBE/internal/services"
     "github.com/kaelCoding/toyBE/internal/utils"
     "github.com/kaelCoding/toyBE/internal/loyalty"
     "gorm.io/gorm"
 )
 
-func RegisterUser(db *gorm.DB) gin.HandlerFunc {
+func RegisterUser(db *gorm.DB, emails *services.Emailer) gin.HandlerFunc {
     return func(c *gin.Context) {
-        var newUser models.User
-
-        if err := c.ShouldBindJSON(&newUser); err != nil {
+        var req models.RegisterRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+            if fields := fieldErrors(err); fields != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": fields})
+                return
+            }
             c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
             return
         }
 
-        hash, err := utils.GenerateFromPassword(newUser.Password, &utils.HashParams{
-            Memory:      64 * 1024,
-            Iterations:  4,
-            Parallelism: 2,
-            SaltLength:  16,
-            KeyLength:   32,
-        })
+        req.Username = strings.TrimSpace(req.Username)
+        req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+
+        fields := map[string]string{}
+        if msg := auth.ValidateUsername(req.Username); msg != "" {
+            fields["username"] = msg
+        }
+        if msg := auth.ValidatePassword(req.Password, req.Username, req.Email); msg != "" {
+            fields["password"] = msg
+        }
+        if len(fields) > 0 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "fields": fields})
+            return
+        }
+
+        var taken []models.User
+        if err := db.Select("username", "email").Where("LOWER(username) = LOWER(?) OR LOWER(email) = ?", req.Username, req.Email).Find(&taken).Error; err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+            return
+        }
+        for _, u := range taken {
+            if strings.EqualFold(u.Username, req.Username) {
+                fields["username"] = "is already taken"
+            }
+            if strings.EqualFold(u.Email, req.Email) {
+                fields["email"] = "is already registered"
+            }
+        }
+        if len(fields) > 0 {
+            c.JSON(http.StatusConflict, gin.H{"error": "Username or email already exists", "fields": fields})
+            return
+        }
+
+        hash, err := utils.GenerateFromPassword(req.Password, utils.DefaultHashParams)
         if err != nil {
             log.Printf("Error hashing password: %v", err)
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
             return
         }
-        newUser.Password = hash
 
-        result := db.Create(&newUser)
-        if result.Error != nil {
-            if strings.Contains(result.Error.Error(), "UNIQUE constraint failed") {
-                c.JSON(http.StatusConflict, gin.H{"error": "Username or email already exists"})
-            } else {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+        newUser := models.User{
+            Username: req.Username,
+            Email:    req.Email,
+            Password: hash,
+        }
+
+        err = db.Transaction(func(tx *gorm.DB) error {
+            if err := tx.Create(&newUser).Error; err != nil {
+                return err
             }
+            return emails.EnqueueVerificationEmail(tx, newUser.ID)
+        })
+        if err != nil {
+            // Lost a race with a concurrent signup for the same username or email.
+            if constraint, ok := database.UniqueViolation(err); ok {
+                field := "username"
+                if strings.Contains(constraint, "email") {
+                    field = "email"
+                }
+                c.JSON(http.StatusConflict, gin.H{"error": "Username or email already exists", "fields": gin.H{field: "is already taken"}})
+                return
+            }
+            log.Printf("Error creating user: %v", err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
             return
         }
 
@@ -64,7 +112,7 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
     }
 }
 
-func LoginUser(db *gorm.DB) gin.HandlerFunc {
+func LoginUser(db *gorm.DB, issuer *auth.Tokens) gin.HandlerFunc {
     return func(c *gin.Context) {
         var loginData models.Login
         if err := c.ShouldBindJSON(&loginData); err != nil {
@@ -72,10 +120,24 @@ func LoginUser(db *gorm.DB) gin.HandlerFunc {
             return
         }
 
+        email := strings.TrimSpace(loginData.Email)
         user := &models.User{}
-        result := db.Where("email = ?", loginData.Email).First(user)
+        result := db.Where("LOWER(email) = LOWER(?)", email).First(user)
         if result.Error != nil {
             if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+                // Email chưa đăng ký cũng bị khóa như tài khoản thật để không lộ email nào tồn tại.
+                lockedFor, err := auth.UnknownEmailLockedFor(db, email)
+                if err == nil && lockedFor == 0 {
+                    lockedFor, err = auth.RecordUnknownEmailFailure(db, email)
+                }
+                if err != nil {
+                    log.Printf("Failed to record failed login for unknown email: %v", err)
+                }
+                if lockedFor > 0 {
+                    c.Header("Retry-After", ratelimit.RetryAfterSeconds(lockedFor.Seconds()))
+                    c.JSON(http.StatusTooManyRequests, gin.H{"error": "Account temporarily locked after too many failed logins. Please try again later or reset your password."})
+                    return
+                }
                 c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
             } else {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
@@ -84,6 +146,12 @@ func LoginUser(db *gorm.DB) gin.HandlerFunc {
             return
         }
 
+        if lockedFor := auth.LockedFor(user); lockedFor > 0 {
+            c.Header("Retry-After", ratelimit.RetryAfterSeconds(lockedFor.Seconds()))
+            c.JSON(http.StatusTooManyRequests, gin.H{"error": "Account temporarily locked after too many failed logins. Please try again later or reset your password."})
+            return
+        }
+
         match, err := user.VerifyPassword(loginData.Password)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying password"})
@@ -91,31 +159,61 @@ func LoginUser(db *gorm.DB) gin.HandlerFunc {
         }
 
         if !match {
+            lockedFor, err := auth.RecordFailedLogin(db, user.ID)
+            if err != nil {
+                log.Printf("Failed to record failed login for user %d: %v", user.ID, err)
+            }
+            if lockedFor > 0 {
+                c.Header("Retry-After", ratelimit.RetryAfterSeconds(lockedFor.Seconds()))
+                c.JSON(http.StatusTooManyRequests, gin.H{"error": "Account temporarily locked after too many failed logins. Please try again later or reset your password."})
+                return
+            }
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
             return
         }
 
-        claims := &models.CustomJWTClaims{
-            ID:       user.ID,
-            Username: user.Username,
-            Email:    user.Email,
-            Admin:    user.Admin,
-            RegisteredClaims: jwt.RegisteredClaims{
-                ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
-                IssuedAt:  jwt.NewNumericDate(time.Now()),
-                NotBefore: jwt.NewNumericDate(time.Now()),
-            },
+        if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+            if err := auth.ClearFailedLogins(db, user.ID); err != nil {
+                log.Printf("Failed to clear failed logins for user %d: %v", user.ID, err)
+            }
         }
 
-        token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-        jwtSecret := os.Getenv("JWT_SECRET")
-        tokenString, err := token.SignedString([]byte(jwtSecret))
+        // Nâng cấp hash cũ lên tham số hiện tại khi đã có mật khẩu gốc.
+        if utils.NeedsRehash(user.Password, utils.DefaultHashParams) {
+            if hash, err := utils.GenerateFromPassword(loginData.Password, utils.DefaultHashParams); err == nil {
+                if err := db.Model(&user).Update("password", hash).Error; err != nil {
+                    log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+                }
+            }
+        }
+
+        if user.TOTPEnabled {
+            challenge, err := auth.CreateUserToken(db, user.ID, models.UserTokenLogin2FA, auth.LoginChallengeTTL)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Error starting two-factor login"})
+                return
+            }
+            c.JSON(http.StatusOK, gin.H{
+                "twoFactorRequired": true,
+                "challengeToken":    challenge,
+                "expiresIn":         int(auth.LoginChallengeTTL.Seconds()),
+            })
+            return
+        }
+
+        tokens, err := issuer.Login(db, user, c.Request.UserAgent(), c.ClientIP())
         if err != nil {
+            log.Printf("Error issuing tokens for user %d: %v", user.ID, err)
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
             return
         }
 
-        c.JSON(http.StatusOK, gin.H{"token": tokenString})
+        c.JSON(http.StatusOK, gin.H{
+            "token":        tokens.AccessToken,
+            "refreshToken": tokens.RefreshToken,
+            "expiresAt":    tokens.ExpiresAt,
+            "expiresIn":    tokens.ExpiresIn,
+        })
     }
 }
 
@@ -166,23 +264,34 @@ func GetUser(db *gorm.DB) gin.HandlerFunc {
         
         if user.VIPLevel > 0 && user.VIPLevel < 4 && user.VIPExpiryDate != nil && time.Now().After(*user.VIPExpiryDate) {
             log.Printf("Lazy demotion check for User ID %d", user.ID)
-            user.VIPLevel--
-            user.MaintenanceSpending = 0
-            if user.VIPLevel > 0 {
-                newExpiryDate := time.Now().AddDate(0, 3, 0)
-                user.VIPExpiryDate = &newExpiryDate
-            } else {
-                user.VIPExpiryDate = nil
+            demotion, err := loyalty.DemoteIfExpired(db, user.ID)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update VIP level"})
+                return
+            }
+            if demotion != nil {
+                if err := db.First(&user, user.ID).Error; err != nil {
+                    c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+                    return
+                }
             }
-            db.Save(&user)
         }
         
         currentVIPInfo := loyalty.GetVIPLevelInfo(user.VIPLevel)
         nextLevelInfo := loyalty.GetVIPLevelInfo(user.VIPLevel + 1)
 
-        var nextLevelRequirement float64
+        var nextLevelRequirement money.Money
+        var err error
         if user.VIPLevel < 4 {
-            nextLevelRequirement = nextLevelInfo.Threshold - user.TotalSpent
+            if nextLevelRequirement, err = nextLevelInfo.Threshold.Sub(user.TotalSpent); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute VIP progress"})
+                return
+            }
+        }
+        maintenanceRequirement, err := currentVIPInfo.MaintenanceRequirement.Sub(user.MaintenanceSpending)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute VIP progress"})
+            return
         }
         
         userProfile := models.UserProfileResponse{
@@ -194,15 +303,17 @@ func GetUser(db *gorm.DB) gin.HandlerFunc {
             VIPLevel:               user.VIPLevel,
             VIPExpiryDate:          user.VIPExpiryDate,
             DiscountPercentage:     currentVIPInfo.Discount,
+            EmailVerified:          user.EmailVerifiedAt != nil,
+            TOTPEnabled:            user.TOTPEnabled,
             NextLevelRequirement:   nextLevelRequirement,
-            MaintenanceRequirement: currentVIPInfo.MaintenanceRequirement - user.MaintenanceSpending,
+            MaintenanceRequirement: maintenanceRequirement,
         }
 
         c.JSON(http.StatusOK, userProfile)
     }
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(db *gorm.DB, issuer *auth.Tokens) gin.HandlerFunc {
     return func(c *gin.Context) {
         var tokenString string
 
@@ -228,43 +339,27 @@ func AuthMiddleware() gin.HandlerFunc {
             return
         }
 
-        jwtSecret := os.Getenv("JWT_SECRET")
-        claims := &models.CustomJWTClaims{}
-        token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-            if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-                return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-            }
-            return []byte(jwtSecret), nil
-        })
-
-        if err != nil || !token.Valid {
+        claims, err := issuer.ParseAccessToken(tokenString)
+        if err != nil {
             c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
             return
         }
 
+        if err := auth.CheckRevoked(db, claims); err != nil {
+            if errors.Is(err, auth.ErrRevokedToken) {
+                c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+                return
+            }
+            c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+            return
+        }
+
         c.Set("userID", claims.ID)
         c.Set("username", claims.Username)
         c.Set("email", claims.Email)
         c.Set("isAdmin", claims.Admin)
-
-        c.Next()
-    }
-}
-
-func AdminOnlyMiddleware() gin.HandlerFunc {
-    return func(c *gin.Context) {
-        isAdmin, exists := c.Get("isAdmin")
-        if !exists {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "User admin status not found in context"})
-            c.Abort()
-            return
-        }
-
-        if !isAdmin.(bool) {
-            c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: requires admin privileges"})
-            c.Abort()
-            return
-        }
+        c.Set("roles", claims.Roles)
+        c.Set("claims", claims)
 
         c.Next()
     }
@@ -0,0 +1,43 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/handlers"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+// Hồ sơ đọc sau khi hết hạn VIP thì hạ một hạng, giữ nguyên các cột khác.
+func TestGetUserDemotesAnExpiredVIP(t *testing.T) {
+	db := testdb.Open(t)
+	user := createUser(t, db, "lapsed")
+	expired := time.Now().Add(-time.Hour)
+	db.Model(&user).Updates(map[string]interface{}{"VIPLevel": 2, "VIPExpiryDate": expired, "total_spent_minor": 3000000})
+
+	router := gin.New()
+	router.GET("/me", asUser(user.ID), handlers.GetUser(db))
+
+	validAfter := time.Now().Truncate(time.Microsecond)
+	db.Model(&user).Update("tokens_valid_after", validAfter)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("get user: got %d: %s", w.Code, w.Body)
+	}
+
+	var saved models.User
+	db.First(&saved, user.ID)
+	if saved.VIPLevel != 1 || saved.VIPExpiryDate == nil || !saved.VIPExpiryDate.After(time.Now()) {
+		t.Errorf("demotion: level=%d expiry=%v, want level 1 with a new expiry", saved.VIPLevel, saved.VIPExpiryDate)
+	}
+	if saved.TotalSpent != money.FromVND(3000000) || saved.TokensValidAfter == nil {
+		t.Errorf("demotion touched other columns: spent=%v tokensValidAfter=%v", saved.TotalSpent, saved.TokensValidAfter)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation errors name fields by their json tag, the name clients send.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// fieldErrors turns binding validation failures into {"field": "message"}
+// keyed by the JSON field name.
+func fieldErrors(err error) map[string]string {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+
+	fields := make(map[string]string, len(validationErrors))
+	for _, fe := range validationErrors {
+		name := fe.Field()
+		switch fe.Tag() {
+		case "required":
+			fields[name] = "is required"
+		case "email":
+			fields[name] = "must be a valid email address"
+		case "min":
+			fields[name] = fmt.Sprintf("must be at least %s characters", fe.Param())
+		case "max":
+			fields[name] = fmt.Sprintf("must be at most %s characters", fe.Param())
+		default:
+			fields[name] = "is invalid"
+		}
+	}
+	return fields
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestFieldErrorsUseJSONNames(t *testing.T) {
+	req := struct {
+		PhoneNumber string `json:"phone" binding:"required"`
+		HomeURL     string `json:"homeUrl,omitempty" binding:"omitempty,max=3"`
+		Note        string `binding:"required"`
+	}{HomeURL: "https://example.com"}
+
+	fields := fieldErrors(binding.Validator.ValidateStruct(&req))
+	want := map[string]string{
+		"phone":   "is required",
+		"homeUrl": "must be at most 3 characters",
+		"Note":    "is required",
+	}
+	if len(fields) != len(want) {
+		t.Fatalf("got %v, want %v", fields, want)
+	}
+	for name, msg := range want {
+		if fields[name] != msg {
+			t.Errorf("%s: got %q, want %q", name, fields[name], msg)
+		}
+	}
+}
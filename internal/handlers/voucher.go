@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/vouchers"
+	"gorm.io/gorm"
+)
+
+func GetMyVouchers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		query := db.Preload("Reward").Where("user_id = ?", userID).Scopes(vouchers.FilterStatus(statusFilter(c)))
+
+		var list []models.UserVoucher
+		if err := query.Order("created_at desc").Find(&list).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve vouchers"})
+			return
+		}
+		vouchers.ShowExpiry(list)
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+func GetAllVouchers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, pageSize := parsePagination(c)
+		query := db.Model(&models.UserVoucher{}).Scopes(vouchers.FilterStatus(statusFilter(c)))
+		if userID := c.Query("userId"); userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count vouchers"})
+			return
+		}
+
+		var list []models.UserVoucher
+		if err := query.Preload("Reward").Order("created_at desc").Scopes(paginate(page, pageSize)).Find(&list).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve vouchers"})
+			return
+		}
+		vouchers.ShowExpiry(list)
+
+		c.JSON(http.StatusOK, paginatedResponse{Data: list, Page: page, PageSize: pageSize, Total: total})
+	}
+}
+
+func GetVoucherSummary(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rows []struct {
+			Status string `json:"status"`
+			Count  int64  `json:"count"`
+		}
+		// Voucher quá hạn nhưng cron chưa quét vẫn được đếm là expired.
+		effective := "CASE WHEN status = ? AND expires_at <= ? THEN ? ELSE status END"
+		err := db.Model(&models.UserVoucher{}).
+			Select(effective+" AS status, count(*) AS count", models.VoucherStatusActive, time.Now(), models.VoucherStatusExpired).
+			Group("1").Scan(&rows).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarise vouchers"})
+			return
+		}
+
+		summary := gin.H{
+			models.VoucherStatusActive:   int64(0),
+			models.VoucherStatusRedeemed: int64(0),
+			models.VoucherStatusExpired:  int64(0),
+			models.VoucherStatusRevoked:  int64(0),
+		}
+		for _, row := range rows {
+			summary[row.Status] = row.Count
+		}
+		c.JSON(http.StatusOK, summary)
+	}
+}
@@ -0,0 +1,182 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+var ManualReasons = map[string]bool{
+	models.StockReasonRestock:    true,
+	models.StockReasonReturn:     true,
+	models.StockReasonDamaged:    true,
+	models.StockReasonCorrection: true,
+}
+
+type OutOfStockItem struct {
+	CartItemID  uint   `json:"cartItemId"`
+	ProductID   uint   `json:"productId"`
+	ProductName string `json:"productName"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+}
+
+// lockProducts locks the given product rows in ascending ID order so that
+// concurrent checkouts sharing products cannot deadlock each other.
+func lockProducts(tx *gorm.DB, productIDs []uint) (map[uint]*models.Product, error) {
+	ids := append([]uint(nil), productIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var products []models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id asc").Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	locked := make(map[uint]*models.Product, len(products))
+	for i := range products {
+		locked[products[i].ID] = &products[i]
+	}
+	return locked, nil
+}
+
+// ReserveCartItems decrements stock for every cart item inside tx. When any
+// item cannot be fulfilled nothing is written and the offending items are
+// returned together with ErrInsufficientStock.
+func ReserveCartItems(tx *gorm.DB, items []models.CartItem, orderID uint) ([]OutOfStockItem, error) {
+	requested := make(map[uint]int)
+	var productIDs []uint
+	for _, item := range items {
+		if _, seen := requested[item.ProductID]; !seen {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		requested[item.ProductID] += item.Quantity
+	}
+
+	products, err := lockProducts(tx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []OutOfStockItem
+	for _, item := range items {
+		product, ok := products[item.ProductID]
+		available := 0
+		name := item.Product.Name
+		if ok {
+			available = product.Stock
+			name = product.Name
+		}
+		if !ok || available < requested[item.ProductID] {
+			missing = append(missing, OutOfStockItem{
+				CartItemID:  item.ID,
+				ProductID:   item.ProductID,
+				ProductName: name,
+				Requested:   item.Quantity,
+				Available:   available,
+			})
+		}
+	}
+	if len(missing) > 0 {
+		return missing, ErrInsufficientStock
+	}
+
+	for _, id := range productIDs {
+		product := products[id]
+		product.Stock -= requested[id]
+		if err := tx.Model(product).Update("stock", product.Stock).Error; err != nil {
+			return nil, err
+		}
+
+		adjustment := models.StockAdjustment{
+			ProductID:  id,
+			Delta:      -requested[id],
+			StockAfter: product.Stock,
+			Reason:     models.StockReasonSale,
+			OrderID:    &orderID,
+		}
+		if err := tx.Create(&adjustment).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func AdjustStock(tx *gorm.DB, productID uint, delta int, reason, note string, adminID *uint) (*models.StockAdjustment, error) {
+	products, err := lockProducts(tx, []uint{productID})
+	if err != nil {
+		return nil, err
+	}
+	product, ok := products[productID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if product.Stock+delta < 0 {
+		return nil, fmt.Errorf("%w: product %d has %d in stock, cannot apply %d", ErrInsufficientStock, productID, product.Stock, delta)
+	}
+
+	product.Stock += delta
+	if err := tx.Model(product).Update("stock", product.Stock).Error; err != nil {
+		return nil, err
+	}
+
+	adjustment := models.StockAdjustment{
+		ProductID:  productID,
+		Delta:      delta,
+		StockAfter: product.Stock,
+		Reason:     reason,
+		Note:       note,
+		AdminID:    adminID,
+	}
+	if err := tx.Create(&adjustment).Error; err != nil {
+		return nil, err
+	}
+	return &adjustment, nil
+}
+
+// ReleaseOrderItems puts the quantities of a cancelled order back on the shelf.
+func ReleaseOrderItems(tx *gorm.DB, items []models.OrderItem, orderID uint) error {
+	released := make(map[uint]int)
+	var productIDs []uint
+	for _, item := range items {
+		if _, seen := released[item.ProductID]; !seen {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		released[item.ProductID] += item.Quantity
+	}
+
+	products, err := lockProducts(tx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range productIDs {
+		product, ok := products[id]
+		if !ok {
+			continue
+		}
+		product.Stock += released[id]
+		if err := tx.Model(product).Update("stock", product.Stock).Error; err != nil {
+			return err
+		}
+
+		adjustment := models.StockAdjustment{
+			ProductID:  id,
+			Delta:      released[id],
+			StockAfter: product.Stock,
+			Reason:     models.StockReasonCancelled,
+			OrderID:    &orderID,
+		}
+		if err := tx.Create(&adjustment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
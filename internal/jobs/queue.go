@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const DefaultMaxAttempts = 8
+
+var (
+	ErrNotFound     = errors.New("job not found")
+	ErrNotRetryable = errors.New("only dead or pending jobs can be retried")
+)
+
+// Handler performs one job. Returning an error schedules a retry with
+// backoff until the job runs out of attempts.
+type Handler func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error
+
+var handlers = map[string]Handler{}
+
+// Register makes jobType runnable by workers. It must be called before the
+// workers start.
+func Register(jobType string, handler Handler) {
+	if _, exists := handlers[jobType]; exists {
+		panic(fmt.Sprintf("jobs: handler for %q registered twice", jobType))
+	}
+	handlers[jobType] = handler
+}
+
+// Enqueue stores a job in tx, so it commits or rolls back together with the
+// change that caused it.
+func Enqueue(tx *gorm.DB, jobType string, payload interface{}) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s payload: %w", jobType, err)
+	}
+	job := models.Job{
+		Type:        jobType,
+		Payload:     datatypes.JSON(data),
+		Status:      models.JobStatusPending,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := tx.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Retry puts a dead job back in the queue with a fresh set of attempts.
+func Retry(db *gorm.DB, id uint) (*models.Job, error) {
+	var job models.Job
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(lockForUpdate).First(&job, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if job.Status != models.JobStatusDead && job.Status != models.JobStatusPending {
+			return ErrNotRetryable
+		}
+		job.Status = models.JobStatusPending
+		job.Attempts = 0
+		job.RunAt = time.Now()
+		job.LockedAt = nil
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  0,
+			"run_at":    job.RunAt,
+			"locked_at": nil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// PurgeSucceeded deletes finished jobs older than the retention period.
+func PurgeSucceeded(db *gorm.DB, olderThan time.Duration) error {
+	return db.Where("status = ? AND completed_at < ?", models.JobStatusSucceeded, time.Now().Add(-olderThan)).
+		Delete(&models.Job{}).Error
+}
@@ -0,0 +1,217 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	pollInterval = 2 * time.Second
+	// jobTimeout bounds one run of a handler. It stays well under lockTimeout
+	// so a slow job is never reclaimed while it is still running.
+	jobTimeout = 2 * time.Minute
+	// lockTimeout reclaims jobs left running by a worker that crashed.
+	lockTimeout = 10 * time.Minute
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+var lockForUpdate = clause.Locking{Strength: "UPDATE"}
+
+var errNoJob = errors.New("no job ready")
+
+// Backoff is the delay before attempt n+1: 30s, 1m, 2m, ... capped at 6h,
+// with up to 10% jitter so failed jobs do not retry in lockstep.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := maxBackoff
+	if attempts < 20 {
+		if d := baseBackoff << (attempts - 1); d < maxBackoff {
+			delay = d
+		}
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/10+1))
+}
+
+type Worker struct {
+	db          *gorm.DB
+	concurrency int
+	wg          sync.WaitGroup
+}
+
+func NewWorker(db *gorm.DB, concurrency int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Worker{db: db, concurrency: concurrency}
+}
+
+// Start launches the polling goroutines. Cancelling ctx stops them picking up
+// jobs and cancels the jobs in flight, which are retried later; Wait then
+// blocks until those handlers have returned.
+func (w *Worker) Start(ctx context.Context) {
+	for i := 0; i < w.concurrency; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	log.Printf("Job worker started with %d goroutine(s)", w.concurrency)
+}
+
+func (w *Worker) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain runs ready jobs one after another until none is left and reports how
+// many ran. It lets tests and one-off commands process the queue without
+// starting the polling goroutines.
+func (w *Worker) Drain(ctx context.Context) (int, error) {
+	ran := 0
+	for {
+		err := w.runNext(ctx)
+		if errors.Is(err, errNoJob) {
+			return ran, nil
+		}
+		if err != nil {
+			return ran, err
+		}
+		ran++
+	}
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		// Chạy liên tục khi còn job, chỉ nghỉ khi hàng đợi trống.
+		err := w.runNext(ctx)
+		if err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if !errors.Is(err, errNoJob) {
+			log.Printf("Job worker error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// claim locks the next ready job with SKIP LOCKED, so concurrent workers on
+// any number of instances each get a different row, and marks it running.
+func (w *Worker) claim() (*models.Job, error) {
+	var job models.Job
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)",
+				models.JobStatusPending, now, models.JobStatusRunning, now.Add(-lockTimeout)).
+			Order("run_at asc, id asc").
+			First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errNoJob
+		}
+		if err != nil {
+			return err
+		}
+
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (w *Worker) runNext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	job, err := w.claim()
+	if err != nil {
+		return err
+	}
+
+	runErr := w.execute(ctx, job)
+	return w.finish(job, runErr)
+}
+
+// execute runs the handler under ctx and jobTimeout; handlers pass the
+// context on to whatever they call, e.g. the mailer.
+func (w *Worker) execute(ctx context.Context, job *models.Job) (err error) {
+	handler, ok := handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler registered for job type %q", job.Type)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+	return handler(ctx, w.db.WithContext(ctx), json.RawMessage(job.Payload))
+}
+
+func (w *Worker) finish(job *models.Job, runErr error) error {
+	now := time.Now()
+	if runErr == nil {
+		return w.db.Model(job).Updates(map[string]interface{}{
+			"status":       models.JobStatusSucceeded,
+			"completed_at": now,
+			"locked_at":    nil,
+			"last_error":   "",
+		}).Error
+	}
+
+	updates := map[string]interface{}{
+		"locked_at":  nil,
+		"last_error": runErr.Error(),
+	}
+	if job.Attempts >= job.MaxAttempts {
+		updates["status"] = models.JobStatusDead
+		log.Printf("Job %d (%s) is dead after %d attempts: %v", job.ID, job.Type, job.Attempts, runErr)
+	} else {
+		updates["status"] = models.JobStatusPending
+		updates["run_at"] = now.Add(Backoff(job.Attempts))
+		log.Printf("Job %d (%s) failed attempt %d/%d: %v", job.ID, job.Type, job.Attempts, job.MaxAttempts, runErr)
+	}
+	return w.db.Model(job).Updates(updates).Error
+}
@@ -0,0 +1,147 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/jobs"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// Register panics on a second call, so the test handlers are registered once
+// per binary; failingRuns counts calls to the failing one.
+var (
+	registerTestJobs sync.Once
+	failingRuns      int
+)
+
+func useTestJobs() {
+	registerTestJobs.Do(func() {
+		jobs.Register("test.always_fails", func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+			failingRuns++
+			return errors.New("boom")
+		})
+		jobs.Register("test.succeeds", func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+			return nil
+		})
+		jobs.Register("test.needs_deadline", func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return errors.New("handler context has no deadline")
+			}
+			return nil
+		})
+	})
+	failingRuns = 0
+}
+
+func TestBackoffDoublesWithJitterUpToCap(t *testing.T) {
+	cases := []struct {
+		attempts int
+		base     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 4*time.Hour + 16*time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, c := range cases {
+		for i := 0; i < 20; i++ {
+			got := jobs.Backoff(c.attempts)
+			if got < c.base || got > c.base+c.base/10 {
+				t.Fatalf("Backoff(%d) = %v, want within [%v, %v]", c.attempts, got, c.base, c.base+c.base/10)
+			}
+		}
+	}
+}
+
+func TestFailedJobRetriesWithBackoffThenDies(t *testing.T) {
+	db := testdb.Open(t)
+	useTestJobs()
+
+	job, err := jobs.Enqueue(db, "test.always_fails", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(job).Update("max_attempts", 2)
+	worker := jobs.NewWorker(db, 1)
+	drain := func() {
+		t.Helper()
+		if _, err := worker.Drain(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	drain()
+	var saved models.Job
+	db.First(&saved, job.ID)
+	if saved.Status != models.JobStatusPending || saved.Attempts != 1 || saved.LastError != "boom" {
+		t.Fatalf("after first failure: status=%s attempts=%d error=%q", saved.Status, saved.Attempts, saved.LastError)
+	}
+	if wait := time.Until(saved.RunAt); wait < 25*time.Second {
+		t.Errorf("retry scheduled in %v, want about 30s", wait)
+	}
+
+	// Chưa tới giờ chạy lại: Drain không được chạy job.
+	drain()
+	if failingRuns != 1 {
+		t.Fatalf("job ran %d times before its backoff elapsed", failingRuns)
+	}
+
+	db.Model(&saved).Update("run_at", time.Now().Add(-time.Second))
+	drain()
+	db.First(&saved, job.ID)
+	if saved.Status != models.JobStatusDead || saved.Attempts != 2 {
+		t.Fatalf("after last attempt: status=%s attempts=%d, want dead after 2", saved.Status, saved.Attempts)
+	}
+
+	if _, err := jobs.Retry(db, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&saved, job.ID)
+	if saved.Status != models.JobStatusPending || saved.Attempts != 0 {
+		t.Errorf("after Retry: status=%s attempts=%d", saved.Status, saved.Attempts)
+	}
+}
+
+func TestRetryRejectsSucceededJob(t *testing.T) {
+	db := testdb.Open(t)
+	useTestJobs()
+	job, err := jobs.Enqueue(db, "test.succeeds", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := jobs.NewWorker(db, 1).Drain(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Drain = %d, %v", n, err)
+	}
+	if _, err := jobs.Retry(db, job.ID); !errors.Is(err, jobs.ErrNotRetryable) {
+		t.Errorf("Retry(succeeded) = %v, want ErrNotRetryable", err)
+	}
+}
+
+// Handler nhận context có timeout riêng cho từng job, không phải context.Background().
+func TestJobHandlerGetsATimeout(t *testing.T) {
+	db := testdb.Open(t)
+	useTestJobs()
+
+	job, err := jobs.Enqueue(db, "test.needs_deadline", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jobs.NewWorker(db, 1).Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var saved models.Job
+	db.First(&saved, job.ID)
+	if saved.Status != models.JobStatusSucceeded {
+		t.Errorf("job is %s (%q), want succeeded", saved.Status, saved.LastError)
+	}
+}
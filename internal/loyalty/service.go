@@ -1,50 +1,61 @@
 package loyalty
 
 import (
+	"errors"
 	"log"
 	"time"
 
 	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type VIPLevel struct {
     Level                   int
-    Threshold               float64
+    Threshold               money.Money
     Discount                float64
-    MaintenanceRequirement  float64
+    MaintenanceRequirement  money.Money
 }
 
 var VIPLevelMap = map[int]VIPLevel{
-    4: {Level: 4, Threshold: 8000000, Discount: 0.07, MaintenanceRequirement: 0},
-    3: {Level: 3, Threshold: 5000000, Discount: 0.05, MaintenanceRequirement: 5000000 * 0.3},
-    2: {Level: 2, Threshold: 2500000, Discount: 0.03, MaintenanceRequirement: 2500000 * 0.3},
-    1: {Level: 1, Threshold: 1000000, Discount: 0.02, MaintenanceRequirement: 1000000 * 0.3},
-    0: {Level: 0, Threshold: 0, Discount: 0, MaintenanceRequirement: 0},
+    4: {Level: 4, Threshold: money.FromVND(8000000), Discount: 0.07, MaintenanceRequirement: money.FromVND(0)},
+    3: {Level: 3, Threshold: money.FromVND(5000000), Discount: 0.05, MaintenanceRequirement: money.FromVND(5000000 * 3 / 10)},
+    2: {Level: 2, Threshold: money.FromVND(2500000), Discount: 0.03, MaintenanceRequirement: money.FromVND(2500000 * 3 / 10)},
+    1: {Level: 1, Threshold: money.FromVND(1000000), Discount: 0.02, MaintenanceRequirement: money.FromVND(1000000 * 3 / 10)},
+    0: {Level: 0, Threshold: money.FromVND(0), Discount: 0, MaintenanceRequirement: money.FromVND(0)},
 }
 
 var VIPLevelsSorted = []VIPLevel{
     VIPLevelMap[4], VIPLevelMap[3], VIPLevelMap[2], VIPLevelMap[1], VIPLevelMap[0],
 }
 
-func UpdateUserLoyaltyStatus(tx *gorm.DB, userID uint, orderAmount float64) error {
+func UpdateUserLoyaltyStatus(tx *gorm.DB, userID uint, orderAmount money.Money) error {
     var user models.User
     if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
         return err
     }
 
-    user.TotalSpent += orderAmount
-    user.MaintenanceSpending += orderAmount
+    var err error
+    if user.TotalSpent, err = user.TotalSpent.Add(orderAmount); err != nil {
+        return err
+    }
+    if user.MaintenanceSpending, err = user.MaintenanceSpending.Add(orderAmount); err != nil {
+        return err
+    }
 
     previousLevel := user.VIPLevel
 
     for _, level := range VIPLevelsSorted {
-        if user.TotalSpent >= level.Threshold && user.VIPLevel < level.Level {
+        reached, err := reaches(user.TotalSpent, level.Threshold)
+        if err != nil {
+            return err
+        }
+        if reached && user.VIPLevel < level.Level {
             user.VIPLevel = level.Level
             log.Printf("User ID %d được thăng hạng lên VIP %d", user.ID, user.VIPLevel)
             
-            user.MaintenanceSpending = 0
+            user.MaintenanceSpending = money.FromVND(0)
             if level.Level < 4 {
                 newExpiryDate := time.Now().AddDate(0, 3, 0)
                 user.VIPExpiryDate = &newExpiryDate
@@ -57,9 +68,13 @@ func UpdateUserLoyaltyStatus(tx *gorm.DB, userID uint, orderAmount float64) erro
 
     if user.VIPLevel == previousLevel && user.VIPLevel > 0 && user.VIPLevel < 4 {
         currentLevelInfo := GetVIPLevelInfo(user.VIPLevel)
-        if user.MaintenanceSpending >= currentLevelInfo.MaintenanceRequirement {
+        maintained, err := reaches(user.MaintenanceSpending, currentLevelInfo.MaintenanceRequirement)
+        if err != nil {
+            return err
+        }
+        if maintained {
             log.Printf("User ID %d đã duy trì thành công hạng VIP %d", user.ID, user.VIPLevel)
-            user.MaintenanceSpending = 0
+            user.MaintenanceSpending = money.FromVND(0)
             newExpiryDate := time.Now().AddDate(0, 3, 0)
             user.VIPExpiryDate = &newExpiryDate
         }
@@ -70,35 +85,194 @@ func UpdateUserLoyaltyStatus(tx *gorm.DB, userID uint, orderAmount float64) erro
     return tx.Save(&user).Error
 }
 
-func CheckAndApplyDemotions(db *gorm.DB) {
-    log.Println("Bắt đầu chạy tác vụ kiểm tra và hạ cấp VIP...")
+// AccrueOrder counts a paid order towards the owner's VIP progress. It is a
+// no-op for orders that already accrued, so replays never double count.
+func AccrueOrder(tx *gorm.DB, order *models.Order) error {
+    if order.LoyaltyAccruedAt != nil {
+        return nil
+    }
+    if err := UpdateUserLoyaltyStatus(tx, order.UserID, order.TotalAmount); err != nil {
+        return err
+    }
+    now := time.Now()
+    order.LoyaltyAccruedAt = &now
+    return tx.Model(order).Update("loyalty_accrued_at", now).Error
+}
+
+// ReverseOrder takes a cancelled or refunded order back out of the owner's
+// spending. A user who no longer reaches their level's threshold drops to the
+// highest level their remaining spending still qualifies for.
+func ReverseOrder(tx *gorm.DB, order *models.Order) error {
+    if order.LoyaltyAccruedAt == nil {
+        return nil
+    }
+
+    var user models.User
+    if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, order.UserID).Error; err != nil {
+        return err
+    }
+
+    totalSpent, err := user.TotalSpent.Sub(order.TotalAmount)
+    if err != nil {
+        return err
+    }
+    if totalSpent.IsNegative() {
+        totalSpent = money.FromVND(0)
+    }
+    maintenance, err := user.MaintenanceSpending.Sub(order.TotalAmount)
+    if err != nil {
+        return err
+    }
+    if maintenance.IsNegative() {
+        maintenance = money.FromVND(0)
+    }
+
+    updates := map[string]interface{}{
+        "total_spent_minor":             totalSpent.Amount,
+        "total_spent_currency":          money.VND,
+        "maintenance_spending_minor":    maintenance.Amount,
+        "maintenance_spending_currency": money.VND,
+    }
+    kept, err := reaches(totalSpent, GetVIPLevelInfo(user.VIPLevel).Threshold)
+    if err != nil {
+        return err
+    }
+    if user.VIPLevel > 0 && !kept {
+        level := 0
+        for _, l := range VIPLevelsSorted {
+            if ok, _ := reaches(totalSpent, l.Threshold); ok {
+                level = l.Level
+                break
+            }
+        }
+        log.Printf("User ID %d bị hạ từ VIP %d xuống VIP %d do đơn #%d bị hủy/hoàn tiền", user.ID, user.VIPLevel, level, order.ID)
+        updates["v_ip_level"] = level
+        updates["discount_percentage"] = GetVIPLevelInfo(level).Discount
+        if level == 0 {
+            updates["v_ip_expiry_date"] = nil
+        }
+    }
+    if err := tx.Model(&user).Updates(updates).Error; err != nil {
+        return err
+    }
+
+    order.LoyaltyAccruedAt = nil
+    return tx.Model(order).Update("loyalty_accrued_at", nil).Error
+}
+
+type Demotion struct {
+    UserID    uint
+    Username  string
+    FromLevel int
+    ToLevel   int
+    ExpiredAt time.Time
+}
+
+// demotionColumns are the only columns a demotion writes, so it cannot undo
+// spending that an order accrued while the job was running.
+var demotionColumns = []string{
+    "v_ip_level", "v_ip_expiry_date", "discount_percentage",
+    "maintenance_spending_minor", "maintenance_spending_currency", "updated_at",
+}
+
+// ApplyDemotions drops every user whose VIP period has expired by one level.
+// With dryRun nothing is written; the returned list is what would change.
+func ApplyDemotions(db *gorm.DB, dryRun bool) ([]Demotion, error) {
     now := time.Now()
     var expiredUsers []models.User
 
-    db.Where("vip_level > 0 AND vip_level < 4 AND vip_expiry_date < ?", now).Find(&expiredUsers)
+    if err := db.Where(demotionDue, now).Find(&expiredUsers).Error; err != nil {
+        return nil, err
+    }
 
-    if len(expiredUsers) == 0 {
-        log.Println("Không có user nào bị hạ cấp.")
-        return
+    var demotions []Demotion
+    for _, candidate := range expiredUsers {
+        if dryRun {
+            demotions = append(demotions, newDemotion(candidate))
+            continue
+        }
+
+        demotion, err := DemoteIfExpired(db, candidate.ID)
+        if err != nil {
+            return demotions, err
+        }
+        if demotion != nil {
+            demotions = append(demotions, *demotion)
+        }
     }
+    return demotions, nil
+}
+
+// DemoteIfExpired drops the user one level if their VIP period has run out,
+// and returns nil when there was nothing to do. Both the cron job and the
+// lazy check on profile reads go through here.
+func DemoteIfExpired(db *gorm.DB, userID uint) (*Demotion, error) {
+    var demotion *Demotion
+    err := db.Transaction(func(tx *gorm.DB) error {
+        // Khóa dòng và kiểm tra lại: đơn hàng vừa thanh toán có thể đã gia hạn VIP.
+        var user models.User
+        err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(demotionDue, time.Now()).First(&user, userID).Error
+        if errors.Is(err, gorm.ErrRecordNotFound) {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
 
-    for _, user := range expiredUsers {
-        log.Printf("User ID %d (VIP %d) đã hết hạn. Bị hạ cấp xuống VIP %d.", user.ID, user.VIPLevel, user.VIPLevel-1)
+        d := newDemotion(user)
         user.VIPLevel--
-        
-        user.MaintenanceSpending = 0
+        user.MaintenanceSpending = money.FromVND(0)
         if user.VIPLevel > 0 {
             newExpiryDate := time.Now().AddDate(0, 3, 0)
             user.VIPExpiryDate = &newExpiryDate
         } else {
             user.VIPExpiryDate = nil
         }
-        
         user.DiscountPercentage = GetVIPLevelInfo(user.VIPLevel).Discount
-        
-        db.Save(&user)
+
+        if err := tx.Model(&user).Select(demotionColumns).Updates(&user).Error; err != nil {
+            return err
+        }
+        demotion = &d
+        return nil
+    })
+    return demotion, err
+}
+
+const demotionDue = "v_ip_level > 0 AND v_ip_level < 4 AND v_ip_expiry_date < ?"
+
+func newDemotion(user models.User) Demotion {
+    return Demotion{
+        UserID:    user.ID,
+        Username:  user.Username,
+        FromLevel: user.VIPLevel,
+        ToLevel:   user.VIPLevel - 1,
+        ExpiredAt: *user.VIPExpiryDate,
+    }
+}
+
+func CheckAndApplyDemotions(db *gorm.DB) {
+    log.Println("Bắt đầu chạy tác vụ kiểm tra và hạ cấp VIP...")
+    demotions, err := ApplyDemotions(db, false)
+    for _, d := range demotions {
+        log.Printf("User ID %d (VIP %d) đã hết hạn. Bị hạ cấp xuống VIP %d.", d.UserID, d.FromLevel, d.ToLevel)
+    }
+    if err != nil {
+        log.Printf("Lỗi khi hạ cấp VIP: %v", err)
+        return
     }
-    log.Printf("Hoàn thành tác vụ hạ cấp cho %d user.", len(expiredUsers))
+
+    if len(demotions) == 0 {
+        log.Println("Không có user nào bị hạ cấp.")
+        return
+    }
+    log.Printf("Hoàn thành tác vụ hạ cấp cho %d user.", len(demotions))
+}
+
+// reaches reports whether amount is at least threshold.
+func reaches(amount, threshold money.Money) (bool, error) {
+    cmp, err := amount.Cmp(threshold)
+    return cmp >= 0, err
 }
 
 func GetVIPLevelInfo(level int) VIPLevel {
@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/kaelCoding/toyBE/internal/config"
+)
+
+// Message is a rendered email. Text is the plain-text alternative shown by
+// clients that do not render HTML.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers messages through one transport.
+type Mailer interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromConfig builds the transport selected by EMAIL_TRANSPORT. config.Load
+// has already resolved an empty choice or rejected it, so there is no
+// fallback here.
+func FromConfig(cfg config.EmailConfig) (Mailer, error) {
+	switch cfg.Transport {
+	case config.EmailTransportResend:
+		return NewResendMailer(cfg.ResendAPIKey), nil
+	case config.EmailTransportSMTP:
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		}, nil
+	case config.EmailTransportFile:
+		log.Printf("Emails are written to %s instead of being sent", cfg.OutboxDir)
+		return &FileOutbox{Dir: cfg.OutboxDir}, nil
+	case config.EmailTransportMemory:
+		return &MemoryOutbox{}, nil
+	default:
+		return nil, fmt.Errorf("unknown EMAIL_TRANSPORT %q", cfg.Transport)
+	}
+}
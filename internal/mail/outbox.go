@@ -0,0 +1,69 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// FileOutbox writes every message as an .eml file that any mail client can
+// open. It is meant for local development.
+type FileOutbox struct {
+	Dir string
+}
+
+func (o *FileOutbox) Name() string { return "file" }
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (o *FileOutbox) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(o.Dir, 0o755); err != nil {
+		return fmt.Errorf("creating outbox: %w", err)
+	}
+
+	to := ""
+	if len(msg.To) > 0 {
+		to = unsafeFileChars.ReplaceAllString(msg.To[0], "_")
+	}
+	body, err := buildMIME(msg)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102-150405.000000"), to)
+	if err := os.WriteFile(filepath.Join(o.Dir, name), body, 0o644); err != nil {
+		return fmt.Errorf("writing outbox message: %w", err)
+	}
+	return nil
+}
+
+// MemoryOutbox keeps sent messages in memory so tests can assert on them.
+type MemoryOutbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (o *MemoryOutbox) Name() string { return "memory" }
+
+func (o *MemoryOutbox) Send(ctx context.Context, msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far.
+func (o *MemoryOutbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message(nil), o.messages...)
+}
+
+func (o *MemoryOutbox) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = nil
+}
@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/resend/resend-go/v2"
+)
+
+type ResendMailer struct {
+	client *resend.Client
+}
+
+func NewResendMailer(apiKey string) *ResendMailer {
+	return &ResendMailer{client: resend.NewClient(apiKey)}
+}
+
+func (m *ResendMailer) Name() string { return "resend" }
+
+func (m *ResendMailer) Send(ctx context.Context, msg Message) error {
+	sent, err := m.client.Emails.SendWithContext(ctx, &resend.SendEmailRequest{
+		From:    msg.From,
+		To:      msg.To,
+		Subject: msg.Subject,
+		Html:    msg.HTML,
+		Text:    msg.Text,
+	})
+	if err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	log.Printf("Email sent successfully to %v, ID: %s\n", msg.To, sent.Id)
+	return nil
+}
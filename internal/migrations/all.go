@@ -0,0 +1,220 @@
+package migrations
+
+import (
+	_ "embed"
+
+	"github.com/kaelCoding/toyBE/internal/database"
+)
+
+//go:embed sql/0001_baseline.sql
+var baselineSQL string
+
+// all is the ordered history of the schema. Never edit or renumber a
+// migration once it has shipped; add a new one instead.
+var all = []Migration{
+	{
+		// Frozen DDL of the schema AutoMigrate used to build on every boot.
+		// Databases created by AutoMigrate already match it, so it only fills gaps there.
+		Version: 1,
+		Name:    "baseline",
+		Up:      execScript(baselineSQL),
+		Down:    irreversible,
+	},
+	{
+		Version: 2,
+		Name:    "backfill_money_columns",
+		Up:      database.BackfillMoneyColumns,
+		Down:    exec(),
+	},
+	{
+		Version: 3,
+		Name:    "grandfather_email_verification",
+		Up:      database.GrandfatherEmailVerification,
+		Down:    exec(),
+	},
+	{
+		Version: 4,
+		Name:    "drop_legacy_money_columns",
+		Up:      database.DropLegacyMoneyColumns,
+		Down:    database.RestoreLegacyMoneyColumns,
+	},
+	{
+		Version: 5,
+		Name:    "quantity_check_constraints",
+		Up: steps(
+			requireNone("products", "stock < 0", "have negative stock; correct them with a stock adjustment"),
+			requireNone("rewards", "quantity < -1", "have a quantity below -1 (the unlimited marker)"),
+			requireNone("order_items", "quantity <= 0", "have a non-positive quantity"),
+			// Giỏ hàng với số lượng <= 0 chỉ là rác, xóa luôn thay vì bắt admin sửa tay.
+			exec(`DELETE FROM cart_items WHERE quantity <= 0`),
+			exec(
+				`ALTER TABLE products ADD CONSTRAINT products_stock_non_negative CHECK (stock >= 0)`,
+				`ALTER TABLE rewards ADD CONSTRAINT rewards_quantity_valid CHECK (quantity >= -1)`,
+				`ALTER TABLE cart_items ADD CONSTRAINT cart_items_quantity_positive CHECK (quantity > 0)`,
+				`ALTER TABLE order_items ADD CONSTRAINT order_items_quantity_positive CHECK (quantity > 0)`,
+			),
+		),
+		Down: exec(
+			`ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_quantity_positive`,
+			`ALTER TABLE cart_items DROP CONSTRAINT IF EXISTS cart_items_quantity_positive`,
+			`ALTER TABLE rewards DROP CONSTRAINT IF EXISTS rewards_quantity_valid`,
+			`ALTER TABLE products DROP CONSTRAINT IF EXISTS products_stock_non_negative`,
+		),
+	},
+	{
+		Version: 6,
+		Name:    "create_jobs",
+		Up: exec(
+			`CREATE TABLE jobs (
+				id bigserial PRIMARY KEY,
+				type varchar(64) NOT NULL,
+				payload jsonb NOT NULL,
+				status varchar(16) NOT NULL DEFAULT 'pending',
+				attempts integer NOT NULL DEFAULT 0,
+				max_attempts integer NOT NULL DEFAULT 8,
+				run_at timestamptz NOT NULL,
+				locked_at timestamptz,
+				last_error text NOT NULL DEFAULT '',
+				completed_at timestamptz,
+				created_at timestamptz,
+				updated_at timestamptz
+			)`,
+			`CREATE INDEX idx_jobs_type ON jobs (type)`,
+			`CREATE INDEX idx_jobs_status ON jobs (status)`,
+			`CREATE INDEX idx_jobs_ready ON jobs (run_at, id) WHERE status = 'pending'`,
+		),
+		Down: exec(`DROP TABLE IF EXISTS jobs`),
+	},
+	{
+		// Đơn cũ được tạo thẳng ở trạng thái "completed" (hoặc mặc định "pending")
+		// và đã cộng điểm VIP ngay lúc đặt; ghi lại điều đó để không cộng lần hai.
+		Version: 7,
+		Name:    "order_loyalty_accrual_and_legacy_statuses",
+		Up: exec(
+			`ALTER TABLE orders ADD COLUMN loyalty_accrued_at timestamptz`,
+			`UPDATE orders SET loyalty_accrued_at = created_at`,
+			`INSERT INTO order_status_histories (created_at, updated_at, order_id, from_status, to_status, note)
+				SELECT now(), now(), id, status, 'delivered', 'legacy status migrated' FROM orders WHERE status = 'completed'`,
+			`UPDATE orders SET status = 'delivered' WHERE status = 'completed'`,
+			`INSERT INTO order_status_histories (created_at, updated_at, order_id, from_status, to_status, note)
+				SELECT now(), now(), id, status, 'pending_payment', 'legacy status migrated' FROM orders WHERE status = 'pending'`,
+			`UPDATE orders SET status = 'pending_payment' WHERE status = 'pending'`,
+			`ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'pending_payment'`,
+		),
+		Down: exec(`ALTER TABLE orders DROP COLUMN IF EXISTS loyalty_accrued_at`),
+	},
+	{
+		// Tỷ giá mặc định trước đây được tạo lúc GET /exchange-rate đầu tiên.
+		Version: 8,
+		Name:    "seed_default_exchange_rate",
+		Up: exec(
+			`INSERT INTO exchange_rates (created_at, updated_at, base_currency, quote_currency, rate, service_fee_percent, source, effective_at)
+				SELECT now(), now(), 'JPY', 'VND', 185, 0.05, 'default', now()
+				WHERE NOT EXISTS (SELECT 1 FROM exchange_rates WHERE base_currency = 'JPY' AND quote_currency = 'VND' AND deleted_at IS NULL)`,
+		),
+		Down: exec(),
+	},
+	{
+		// Phương thức thanh toán được chuẩn hóa (chữ thường, bỏ khoảng trắng) khi đặt hàng;
+		// mỗi đơn chỉ có tối đa một thanh toán đang chờ.
+		Version: 9,
+		Name:    "normalize_payment_methods_and_single_pending_payment",
+		Up: exec(
+			`UPDATE orders SET payment_method = lower(btrim(payment_method)) WHERE payment_method <> lower(btrim(payment_method))`,
+			`UPDATE proxy_orders SET payment_method = lower(btrim(payment_method)) WHERE payment_method <> lower(btrim(payment_method))`,
+			// Thanh toán chờ cũ hơn bị thay bằng lượt mới nhất; callback đến muộn vẫn được nhận.
+			`UPDATE payments SET status = 'expired' WHERE status = 'pending' AND order_id IS NOT NULL AND id NOT IN
+				(SELECT max(id) FROM payments WHERE status = 'pending' AND order_id IS NOT NULL GROUP BY order_id)`,
+			`UPDATE payments SET status = 'expired' WHERE status = 'pending' AND proxy_order_id IS NOT NULL AND id NOT IN
+				(SELECT max(id) FROM payments WHERE status = 'pending' AND proxy_order_id IS NOT NULL GROUP BY proxy_order_id)`,
+			`CREATE UNIQUE INDEX idx_payments_one_pending_per_order ON payments (order_id) WHERE status = 'pending' AND order_id IS NOT NULL`,
+			`CREATE UNIQUE INDEX idx_payments_one_pending_per_proxy_order ON payments (proxy_order_id) WHERE status = 'pending' AND proxy_order_id IS NOT NULL`,
+		),
+		Down: exec(
+			`DROP INDEX IF EXISTS idx_payments_one_pending_per_proxy_order`,
+			`DROP INDEX IF EXISTS idx_payments_one_pending_per_order`,
+		),
+	},
+	{
+		// active=false từng bị default true của cột nuốt mất; mã của coupon đã xóa được dùng lại.
+		Version: 10,
+		Name:    "coupon_active_without_default_and_live_code_index",
+		Up: exec(
+			`UPDATE coupons SET active = true WHERE active IS NULL`,
+			`ALTER TABLE coupons ALTER COLUMN active DROP DEFAULT, ALTER COLUMN active SET NOT NULL`,
+			`DROP INDEX IF EXISTS idx_coupons_code`,
+			`CREATE UNIQUE INDEX idx_coupons_code ON coupons (code) WHERE deleted_at IS NULL`,
+		),
+		Down: steps(
+			requireNone("coupons", "deleted_at IS NOT NULL AND code IN (SELECT code FROM coupons GROUP BY code HAVING count(*) > 1)",
+				"are deleted coupons whose code was reused; rename or purge them first"),
+			exec(
+				`DROP INDEX IF EXISTS idx_coupons_code`,
+				`CREATE UNIQUE INDEX idx_coupons_code ON coupons (code)`,
+				`ALTER TABLE coupons ALTER COLUMN active DROP NOT NULL, ALTER COLUMN active SET DEFAULT true`,
+			),
+		),
+	},
+	{
+		Version: 11,
+		Name:    "spin_campaign_active_without_default",
+		Up: exec(
+			`UPDATE spin_campaigns SET active = true WHERE active IS NULL`,
+			`ALTER TABLE spin_campaigns ALTER COLUMN active DROP DEFAULT, ALTER COLUMN active SET NOT NULL`,
+		),
+		Down: exec(`ALTER TABLE spin_campaigns ALTER COLUMN active DROP NOT NULL, ALTER COLUMN active SET DEFAULT true`),
+	},
+	{
+		Version: 12,
+		Name:    "case_insensitive_unique_username_and_email",
+		Up: steps(
+			requireNone("users", "LOWER(email) IN (SELECT LOWER(email) FROM users GROUP BY LOWER(email) HAVING count(*) > 1)",
+				"share an email that differs only in case; merge or rename them first"),
+			requireNone("users", "LOWER(username) IN (SELECT LOWER(username) FROM users GROUP BY LOWER(username) HAVING count(*) > 1)",
+				"share a username that differs only in case; rename them first"),
+			exec(
+				`CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email))`,
+				`CREATE UNIQUE INDEX idx_users_username_lower ON users (LOWER(username))`,
+			),
+		),
+		Down: exec(
+			`DROP INDEX IF EXISTS idx_users_username_lower`,
+			`DROP INDEX IF EXISTS idx_users_email_lower`,
+		),
+	},
+	{
+		Version: 13,
+		Name:    "create_login_failures",
+		Up: exec(
+			`CREATE TABLE login_failures (
+				id bigserial PRIMARY KEY,
+				email varchar(254) NOT NULL,
+				failed_attempts integer NOT NULL DEFAULT 0,
+				locked_until timestamptz,
+				updated_at timestamptz
+			)`,
+			`CREATE UNIQUE INDEX idx_login_failures_email ON login_failures (email)`,
+		),
+		Down: exec(`DROP TABLE IF EXISTS login_failures`),
+	},
+	{
+		Version: 14,
+		Name:    "add_order_shipping_fee_and_amount_due",
+		Up: exec(
+			`ALTER TABLE orders
+				ADD COLUMN shipping_fee_minor bigint NOT NULL DEFAULT 0,
+				ADD COLUMN shipping_fee_currency varchar(3),
+				ADD COLUMN amount_due_minor bigint NOT NULL DEFAULT 0,
+				ADD COLUMN amount_due_currency varchar(3)`,
+			// Đơn cũ: phí ship tính theo hạng VIP hiện tại, như trước đây.
+			`UPDATE orders SET shipping_fee_minor = CASE WHEN users.v_ip_level >= 2 THEN 0 ELSE 50000 END, shipping_fee_currency = 'VND'
+				FROM users WHERE users.id = orders.user_id`,
+			`UPDATE orders SET amount_due_minor = total_amount_minor + shipping_fee_minor, amount_due_currency = 'VND'`,
+		),
+		Down: exec(`ALTER TABLE orders
+			DROP COLUMN IF EXISTS amount_due_currency,
+			DROP COLUMN IF EXISTS amount_due_minor,
+			DROP COLUMN IF EXISTS shipping_fee_currency,
+			DROP COLUMN IF EXISTS shipping_fee_minor`),
+	},
+}
@@ -0,0 +1,273 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// advisoryLockKey serialises migration runs across instances starting at the
+// same time. The value is arbitrary but must stay fixed.
+const advisoryLockKey = 7301442019
+
+var (
+	ErrSchemaBehind   = errors.New("database schema is behind the application")
+	ErrIrreversible   = errors.New("migration cannot be reverted")
+	ErrUnknownVersion = errors.New("database has migrations this binary does not know about")
+	ErrDataViolation  = errors.New("existing data must be fixed before this migration can run")
+)
+
+// Migration is one schema step. Up and Down each run inside their own
+// transaction together with the schema_migrations bookkeeping.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration is a row of the schema_migrations table.
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+// exec builds an Up/Down step from plain SQL statements.
+func exec(statements ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// execScript runs a SQL file statement by statement. Statements end with a
+// semicolon at the end of a line, so DO blocks can keep theirs inline.
+func execScript(script string) func(tx *gorm.DB) error {
+	var statements []string
+	for _, stmt := range strings.Split(script, ";\n") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+	return exec(statements...)
+}
+
+func steps(fns ...func(tx *gorm.DB) error) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, fn := range fns {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// requireNone fails with a readable message when rows of table match where,
+// so a constraint is never added on top of data that violates it.
+func requireNone(table, where, problem string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		var ids []int64
+		if err := tx.Table(table).Where(where).Order("id").Limit(10).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		var count int64
+		if err := tx.Table(table).Where(where).Count(&count).Error; err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %d row(s) of %s %s (first ids: %v)", ErrDataViolation, count, table, problem, ids)
+	}
+}
+
+func irreversible(tx *gorm.DB) error {
+	return ErrIrreversible
+}
+
+func sorted() []Migration {
+	list := append([]Migration(nil), all...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+func ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`).Error
+}
+
+func applied(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := db.Order("version asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+func lock(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", advisoryLockKey).Error
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it ran. It stops at the first failure; earlier migrations stay applied.
+func Up(db *gorm.DB) ([]Migration, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, m := range sorted() {
+		m := m
+		didRun := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&SchemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			didRun = true
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		if didRun {
+			ran = append(ran, m)
+		}
+	}
+	return ran, nil
+}
+
+// Down reverts the latest steps applied migrations, newest first.
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]Migration)
+	for _, m := range all {
+		known[m.Version] = m
+	}
+
+	var reverted []Migration
+	for i := 0; i < steps; i++ {
+		var m Migration
+		done := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			var latest SchemaMigration
+			err := tx.Order("version desc").First(&latest).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				done = true
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			var ok bool
+			if m, ok = known[latest.Version]; !ok {
+				return fmt.Errorf("%w: version %d", ErrUnknownVersion, latest.Version)
+			}
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, latest.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("reverting migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		if done {
+			break
+		}
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
+
+// Statuses lists every known migration with its applied time, if any.
+func Statuses(db *gorm.DB) ([]Status, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Status
+	for _, m := range sorted() {
+		status := Status{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Pending returns the migrations not yet applied to db.
+func Pending(db *gorm.DB) ([]Migration, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range sorted() {
+		if _, ok := done[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// CheckCurrent fails with ErrSchemaBehind when migrations are pending, so the
+// server never runs against a schema older than its models.
+func CheckCurrent(db *gorm.DB) error {
+	pending, err := Pending(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending, first is %d_%s", ErrSchemaBehind, len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBaselineScriptSplitsIntoStatements(t *testing.T) {
+	var statements []string
+	for _, stmt := range strings.Split(baselineSQL, ";\n") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+
+	if len(statements) < 50 {
+		t.Fatalf("baseline split into %d statements, want the full schema", len(statements))
+	}
+	for _, stmt := range statements {
+		if strings.HasPrefix(stmt, "DO $$") && !strings.HasSuffix(stmt, "END $$") {
+			t.Errorf("DO block was split apart: %q", stmt)
+		}
+	}
+}
+
+func TestVersionsAreUniqueAndOrdered(t *testing.T) {
+	seen := make(map[int64]bool)
+	var last int64
+	for _, m := range all {
+		if seen[m.Version] {
+			t.Errorf("version %d is used twice", m.Version)
+		}
+		if m.Version <= last {
+			t.Errorf("version %d is listed after %d", m.Version, last)
+		}
+		if m.Up == nil || m.Down == nil {
+			t.Errorf("migration %d_%s needs both Up and Down", m.Version, m.Name)
+		}
+		seen[m.Version] = true
+		last = m.Version
+	}
+}
@@ -0,0 +1,130 @@
+package migrations_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kaelCoding/toyBE/internal/migrations"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+var allModels = []any{
+	&models.User{}, &models.Product{}, &models.Category{}, &models.Message{}, &models.Order{},
+	&models.OrderItem{}, &models.Reward{}, &models.SpinLog{}, &models.ProxyOrder{}, &models.Cart{},
+	&models.CartItem{}, &models.StockAdjustment{}, &models.OrderStatusHistory{}, &models.ExchangeRate{},
+	&models.Payment{}, &models.Coupon{}, &models.CouponRedemption{}, &models.UserVoucher{},
+	&models.SpinCampaign{}, &models.RefreshToken{}, &models.RevokedAccessToken{}, &models.UserToken{},
+	&models.UserRole{}, &models.BackupCode{}, &models.Job{},
+}
+
+// A fresh database built only from migrations must have every column the
+// models read and write; otherwise a model change is missing its migration.
+func TestMigratedSchemaCoversModels(t *testing.T) {
+	db := testdb.Open(t)
+
+	for _, model := range allModels {
+		stmt := db.Model(model).Statement
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		if !db.Migrator().HasTable(model) {
+			t.Errorf("table %s is missing", stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			if !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("column %s.%s is missing", stmt.Schema.Table, field.DBName)
+			}
+		}
+		for _, rel := range stmt.Schema.Relationships.Many2Many {
+			if rel.JoinTable != nil && !db.Migrator().HasTable(rel.JoinTable.Table) {
+				t.Errorf("join table %s is missing", rel.JoinTable.Table)
+			}
+		}
+	}
+}
+
+func TestUpIsIdempotent(t *testing.T) {
+	db := testdb.Open(t)
+
+	ran, err := migrations.Up(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 0 {
+		t.Fatalf("second Up ran %d migrations", len(ran))
+	}
+	if err := migrations.CheckCurrent(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBaselineUpgradesPreMigrationDatabase(t *testing.T) {
+	db := testdb.OpenEmpty(t)
+
+	// Một bảng users kiểu cũ, trước khi có cột money và 2FA.
+	if err := db.Exec(`CREATE TABLE users (
+		id bigserial PRIMARY KEY, created_at timestamptz, updated_at timestamptz, deleted_at timestamptz,
+		username text NOT NULL UNIQUE, email text NOT NULL UNIQUE, password text NOT NULL,
+		admin boolean DEFAULT false, total_spent decimal DEFAULT 0, v_ip_level bigint DEFAULT 0,
+		v_ip_expiry_date timestamptz, maintenance_spending decimal DEFAULT 0, discount_percentage decimal DEFAULT 0
+	)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO users (username, email, password, total_spent) VALUES ('old', 'old@example.com', 'x', 1500000)`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", "old").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.TotalSpent.Amount != 1500000 || user.TotalSpent.Currency != "VND" {
+		t.Errorf("total spent = %+v, want 1500000 VND backfilled", user.TotalSpent)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("existing user should be grandfathered as verified")
+	}
+}
+
+func TestQuantityConstraintsRefuseViolatingRows(t *testing.T) {
+	db := testdb.Open(t)
+
+	// Quay về trước migration 5 để chạy lại nó trên dữ liệu sai.
+	statuses, err := migrations.Statuses(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for _, s := range statuses {
+		if s.Version >= 5 {
+			steps++
+		}
+	}
+	if _, err := migrations.Down(db, steps); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Exec(`INSERT INTO products (name, stock) VALUES ('oversold', -2)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrations.Up(db)
+	if !errors.Is(err, migrations.ErrDataViolation) {
+		t.Fatalf("Up over negative stock returned %v, want ErrDataViolation", err)
+	}
+
+	if err := db.Exec(`UPDATE products SET stock = 0`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("Up after fixing the data: %v", err)
+	}
+}
@@ -0,0 +1,460 @@
+-- Schema as of the first versioned migration, frozen so that later model
+-- changes never leak into it. Every statement is idempotent: databases that
+-- predate versioned migrations (built by AutoMigrate) are brought up to this
+-- schema, fresh databases are created from scratch.
+
+CREATE TABLE IF NOT EXISTS "users" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "users"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "username" text NOT NULL,
+	ADD COLUMN IF NOT EXISTS "email" text NOT NULL,
+	ADD COLUMN IF NOT EXISTS "password" text NOT NULL,
+	ADD COLUMN IF NOT EXISTS "admin" boolean DEFAULT false,
+	ADD COLUMN IF NOT EXISTS "total_spent_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "total_spent_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "v_ip_level" bigint DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "v_ip_expiry_date" timestamptz,
+	ADD COLUMN IF NOT EXISTS "maintenance_spending_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "maintenance_spending_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "discount_percentage" decimal DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "tokens_valid_after" timestamptz,
+	ADD COLUMN IF NOT EXISTS "email_verified_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "failed_login_attempts" bigint DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "locked_until" timestamptz,
+	ADD COLUMN IF NOT EXISTS "totp_secret" varchar(64),
+	ADD COLUMN IF NOT EXISTS "totp_enabled" boolean DEFAULT false,
+	ADD COLUMN IF NOT EXISTS "totp_last_step" bigint DEFAULT 0;
+CREATE INDEX IF NOT EXISTS "idx_users_username" ON "users" ("username");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+DO $$ BEGIN ALTER TABLE "users" ADD CONSTRAINT "uni_users_username" UNIQUE ("username"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "users" ADD CONSTRAINT "uni_users_email" UNIQUE ("email"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "products" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "products"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "name" text,
+	ADD COLUMN IF NOT EXISTS "description" text,
+	ADD COLUMN IF NOT EXISTS "price_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "price_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "stock" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "image_urls" JSONB;
+CREATE INDEX IF NOT EXISTS "idx_products_deleted_at" ON "products" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "categories" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "categories"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "name" varchar(255),
+	ADD COLUMN IF NOT EXISTS "description" varchar(255);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_categories_name" ON "categories" ("name");
+CREATE INDEX IF NOT EXISTS "idx_categories_deleted_at" ON "categories" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "product_categories" ("category_id" bigint, "product_id" bigint, PRIMARY KEY ("category_id","product_id"));
+DO $$ BEGIN ALTER TABLE "product_categories" ADD CONSTRAINT "fk_product_categories_category" FOREIGN KEY ("category_id") REFERENCES "categories"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "product_categories" ADD CONSTRAINT "fk_product_categories_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "messages" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "messages"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "sender_id" bigint,
+	ADD COLUMN IF NOT EXISTS "receiver_id" bigint,
+	ADD COLUMN IF NOT EXISTS "content" text,
+	ADD COLUMN IF NOT EXISTS "read" boolean DEFAULT false,
+	ADD COLUMN IF NOT EXISTS "timestamp" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_messages_deleted_at" ON "messages" ("deleted_at");
+DO $$ BEGIN ALTER TABLE "messages" ADD CONSTRAINT "fk_messages_sender" FOREIGN KEY ("sender_id") REFERENCES "users"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "messages" ADD CONSTRAINT "fk_messages_receiver" FOREIGN KEY ("receiver_id") REFERENCES "users"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "orders" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "orders"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint,
+	ADD COLUMN IF NOT EXISTS "total_amount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "total_amount_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "original_amount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "original_amount_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "discount_applied_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "discount_applied_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "coupon_id" bigint,
+	ADD COLUMN IF NOT EXISTS "coupon_code" varchar(64),
+	ADD COLUMN IF NOT EXISTS "coupon_discount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "coupon_discount_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "voucher_id" bigint,
+	ADD COLUMN IF NOT EXISTS "voucher_code" varchar(32),
+	ADD COLUMN IF NOT EXISTS "voucher_discount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "voucher_discount_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "status" text DEFAULT 'pending_payment',
+	ADD COLUMN IF NOT EXISTS "customer_name" text,
+	ADD COLUMN IF NOT EXISTS "customer_phone" text,
+	ADD COLUMN IF NOT EXISTS "customer_address" text,
+	ADD COLUMN IF NOT EXISTS "customer_email" text,
+	ADD COLUMN IF NOT EXISTS "payment_method" text,
+	ADD COLUMN IF NOT EXISTS "shipping_code" text,
+	ADD COLUMN IF NOT EXISTS "has_spun" boolean DEFAULT false,
+	ADD COLUMN IF NOT EXISTS "spin_token" varchar(64),
+	ADD COLUMN IF NOT EXISTS "spin_token_issued_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_orders_shipping_code" ON "orders" ("shipping_code");
+CREATE INDEX IF NOT EXISTS "idx_orders_voucher_id" ON "orders" ("voucher_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_coupon_id" ON "orders" ("coupon_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_deleted_at" ON "orders" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_orders_spin_token" ON "orders" ("spin_token");
+DO $$ BEGIN ALTER TABLE "orders" ADD CONSTRAINT "fk_orders_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "orders" ADD CONSTRAINT "uni_orders_shipping_code" UNIQUE ("shipping_code"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "order_items" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "order_items"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "order_id" bigint,
+	ADD COLUMN IF NOT EXISTS "product_id" bigint,
+	ADD COLUMN IF NOT EXISTS "quantity" bigint,
+	ADD COLUMN IF NOT EXISTS "price_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "price_currency" varchar(3);
+CREATE INDEX IF NOT EXISTS "idx_order_items_deleted_at" ON "order_items" ("deleted_at");
+DO $$ BEGIN ALTER TABLE "order_items" ADD CONSTRAINT "fk_order_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "order_items" ADD CONSTRAINT "fk_orders_order_items" FOREIGN KEY ("order_id") REFERENCES "orders"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "spin_campaigns" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "spin_campaigns"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "name" varchar(128) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "description" text,
+	ADD COLUMN IF NOT EXISTS "starts_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "ends_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "min_order_value_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "min_order_value_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "max_spins_per_user" bigint DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "priority" bigint DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "active" boolean DEFAULT true;
+CREATE INDEX IF NOT EXISTS "idx_spin_campaigns_deleted_at" ON "spin_campaigns" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "rewards" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "rewards"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "name" text NOT NULL,
+	ADD COLUMN IF NOT EXISTS "value" text,
+	ADD COLUMN IF NOT EXISTS "quantity" bigint DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "probability" decimal NOT NULL,
+	ADD COLUMN IF NOT EXISTS "campaign_id" bigint,
+	ADD COLUMN IF NOT EXISTS "voucher_type" varchar(16) NOT NULL DEFAULT 'gift',
+	ADD COLUMN IF NOT EXISTS "percent_off" decimal,
+	ADD COLUMN IF NOT EXISTS "amount_off_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "amount_off_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "max_discount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "max_discount_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "valid_days" bigint NOT NULL DEFAULT 30,
+	ADD COLUMN IF NOT EXISTS "cost_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "cost_currency" varchar(3);
+CREATE INDEX IF NOT EXISTS "idx_rewards_campaign_id" ON "rewards" ("campaign_id");
+CREATE INDEX IF NOT EXISTS "idx_rewards_deleted_at" ON "rewards" ("deleted_at");
+DO $$ BEGIN ALTER TABLE "rewards" ADD CONSTRAINT "fk_spin_campaigns_rewards" FOREIGN KEY ("campaign_id") REFERENCES "spin_campaigns"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "spin_logs" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "spin_logs"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "order_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "reward_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "campaign_id" bigint,
+	ADD COLUMN IF NOT EXISTS "spin_date" timestamptz NOT NULL,
+	ADD COLUMN IF NOT EXISTS "seed" varchar(16),
+	ADD COLUMN IF NOT EXISTS "roll" decimal,
+	ADD COLUMN IF NOT EXISTS "total_weight" decimal;
+CREATE INDEX IF NOT EXISTS "idx_spin_logs_campaign_id" ON "spin_logs" ("campaign_id");
+CREATE INDEX IF NOT EXISTS "idx_spin_logs_deleted_at" ON "spin_logs" ("deleted_at");
+DO $$ BEGIN ALTER TABLE "spin_logs" ADD CONSTRAINT "fk_spin_logs_order" FOREIGN KEY ("order_id") REFERENCES "orders"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "spin_logs" ADD CONSTRAINT "fk_spin_logs_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "spin_logs" ADD CONSTRAINT "fk_spin_logs_reward" FOREIGN KEY ("reward_id") REFERENCES "rewards"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "proxy_orders" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "proxy_orders"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint,
+	ADD COLUMN IF NOT EXISTS "mercari_url" text,
+	ADD COLUMN IF NOT EXISTS "mercari_item_id" text,
+	ADD COLUMN IF NOT EXISTS "product_name" text,
+	ADD COLUMN IF NOT EXISTS "product_price_jpy_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "product_price_jpy_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "product_condition" text,
+	ADD COLUMN IF NOT EXISTS "product_description" text,
+	ADD COLUMN IF NOT EXISTS "image_urls" JSONB,
+	ADD COLUMN IF NOT EXISTS "customer_name" text,
+	ADD COLUMN IF NOT EXISTS "customer_phone" text,
+	ADD COLUMN IF NOT EXISTS "customer_address" text,
+	ADD COLUMN IF NOT EXISTS "customer_email" text,
+	ADD COLUMN IF NOT EXISTS "exchange_rate" decimal,
+	ADD COLUMN IF NOT EXISTS "exchange_rate_id" bigint,
+	ADD COLUMN IF NOT EXISTS "service_fee_percent" decimal,
+	ADD COLUMN IF NOT EXISTS "service_fee_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "service_fee_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "total_amount_vnd_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "total_amount_vnd_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "status" text DEFAULT 'pending_quote',
+	ADD COLUMN IF NOT EXISTS "quantity" bigint,
+	ADD COLUMN IF NOT EXISTS "payment_method" text,
+	ADD COLUMN IF NOT EXISTS "actual_price_jpy_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "actual_price_jpy_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "domestic_shipping_jpy_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "domestic_shipping_jpy_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "weight_kg" decimal,
+	ADD COLUMN IF NOT EXISTS "international_shipping_vnd_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "international_shipping_vnd_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "quoted_total_vnd_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "quoted_total_vnd_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "quoted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "admin_note" text;
+CREATE INDEX IF NOT EXISTS "idx_proxy_orders_deleted_at" ON "proxy_orders" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_proxy_orders_exchange_rate_id" ON "proxy_orders" ("exchange_rate_id");
+CREATE INDEX IF NOT EXISTS "idx_proxy_orders_mercari_item_id" ON "proxy_orders" ("mercari_item_id");
+DO $$ BEGIN ALTER TABLE "proxy_orders" ADD CONSTRAINT "fk_proxy_orders_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "carts" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "carts"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_carts_user_id" ON "carts" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_carts_deleted_at" ON "carts" ("deleted_at");
+DO $$ BEGIN ALTER TABLE "carts" ADD CONSTRAINT "fk_carts_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "cart_items" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "cart_items"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "cart_id" bigint,
+	ADD COLUMN IF NOT EXISTS "product_id" bigint,
+	ADD COLUMN IF NOT EXISTS "quantity" bigint;
+CREATE INDEX IF NOT EXISTS "idx_cart_items_deleted_at" ON "cart_items" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_cart_items_cart_id" ON "cart_items" ("cart_id");
+DO $$ BEGIN ALTER TABLE "cart_items" ADD CONSTRAINT "fk_cart_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "cart_items" ADD CONSTRAINT "fk_carts_cart_items" FOREIGN KEY ("cart_id") REFERENCES "carts"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "stock_adjustments" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "stock_adjustments"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "product_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "delta" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "stock_after" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "reason" varchar(32) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "note" text,
+	ADD COLUMN IF NOT EXISTS "order_id" bigint,
+	ADD COLUMN IF NOT EXISTS "admin_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_stock_adjustments_order_id" ON "stock_adjustments" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_adjustments_product_id" ON "stock_adjustments" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_adjustments_deleted_at" ON "stock_adjustments" ("deleted_at");
+DO $$ BEGIN ALTER TABLE "stock_adjustments" ADD CONSTRAINT "fk_stock_adjustments_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "order_status_histories" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "order_status_histories"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "order_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "from_status" text,
+	ADD COLUMN IF NOT EXISTS "to_status" text NOT NULL,
+	ADD COLUMN IF NOT EXISTS "changed_by_id" bigint,
+	ADD COLUMN IF NOT EXISTS "note" text;
+CREATE INDEX IF NOT EXISTS "idx_order_status_histories_order_id" ON "order_status_histories" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_order_status_histories_deleted_at" ON "order_status_histories" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "exchange_rates" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "exchange_rates"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "base_currency" varchar(3) NOT NULL DEFAULT 'JPY',
+	ADD COLUMN IF NOT EXISTS "quote_currency" varchar(3) NOT NULL DEFAULT 'VND',
+	ADD COLUMN IF NOT EXISTS "rate" decimal NOT NULL,
+	ADD COLUMN IF NOT EXISTS "service_fee_percent" decimal NOT NULL,
+	ADD COLUMN IF NOT EXISTS "source" varchar(64) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "effective_at" timestamptz NOT NULL,
+	ADD COLUMN IF NOT EXISTS "created_by_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_exchange_rates_effective_at" ON "exchange_rates" ("effective_at");
+CREATE INDEX IF NOT EXISTS "idx_exchange_rates_deleted_at" ON "exchange_rates" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "payments" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "payments"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "order_id" bigint,
+	ADD COLUMN IF NOT EXISTS "proxy_order_id" bigint,
+	ADD COLUMN IF NOT EXISTS "provider" varchar(32) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "txn_ref" varchar(64) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "amount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "amount_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "status" varchar(16) NOT NULL DEFAULT 'pending',
+	ADD COLUMN IF NOT EXISTS "provider_txn_id" varchar(64),
+	ADD COLUMN IF NOT EXISTS "response_code" varchar(16),
+	ADD COLUMN IF NOT EXISTS "raw_callback" JSONB,
+	ADD COLUMN IF NOT EXISTS "paid_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_payments_deleted_at" ON "payments" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payments_txn_ref" ON "payments" ("txn_ref");
+CREATE INDEX IF NOT EXISTS "idx_payments_proxy_order_id" ON "payments" ("proxy_order_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_order_id" ON "payments" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_user_id" ON "payments" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "coupons" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "coupons"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "code" varchar(64) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "description" varchar(255),
+	ADD COLUMN IF NOT EXISTS "type" varchar(16) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "percent_off" decimal,
+	ADD COLUMN IF NOT EXISTS "amount_off_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "amount_off_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "max_discount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "max_discount_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "min_order_value_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "min_order_value_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "usage_limit" bigint DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "per_user_limit" bigint DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "used_count" bigint DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "starts_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "ends_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "active" boolean DEFAULT true;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_coupons_code" ON "coupons" ("code");
+CREATE INDEX IF NOT EXISTS "idx_coupons_deleted_at" ON "coupons" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "coupon_categories" ("coupon_id" bigint, "category_id" bigint, PRIMARY KEY ("coupon_id","category_id"));
+DO $$ BEGIN ALTER TABLE "coupon_categories" ADD CONSTRAINT "fk_coupon_categories_coupon" FOREIGN KEY ("coupon_id") REFERENCES "coupons"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "coupon_categories" ADD CONSTRAINT "fk_coupon_categories_category" FOREIGN KEY ("category_id") REFERENCES "categories"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "coupon_products" ("coupon_id" bigint, "product_id" bigint, PRIMARY KEY ("coupon_id","product_id"));
+DO $$ BEGIN ALTER TABLE "coupon_products" ADD CONSTRAINT "fk_coupon_products_coupon" FOREIGN KEY ("coupon_id") REFERENCES "coupons"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "coupon_products" ADD CONSTRAINT "fk_coupon_products_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "coupon_redemptions" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "coupon_redemptions"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "coupon_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "order_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "discount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "discount_currency" varchar(3);
+CREATE INDEX IF NOT EXISTS "idx_coupon_redemptions_user_id" ON "coupon_redemptions" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_coupon_redemptions_coupon_id" ON "coupon_redemptions" ("coupon_id");
+CREATE INDEX IF NOT EXISTS "idx_coupon_redemptions_deleted_at" ON "coupon_redemptions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_coupon_redemptions_order_id" ON "coupon_redemptions" ("order_id");
+
+CREATE TABLE IF NOT EXISTS "user_vouchers" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "user_vouchers"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "reward_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "spin_log_id" bigint,
+	ADD COLUMN IF NOT EXISTS "source_order_id" bigint,
+	ADD COLUMN IF NOT EXISTS "code" varchar(32) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "type" varchar(16) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "percent_off" decimal,
+	ADD COLUMN IF NOT EXISTS "amount_off_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "amount_off_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "max_discount_minor" bigint NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "max_discount_currency" varchar(3),
+	ADD COLUMN IF NOT EXISTS "status" varchar(16) NOT NULL DEFAULT 'active',
+	ADD COLUMN IF NOT EXISTS "expires_at" timestamptz NOT NULL,
+	ADD COLUMN IF NOT EXISTS "redeemed_order_id" bigint,
+	ADD COLUMN IF NOT EXISTS "redeemed_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_user_vouchers_deleted_at" ON "user_vouchers" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_user_vouchers_expires_at" ON "user_vouchers" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_user_vouchers_status" ON "user_vouchers" ("status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_vouchers_code" ON "user_vouchers" ("code");
+CREATE INDEX IF NOT EXISTS "idx_user_vouchers_source_order_id" ON "user_vouchers" ("source_order_id");
+CREATE INDEX IF NOT EXISTS "idx_user_vouchers_spin_log_id" ON "user_vouchers" ("spin_log_id");
+CREATE INDEX IF NOT EXISTS "idx_user_vouchers_reward_id" ON "user_vouchers" ("reward_id");
+CREATE INDEX IF NOT EXISTS "idx_user_vouchers_user_id" ON "user_vouchers" ("user_id");
+DO $$ BEGIN ALTER TABLE "user_vouchers" ADD CONSTRAINT "fk_user_vouchers_reward" FOREIGN KEY ("reward_id") REFERENCES "rewards"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "spin_campaign_categories" ("spin_campaign_id" bigint, "category_id" bigint, PRIMARY KEY ("spin_campaign_id","category_id"));
+DO $$ BEGIN ALTER TABLE "spin_campaign_categories" ADD CONSTRAINT "fk_spin_campaign_categories_spin_campaign" FOREIGN KEY ("spin_campaign_id") REFERENCES "spin_campaigns"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+DO $$ BEGIN ALTER TABLE "spin_campaign_categories" ADD CONSTRAINT "fk_spin_campaign_categories_category" FOREIGN KEY ("category_id") REFERENCES "categories"("id"); EXCEPTION WHEN duplicate_object OR duplicate_table THEN NULL; END $$;
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "refresh_tokens"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "token_hash" varchar(64) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "family_id" varchar(32) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "expires_at" timestamptz NOT NULL,
+	ADD COLUMN IF NOT EXISTS "revoked_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "replaced_by_id" bigint,
+	ADD COLUMN IF NOT EXISTS "user_agent" varchar(255),
+	ADD COLUMN IF NOT EXISTS "ip" varchar(64);
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_deleted_at" ON "refresh_tokens" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_expires_at" ON "refresh_tokens" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_family_id" ON "refresh_tokens" ("family_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "revoked_access_tokens" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "revoked_access_tokens"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "jti" varchar(32) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "expires_at" timestamptz NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_revoked_access_tokens_jti" ON "revoked_access_tokens" ("jti");
+CREATE INDEX IF NOT EXISTS "idx_revoked_access_tokens_deleted_at" ON "revoked_access_tokens" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_revoked_access_tokens_expires_at" ON "revoked_access_tokens" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_revoked_access_tokens_user_id" ON "revoked_access_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "user_tokens" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "user_tokens"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "purpose" varchar(32) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "token_hash" varchar(64) NOT NULL,
+	ADD COLUMN IF NOT EXISTS "expires_at" timestamptz NOT NULL,
+	ADD COLUMN IF NOT EXISTS "used_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_user_tokens_purpose" ON "user_tokens" ("purpose");
+CREATE INDEX IF NOT EXISTS "idx_user_tokens_user_id" ON "user_tokens" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_user_tokens_deleted_at" ON "user_tokens" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_tokens_token_hash" ON "user_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "user_roles" ("user_id" bigint, "role" varchar(32), "created_at" timestamptz, PRIMARY KEY ("user_id","role"));
+
+CREATE TABLE IF NOT EXISTS "backup_codes" ("id" bigserial, PRIMARY KEY ("id"));
+ALTER TABLE "backup_codes"
+	ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+	ADD COLUMN IF NOT EXISTS "user_id" bigint NOT NULL,
+	ADD COLUMN IF NOT EXISTS "code_hash" text NOT NULL,
+	ADD COLUMN IF NOT EXISTS "used_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_backup_codes_user_id" ON "backup_codes" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_backup_codes_deleted_at" ON "backup_codes" ("deleted_at");
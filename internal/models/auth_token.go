@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken stores only the SHA-256 of the token handed to the client.
+// Tokens rotated from the same login share a FamilyID so that replaying an
+// already-rotated token can revoke the whole chain.
+type RefreshToken struct {
+	gorm.Model
+	UserID       uint       `gorm:"index;not null" json:"userId"`
+	TokenHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	FamilyID     string     `gorm:"size:32;index;not null" json:"familyId"`
+	ExpiresAt    time.Time  `gorm:"index;not null" json:"expiresAt"`
+	RevokedAt    *time.Time `json:"revokedAt"`
+	ReplacedByID *uint      `json:"replacedById"`
+	UserAgent    string     `gorm:"size:255" json:"userAgent"`
+	IP           string     `gorm:"size:64" json:"ip"`
+}
+
+// RevokedAccessToken is the deny list of access token IDs (jti) logged out
+// before they expired.
+type RevokedAccessToken struct {
+	gorm.Model
+	JTI       string    `gorm:"size:32;uniqueIndex;not null" json:"jti"`
+	UserID    uint      `gorm:"index;not null" json:"userId"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expiresAt"`
+}
+
+// LoginFailure counts failed logins for an email that has no account, so
+// unknown addresses lock out exactly like real ones and the lockout cannot
+// be used to find out which emails are registered.
+type LoginFailure struct {
+	ID             uint   `gorm:"primarykey"`
+	Email          string `gorm:"size:254;uniqueIndex;not null"`
+	FailedAttempts int    `gorm:"not null;default:0"`
+	LockedUntil    *time.Time
+	UpdatedAt      time.Time
+}
+
+const (
+	UserTokenVerifyEmail   = "verify_email"
+	UserTokenResetPassword = "reset_password"
+	UserTokenLogin2FA      = "login_2fa"
+)
+
+// UserToken is a single-use emailed token; only its SHA-256 is stored.
+type UserToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"index;not null" json:"userId"`
+	Purpose   string     `gorm:"size:32;index;not null" json:"purpose"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// BackupCode is a one-time 2FA recovery code, stored as an argon2 hash.
+type BackupCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"index;not null" json:"userId"`
+	CodeHash string     `gorm:"not null" json:"-"`
+	UsedAt   *time.Time `json:"usedAt"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
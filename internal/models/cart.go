@@ -33,4 +33,6 @@ type CartCheckoutRequest struct {
 	CustomerPhone   string `json:"customerPhone" binding:"required"`
 	CustomerAddress string `json:"customerAddress" binding:"required"`
 	PaymentMethod   string `json:"paymentMethod" binding:"required"`
+	CouponCode      string `json:"couponCode"`
+	VoucherCode     string `json:"voucherCode"`
 }
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+const (
+	CouponTypePercentage = "percentage"
+	CouponTypeFixed      = "fixed"
+)
+
+type Coupon struct {
+	gorm.Model
+	Code          string      `gorm:"size:64;not null;index:idx_coupons_code,unique,where:deleted_at IS NULL" json:"code"`
+	Description   string      `gorm:"size:255" json:"description"`
+	Type          string      `gorm:"size:16;not null" json:"type"`
+	PercentOff    float64     `json:"percentOff"`
+	AmountOff     money.Money `gorm:"embedded;embeddedPrefix:amount_off_" json:"amountOff"`
+	MaxDiscount   money.Money `gorm:"embedded;embeddedPrefix:max_discount_" json:"maxDiscount"`
+	MinOrderValue money.Money `gorm:"embedded;embeddedPrefix:min_order_value_" json:"minOrderValue"`
+	UsageLimit    int         `gorm:"default:0" json:"usageLimit"`
+	PerUserLimit  int         `gorm:"default:0" json:"perUserLimit"`
+	UsedCount     int         `gorm:"default:0" json:"usedCount"`
+	StartsAt      *time.Time  `json:"startsAt"`
+	EndsAt        *time.Time  `json:"endsAt"`
+	Active        bool        `gorm:"not null" json:"active"`
+	Categories    []Category  `gorm:"many2many:coupon_categories;" json:"categories"`
+	Products      []Product   `gorm:"many2many:coupon_products;" json:"products"`
+}
+
+type CouponRedemption struct {
+	gorm.Model
+	CouponID uint        `gorm:"index;not null" json:"couponId"`
+	UserID   uint        `gorm:"index;not null" json:"userId"`
+	OrderID  uint        `gorm:"index;not null" json:"orderId"`
+	Discount money.Money `gorm:"embedded;embeddedPrefix:discount_" json:"discount"`
+}
+
+type CouponRequest struct {
+	Code          string     `json:"code" binding:"required"`
+	Description   string     `json:"description"`
+	Type          string     `json:"type" binding:"required,oneof=percentage fixed"`
+	PercentOff    float64    `json:"percentOff" binding:"gte=0,lte=1"`
+	AmountOff     int64      `json:"amountOff" binding:"gte=0"`
+	MaxDiscount   int64      `json:"maxDiscount" binding:"gte=0"`
+	MinOrderValue int64      `json:"minOrderValue" binding:"gte=0"`
+	UsageLimit    int        `json:"usageLimit" binding:"gte=0"`
+	PerUserLimit  int        `json:"perUserLimit" binding:"gte=0"`
+	StartsAt      *time.Time `json:"startsAt"`
+	EndsAt        *time.Time `json:"endsAt"`
+	Active        *bool      `json:"active"`
+	CategoryIDs   []uint     `json:"categoryIds"`
+	ProductIDs    []uint     `json:"productIds"`
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required"`
+}
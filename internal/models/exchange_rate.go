@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RateSourceManual  = "manual"
+	RateSourceDefault = "default"
+)
+
+type ExchangeRate struct {
+	gorm.Model
+	BaseCurrency      string    `gorm:"size:3;not null;default:'JPY'" json:"baseCurrency"`
+	QuoteCurrency     string    `gorm:"size:3;not null;default:'VND'" json:"quoteCurrency"`
+	Rate              float64   `gorm:"not null" json:"rate"`
+	ServiceFeePercent float64   `gorm:"not null" json:"serviceFeePercent"`
+	Source            string    `gorm:"size:64;not null" json:"source"`
+	EffectiveAt       time.Time `gorm:"index;not null" json:"effectiveAt"`
+	CreatedByID       *uint     `json:"createdById"`
+}
+
+type ExchangeRateRequest struct {
+	Rate              float64    `json:"rate" binding:"required,gt=0"`
+	ServiceFeePercent *float64   `json:"serviceFeePercent" binding:"omitempty,gte=0,lt=1"`
+	EffectiveAt       *time.Time `json:"effectiveAt"`
+}
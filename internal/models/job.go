@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusDead      = "dead"
+)
+
+// Job is a unit of background work stored in Postgres so it survives
+// restarts. Dead jobs exhausted their attempts and wait for an admin retry.
+type Job struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Type        string         `gorm:"size:64;not null;index" json:"type"`
+	Payload     datatypes.JSON `gorm:"type:jsonb;not null" json:"payload"`
+	Status      string         `gorm:"size:16;not null;default:'pending';index" json:"status"`
+	Attempts    int            `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int            `gorm:"not null;default:8" json:"maxAttempts"`
+	RunAt       time.Time      `gorm:"not null" json:"runAt"`
+	LockedAt    *time.Time     `json:"lockedAt"`
+	LastError   string         `gorm:"type:text;not null;default:''" json:"lastError"`
+	CompletedAt *time.Time     `json:"completedAt"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+}
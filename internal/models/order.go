@@ -1,32 +1,71 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
+
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+const (
+	OrderStatusPendingPayment = "pending_payment"
+	OrderStatusPaid           = "paid"
+	OrderStatusPacking        = "packing"
+	OrderStatusShipped        = "shipped"
+	OrderStatusDelivered      = "delivered"
+	OrderStatusCancelled      = "cancelled"
+	OrderStatusRefunded       = "refunded"
 )
 
 type Order struct {
 	gorm.Model
-	UserID          uint        `json:"userId"`
-	User            User        `json:"user"`
-	TotalAmount     float64     `json:"totalAmount"`
-	OriginalAmount  float64     `json:"originalAmount"`
-	DiscountApplied float64     `json:"discountApplied"`
-	Status          string      `gorm:"default:'pending'" json:"status"`
-	CustomerName    string      `json:"customerName"`
-	CustomerPhone   string      `json:"customerPhone"`
-	CustomerAddress string      `json:"customerAddress"`
-	CustomerEmail   string      `json:"customerEmail"`
-	PaymentMethod   string      `json:"paymentMethod"`
-	OrderItems      []OrderItem `gorm:"foreignKey:OrderID" json:"orderItems"`
-	ShippingCode    string      `gorm:"unique;index" json:"shippingCode"`
-	HasSpun         bool        `gorm:"default:false" json:"hasSpun"`
+	UserID            uint        `json:"userId"`
+	User              User        `json:"user"`
+	TotalAmount       money.Money `gorm:"embedded;embeddedPrefix:total_amount_" json:"totalAmount"`
+	OriginalAmount    money.Money `gorm:"embedded;embeddedPrefix:original_amount_" json:"originalAmount"`
+	DiscountApplied   money.Money `gorm:"embedded;embeddedPrefix:discount_applied_" json:"discountApplied"`
+	CouponID          *uint       `gorm:"index" json:"couponId"`
+	CouponCode        string      `gorm:"size:64" json:"couponCode"`
+	CouponDiscount    money.Money `gorm:"embedded;embeddedPrefix:coupon_discount_" json:"couponDiscount"`
+	VoucherID         *uint       `gorm:"index" json:"voucherId"`
+	VoucherCode       string      `gorm:"size:32" json:"voucherCode"`
+	VoucherDiscount   money.Money `gorm:"embedded;embeddedPrefix:voucher_discount_" json:"voucherDiscount"`
+	ShippingFee       money.Money `gorm:"embedded;embeddedPrefix:shipping_fee_" json:"shippingFee"`
+	AmountDue         money.Money `gorm:"embedded;embeddedPrefix:amount_due_" json:"amountDue"`
+	Status            string      `gorm:"default:'pending_payment'" json:"status"`
+	CustomerName      string      `json:"customerName"`
+	CustomerPhone     string      `json:"customerPhone"`
+	CustomerAddress   string      `json:"customerAddress"`
+	CustomerEmail     string      `json:"customerEmail"`
+	PaymentMethod     string      `json:"paymentMethod"`
+	OrderItems        []OrderItem `gorm:"foreignKey:OrderID" json:"orderItems"`
+	ShippingCode      string      `gorm:"unique;index" json:"shippingCode"`
+	HasSpun           bool        `gorm:"default:false" json:"hasSpun"`
+	SpinToken         *string     `gorm:"size:64;uniqueIndex" json:"spinToken,omitempty"`
+	SpinTokenIssuedAt *time.Time  `json:"spinTokenIssuedAt,omitempty"`
+	LoyaltyAccruedAt  *time.Time  `json:"loyaltyAccruedAt,omitempty"`
 }
 
 type OrderItem struct {
 	gorm.Model
-	OrderID   uint    `json:"orderId"`
-	ProductID uint    `json:"productId"`
-	Product   Product `json:"product"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	OrderID   uint        `json:"orderId"`
+	ProductID uint        `json:"productId"`
+	Product   Product     `json:"product"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `gorm:"embedded;embeddedPrefix:price_" json:"price"`
+}
+
+type OrderStatusHistory struct {
+	gorm.Model
+	OrderID     uint   `gorm:"index;not null" json:"orderId"`
+	FromStatus  string `json:"fromStatus"`
+	ToStatus    string `gorm:"not null" json:"toStatus"`
+	ChangedByID *uint  `json:"changedById"`
+	Note        string `gorm:"type:text" json:"note"`
+}
+
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusSucceeded = "succeeded"
+	PaymentStatusFailed    = "failed"
+	// Expired payments were replaced by a newer attempt after their gateway
+	// link lapsed. A late success callback is still honoured.
+	PaymentStatusExpired = "expired"
+)
+
+type Payment struct {
+	gorm.Model
+	UserID        uint           `gorm:"index;not null" json:"userId"`
+	OrderID       *uint          `gorm:"index" json:"orderId"`
+	ProxyOrderID  *uint          `gorm:"index" json:"proxyOrderId"`
+	Provider      string         `gorm:"size:32;not null" json:"provider"`
+	TxnRef        string         `gorm:"size:64;uniqueIndex;not null" json:"txnRef"`
+	Amount        money.Money    `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	Status        string         `gorm:"size:16;not null;default:'pending'" json:"status"`
+	ProviderTxnID string         `gorm:"size:64" json:"providerTxnId"`
+	ResponseCode  string         `gorm:"size:16" json:"responseCode"`
+	RawCallback   datatypes.JSON `json:"-"`
+	PaidAt        *time.Time     `json:"paidAt"`
+}
+
+type CreatePaymentRequest struct {
+	Provider string `json:"provider" binding:"required"`
+}
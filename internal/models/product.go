@@ -3,6 +3,8 @@ package models
 import (
     "gorm.io/gorm"
     "gorm.io/datatypes"
+
+    "github.com/kaelCoding/toyBE/internal/money"
 )
 
 type Product struct {
@@ -10,7 +12,8 @@ type Product struct {
     ID              uint            `gorm:"primaryKey;autoIncrement" json:"ID"`
     Name            string          `json:"name"`
     Description     string          `gorm:"type:text" json:"description"`
-    Price           string          `json:"price"`
+    Price           money.Money     `gorm:"embedded;embeddedPrefix:price_" json:"price"`
+    Stock           int             `gorm:"not null;default:0" json:"stock"`
     ImageURLs       datatypes.JSON  `json:"image_urls"`
     Categories      []Category      `gorm:"many2many:product_categories;" json:"categories"`
 }
@@ -21,4 +24,32 @@ type Category struct {
     Name        string      `gorm:"uniqueIndex;size:255" json:"name"`
     Description string      `gorm:"size:255" json:"description"`
     Products    []Product   `gorm:"many2many:product_categories;" json:"products"`
-}
\ No newline at end of file
+}
+
+const (
+    StockReasonInitial    = "initial"
+    StockReasonRestock    = "restock"
+    StockReasonSale       = "sale"
+    StockReasonReturn     = "return"
+    StockReasonDamaged    = "damaged"
+    StockReasonCorrection = "correction"
+    StockReasonCancelled  = "order_cancelled"
+)
+
+type StockAdjustment struct {
+    gorm.Model
+    ProductID  uint    `gorm:"index;not null" json:"productId"`
+    Product    Product `gorm:"foreignKey:ProductID" json:"-"`
+    Delta      int     `gorm:"not null" json:"delta"`
+    StockAfter int     `gorm:"not null" json:"stockAfter"`
+    Reason     string  `gorm:"size:32;not null" json:"reason"`
+    Note       string  `gorm:"type:text" json:"note"`
+    OrderID    *uint   `gorm:"index" json:"orderId"`
+    AdminID    *uint   `json:"adminId"`
+}
+
+type AdjustStockRequest struct {
+    Delta  int    `json:"delta" binding:"required"`
+    Reason string `json:"reason" binding:"required"`
+    Note   string `json:"note"`
+}
@@ -1,8 +1,23 @@
 package models
 
 import (
+    "time"
+
     "gorm.io/datatypes"
     "gorm.io/gorm"
+
+    "github.com/kaelCoding/toyBE/internal/money"
+)
+
+const (
+    ProxyStatusPendingQuote     = "pending_quote"
+    ProxyStatusQuoted           = "quoted"
+    ProxyStatusPaid             = "paid"
+    ProxyStatusPurchasedInJapan = "purchased_in_japan"
+    ProxyStatusArrivedWarehouse = "arrived_warehouse"
+    ProxyStatusShippedToVN      = "shipped_to_vn"
+    ProxyStatusDelivered        = "delivered"
+    ProxyStatusCancelled        = "cancelled"
 )
 
 type ProxyOrder struct {
@@ -12,7 +27,7 @@ type ProxyOrder struct {
     MercariURL      string `gorm:"type:text" json:"mercariURL"`      
     MercariItemID   string `gorm:"index" json:"mercariItemID"`  
     ProductName     string `json:"productName"`
-    ProductPriceJPY float64 `json:"productPriceJPY"`  
+    ProductPriceJPY money.Money `gorm:"embedded;embeddedPrefix:product_price_jpy_" json:"productPriceJPY"`
     ProductCondition string `json:"productCondition"`
     ProductDescription string `gorm:"type:text" json:"productDescription"`
     ImageURLs       datatypes.JSON `json:"imageURLs"`
@@ -21,9 +36,31 @@ type ProxyOrder struct {
     CustomerAddress string `json:"customerAddress"`
     CustomerEmail   string `json:"customerEmail"`
     ExchangeRate  float64 `json:"exchangeRate"`  
-    ServiceFee    float64 `json:"serviceFee"`   
-    TotalAmountVND float64 `json:"totalAmountVND"` 
+    ExchangeRateID *uint  `gorm:"index" json:"exchangeRateId"`
+    ServiceFeePercent float64 `json:"serviceFeePercent"`
+    ServiceFee    money.Money `gorm:"embedded;embeddedPrefix:service_fee_" json:"serviceFee"`
+    TotalAmountVND money.Money `gorm:"embedded;embeddedPrefix:total_amount_vnd_" json:"totalAmountVND"`
     Status        string  `gorm:"default:'pending_quote'" json:"status"`
     Quantity      int    `json:"quantity"`
     PaymentMethod string `json:"paymentMethod"`
+
+    ActualPriceJPY           money.Money `gorm:"embedded;embeddedPrefix:actual_price_jpy_" json:"actualPriceJPY"`
+    DomesticShippingJPY      money.Money `gorm:"embedded;embeddedPrefix:domestic_shipping_jpy_" json:"domesticShippingJPY"`
+    WeightKg                 float64     `json:"weightKg"`
+    InternationalShippingVND money.Money `gorm:"embedded;embeddedPrefix:international_shipping_vnd_" json:"internationalShippingVND"`
+    QuotedTotalVND           money.Money `gorm:"embedded;embeddedPrefix:quoted_total_vnd_" json:"quotedTotalVND"`
+    QuotedAt                 *time.Time `json:"quotedAt"`
+    AdminNote                string     `gorm:"type:text" json:"adminNote"`
+}
+
+type ProxyQuoteRequest struct {
+    ActualPriceJPY      int64   `json:"actualPriceJPY" binding:"required,gt=0"`
+    DomesticShippingJPY int64   `json:"domesticShippingJPY" binding:"gte=0"`
+    WeightKg            float64 `json:"weightKg" binding:"required,gt=0"`
+    Note                string  `json:"note"`
+}
+
+type UpdateProxyOrderStatusRequest struct {
+    Status string `json:"status" binding:"required"`
+    Note   string `json:"note"`
 }
\ No newline at end of file
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserRole assigns one of the roles defined in the rbac package to a user.
+type UserRole struct {
+	UserID    uint      `gorm:"primaryKey" json:"userId"`
+	Role      string    `gorm:"primaryKey;size:32" json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AssignRolesRequest struct {
+	Roles []string `json:"roles"`
+}
@@ -2,7 +2,21 @@ package models
 
 import (
 	"time"
+
 	"gorm.io/gorm"
+
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+const (
+	VoucherTypeGift       = "gift"
+	VoucherTypePercentage = "percentage"
+	VoucherTypeFixed      = "fixed"
+
+	VoucherStatusActive   = "active"
+	VoucherStatusRedeemed = "redeemed"
+	VoucherStatusExpired  = "expired"
+	VoucherStatusRevoked  = "revoked"
 )
 
 type Reward struct {
@@ -11,14 +25,45 @@ type Reward struct {
 	Value       string  `json:"value"`
 	Quantity    int     `gorm:"default:0" json:"quantity"`
 	Probability float64 `gorm:"not null" json:"probability"`
+	CampaignID  *uint   `gorm:"index" json:"campaignId"`
+
+	VoucherType string      `gorm:"size:16;not null;default:'gift'" json:"voucherType"`
+	PercentOff  float64     `json:"percentOff"`
+	AmountOff   money.Money `gorm:"embedded;embeddedPrefix:amount_off_" json:"amountOff"`
+	MaxDiscount money.Money `gorm:"embedded;embeddedPrefix:max_discount_" json:"maxDiscount"`
+	ValidDays   int         `gorm:"not null;default:30" json:"validDays"`
+	Cost        money.Money `gorm:"embedded;embeddedPrefix:cost_" json:"cost"`
+}
+
+type UserVoucher struct {
+	gorm.Model
+	UserID          uint        `gorm:"index;not null" json:"userId"`
+	RewardID        uint        `gorm:"index;not null" json:"rewardId"`
+	Reward          Reward      `gorm:"foreignKey:RewardID" json:"reward"`
+	SpinLogID       uint        `gorm:"index" json:"spinLogId"`
+	SourceOrderID   uint        `gorm:"index" json:"sourceOrderId"`
+	Code            string      `gorm:"size:32;uniqueIndex;not null" json:"code"`
+	Type            string      `gorm:"size:16;not null" json:"type"`
+	PercentOff      float64     `json:"percentOff"`
+	AmountOff       money.Money `gorm:"embedded;embeddedPrefix:amount_off_" json:"amountOff"`
+	MaxDiscount     money.Money `gorm:"embedded;embeddedPrefix:max_discount_" json:"maxDiscount"`
+	Status          string      `gorm:"size:16;index;not null;default:'active'" json:"status"`
+	ExpiresAt       time.Time   `gorm:"index;not null" json:"expiresAt"`
+	RedeemedOrderID *uint       `json:"redeemedOrderId"`
+	RedeemedAt      *time.Time  `json:"redeemedAt"`
 }
 
 type SpinLog struct {
 	gorm.Model
-	OrderID  uint      `gorm:"not null" json:"orderId"`
-	UserID   uint      `gorm:"not null" json:"userId"`
-	RewardID uint      `gorm:"not null" json:"rewardId"`
-	SpinDate time.Time `gorm:"not null" json:"spinDate"`
+	OrderID    uint      `gorm:"not null" json:"orderId"`
+	UserID     uint      `gorm:"not null" json:"userId"`
+	RewardID   uint      `gorm:"not null" json:"rewardId"`
+	CampaignID *uint     `gorm:"index" json:"campaignId"`
+	SpinDate   time.Time `gorm:"not null" json:"spinDate"`
+
+	Seed        string  `gorm:"size:16" json:"seed"`
+	Roll        float64 `json:"roll"`
+	TotalWeight float64 `json:"totalWeight"`
 
 	Order  Order  `gorm:"foreignKey:OrderID" json:"order"`
 	User   User   `gorm:"foreignKey:UserID" json:"user"`
@@ -26,10 +71,11 @@ type SpinLog struct {
 }
 
 type SpinRequest struct {
-	ShippingCode string `json:"shippingCode" binding:"required"`
+	SpinToken string `json:"spinToken" binding:"required"`
 }
 
 type SpinResponse struct {
-	Message string `json:"message"`
-	Reward  Reward `json:"reward"`
-}
\ No newline at end of file
+	Message string       `json:"message"`
+	Reward  Reward       `json:"reward"`
+	Voucher *UserVoucher `json:"voucher"`
+}
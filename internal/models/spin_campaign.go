@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+// SpinCampaign is a seasonal wheel with its own reward pool. Rewards without a
+// campaign form the default pool used when no campaign matches the order.
+type SpinCampaign struct {
+	gorm.Model
+	Name            string      `gorm:"size:128;not null" json:"name"`
+	Description     string      `gorm:"type:text" json:"description"`
+	StartsAt        *time.Time  `json:"startsAt"`
+	EndsAt          *time.Time  `json:"endsAt"`
+	MinOrderValue   money.Money `gorm:"embedded;embeddedPrefix:min_order_value_" json:"minOrderValue"`
+	MaxSpinsPerUser int         `gorm:"default:0" json:"maxSpinsPerUser"`
+	Priority        int         `gorm:"default:0" json:"priority"`
+	Active          bool        `gorm:"not null" json:"active"`
+	Categories      []Category  `gorm:"many2many:spin_campaign_categories;" json:"categories"`
+	Rewards         []Reward    `gorm:"foreignKey:CampaignID" json:"rewards"`
+}
+
+type SpinCampaignRequest struct {
+	Name            string     `json:"name" binding:"required"`
+	Description     string     `json:"description"`
+	StartsAt        *time.Time `json:"startsAt"`
+	EndsAt          *time.Time `json:"endsAt"`
+	MinOrderValue   int64      `json:"minOrderValue" binding:"gte=0"`
+	MaxSpinsPerUser int        `json:"maxSpinsPerUser" binding:"gte=0"`
+	Priority        int        `json:"priority"`
+	Active          *bool      `json:"active"`
+	CategoryIDs     []uint     `json:"categoryIds"`
+}
+
+// RewardOdds is one slice of the wheel as a customer would actually see it.
+type RewardOdds struct {
+	RewardID             uint    `json:"rewardId"`
+	Name                 string  `json:"name"`
+	Quantity             int     `json:"quantity"`
+	Weight               float64 `json:"weight"`
+	EffectiveProbability float64 `json:"effectiveProbability"`
+	Available            bool    `json:"available"`
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 	"gorm.io/gorm"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/kaelCoding/toyBE/internal/money"
 	"github.com/kaelCoding/toyBE/internal/utils"
 )
 
@@ -13,11 +14,18 @@ type User struct {
 	Email      			string `gorm:"unique;not null;index" json:"email"`
 	Password   			string `gorm:"not null" json:"password"` 
 	Admin      			bool   `gorm:"default:false" json:"admin"`
-	TotalSpent          float64    `gorm:"default:0" json:"totalSpent"`
+	TotalSpent          money.Money `gorm:"embedded;embeddedPrefix:total_spent_" json:"totalSpent"`
 	VIPLevel            int        `gorm:"default:0" json:"vipLevel"`
 	VIPExpiryDate       *time.Time `json:"vipExpiryDate"` 
-	MaintenanceSpending float64    `gorm:"default:0" json:"maintenanceSpending"`
+	MaintenanceSpending money.Money `gorm:"embedded;embeddedPrefix:maintenance_spending_" json:"maintenanceSpending"`
 	DiscountPercentage  float64    `gorm:"default:0" json:"discountPercentage"`
+	TokensValidAfter    *time.Time `json:"-"`
+	EmailVerifiedAt     *time.Time `json:"emailVerifiedAt"`
+	FailedLoginAttempts int        `gorm:"default:0" json:"-"`
+	LockedUntil         *time.Time `json:"-"`
+	TOTPSecret          string     `gorm:"size:64" json:"-"`
+	TOTPEnabled         bool       `gorm:"default:false" json:"totpEnabled"`
+	TOTPLastStep        int64      `gorm:"default:0" json:"-"`
 }
 
 type UserProfileResponse struct {
@@ -25,12 +33,14 @@ type UserProfileResponse struct {
 	Username            string     `json:"username"`
 	Email               string     `json:"email"`
 	Admin               bool       `json:"admin"`
-	TotalSpent          float64    `json:"totalSpent"`
+	TotalSpent          money.Money `json:"totalSpent"`
 	VIPLevel            int        `json:"vipLevel"`
 	VIPExpiryDate       *time.Time `json:"vipExpiryDate"`
 	DiscountPercentage  float64    `json:"discountPercentage"`
-	NextLevelRequirement float64   `json:"nextLevelRequirement"`
-	MaintenanceRequirement float64 `json:"maintenanceRequirement"`
+	EmailVerified       bool       `json:"emailVerified"`
+	TOTPEnabled         bool       `json:"totpEnabled"`
+	NextLevelRequirement money.Money   `json:"nextLevelRequirement"`
+	MaintenanceRequirement money.Money `json:"maintenanceRequirement"`
 }
 
 type UserResponse struct {
@@ -45,22 +55,30 @@ type AdminUserResponse struct {
 	Username            string     `json:"username"`
 	Email               string     `json:"email"`
 	Admin               bool       `json:"admin"`
-	TotalSpent          float64    `json:"totalSpent"`
+	TotalSpent          money.Money `json:"totalSpent"`
 	VIPLevel            int        `json:"vipLevel"`
 	VIPExpiryDate       *time.Time `json:"vipExpiryDate"`
 	DiscountPercentage  float64    `json:"discountPercentage"`
 }
 
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=32"`
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required"`
+}
+
 type Login struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
 }
 
 type CustomJWTClaims struct {
-	ID       uint   `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Admin    bool   `json:"admin"`
+	ID       uint     `json:"id"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Admin    bool     `json:"admin"`
+	Roles    []string `json:"roles"`
+	MFA      bool     `json:"mfa"`
 	jwt.RegisteredClaims
 }
 
@@ -0,0 +1,241 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Currency string
+
+const (
+	VND Currency = "VND"
+	JPY Currency = "JPY"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid money amount")
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+)
+
+// Money is an amount in the currency's minor unit. Both VND and JPY have no
+// subunit in practice, so one minor unit is one đồng or one yên.
+//
+// In JSON it is {"amount": 150000, "currency": "VND"}. Price fields used to be
+// bare numbers (and Product.price a string), so responses changed shape; see
+// UnmarshalJSON for what requests still accept.
+type Money struct {
+	Amount   int64    `gorm:"column:minor;not null;default:0" json:"amount"`
+	Currency Currency `gorm:"column:currency;size:3" json:"currency"`
+}
+
+func New(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func FromVND(amount int64) Money {
+	return New(amount, VND)
+}
+
+func FromJPY(amount int64) Money {
+	return New(amount, JPY)
+}
+
+// FromFloat rounds a major-unit amount half away from zero. It is only meant
+// for values that are floats by nature, such as exchange rate conversions.
+func FromFloat(amount float64, currency Currency) Money {
+	return New(int64(math.Round(amount)), currency)
+}
+
+// Parse reads a plain integer amount, accepting "." and "," as thousands
+// separators and an optional currency suffix, e.g. "150.000 VNĐ".
+func Parse(s string, currency Currency) (Money, error) {
+	cleaned := strings.TrimSpace(s)
+	for _, suffix := range []string{"VNĐ", "VND", "đ", "₫", "JPY", "¥", "円"} {
+		cleaned = strings.TrimSuffix(cleaned, suffix)
+		cleaned = strings.TrimPrefix(cleaned, suffix)
+	}
+	cleaned = strings.TrimSpace(cleaned)
+	cleaned = strings.NewReplacer(".", "", ",", "", " ", "").Replace(cleaned)
+	if cleaned == "" {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	amount, err := strconv.ParseInt(cleaned, 10, 64)
+	if err != nil || amount < 0 {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	return New(amount, currency), nil
+}
+
+func (m Money) currencyWith(other Money) (Currency, error) {
+	switch {
+	case m.Currency == "":
+		return other.Currency, nil
+	case other.Currency == "" || other.Currency == m.Currency:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s vs %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+// Add and Sub refuse to mix currencies; use Convert first.
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.currencyWith(other)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(m.Amount+other.Amount, currency), nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	currency, err := m.currencyWith(other)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(m.Amount-other.Amount, currency), nil
+}
+
+// Sum adds values left to right, e.g. Sum(price, shipping, fee).
+func Sum(values ...Money) (Money, error) {
+	var total Money
+	for _, v := range values {
+		var err error
+		if total, err = total.Add(v); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func (m Money) Mul(n int64) Money {
+	return New(m.Amount*n, m.Currency)
+}
+
+// Percent returns the rounded share of m, e.g. m.Percent(0.05) for 5%.
+func (m Money) Percent(p float64) Money {
+	return m.Scale(p)
+}
+
+// Scale multiplies m by a non-integer factor such as a weight in kg.
+func (m Money) Scale(f float64) Money {
+	return FromFloat(float64(m.Amount)*f, m.Currency)
+}
+
+// Convert applies rate (units of target per unit of m) and rounds.
+func (m Money) Convert(rate float64, target Currency) Money {
+	return FromFloat(float64(m.Amount)*rate, target)
+}
+
+func (m Money) Div(n int64) Money {
+	if n == 0 {
+		return m
+	}
+	return FromFloat(float64(m.Amount)/float64(n), m.Currency)
+}
+
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := m.currencyWith(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Min returns the smaller of a and b, e.g. a discount capped at its maximum.
+func Min(a, b Money) (Money, error) {
+	cmp, err := a.Cmp(b)
+	if err != nil {
+		return Money{}, err
+	}
+	if cmp > 0 {
+		return b, nil
+	}
+	return a, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func groupDigits(amount int64, sep rune) string {
+	negative := amount < 0
+	if negative {
+		amount = -amount
+	}
+	s := strconv.FormatInt(amount, 10)
+	n := len(s)
+
+	var result strings.Builder
+	if negative {
+		result.WriteRune('-')
+	}
+	for i, r := range s {
+		result.WriteRune(r)
+		if (n-1-i)%3 == 0 && i != n-1 {
+			result.WriteRune(sep)
+		}
+	}
+	return result.String()
+}
+
+// String formats the amount the way customers read it: "150.000 VNĐ", "¥1,500".
+func (m Money) String() string {
+	switch m.Currency {
+	case JPY:
+		return "¥" + groupDigits(m.Amount, ',')
+	case VND, "":
+		return groupDigits(m.Amount, '.') + " VNĐ"
+	default:
+		return groupDigits(m.Amount, ',') + " " + string(m.Currency)
+	}
+}
+
+// UnmarshalJSON accepts the object form and, for clients written against the
+// old API, a bare number or a numeric string such as "150.000". The legacy
+// forms carry no currency, so Currency is left empty and matches any other.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case len(data) > 0 && data[0] == '{':
+		type plain Money
+		var v plain
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*m = Money(v)
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := Parse(s, "")
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+	}
+	*m = FromFloat(f, "")
+	return nil
+}
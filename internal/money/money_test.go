@@ -0,0 +1,119 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestArithmeticRefusesMixedCurrencies(t *testing.T) {
+	vnd, jpy := FromVND(1000), FromJPY(1000)
+
+	if _, err := vnd.Add(jpy); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add: got %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := vnd.Sub(jpy); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sub: got %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := vnd.Cmp(jpy); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp: got %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := Sum(vnd, vnd, jpy); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sum: got %v, want ErrCurrencyMismatch", err)
+	}
+
+	converted := jpy.Convert(172.5, VND)
+	if total, err := vnd.Add(converted); err != nil || total != FromVND(173500) {
+		t.Errorf("explicit conversion: got %v, %v", total, err)
+	}
+}
+
+func TestZeroValueTakesOtherCurrency(t *testing.T) {
+	total, err := Sum(FromJPY(500), FromJPY(250))
+	if err != nil || total != FromJPY(750) {
+		t.Fatalf("got %v, %v; want ¥750", total, err)
+	}
+	if got, _ := (Money{}).Add(FromVND(1)); got.Currency != VND {
+		t.Errorf("currency %q, want VND", got.Currency)
+	}
+}
+
+func TestMin(t *testing.T) {
+	got, err := Min(FromVND(80000), FromVND(50000))
+	if err != nil || got != FromVND(50000) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	got, _ = Min(FromVND(20000), FromVND(50000))
+	if got != FromVND(20000) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	if got := FromVND(100001).Percent(0.05); got != FromVND(5000) {
+		t.Errorf("Percent: got %v", got)
+	}
+	if got := FromVND(195000).Scale(0.8); got != FromVND(156000) {
+		t.Errorf("Scale: got %v", got)
+	}
+	if got := FromVND(100).Div(3); got != FromVND(33) {
+		t.Errorf("Div: got %v", got)
+	}
+	if got := FromFloat(2.5, VND); got != FromVND(3) {
+		t.Errorf("half away from zero: got %v", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := map[string]int64{"150000": 150000, "150.000": 150000, "150,000 VNĐ": 150000, "¥1,500": 1500}
+	for in, want := range cases {
+		got, err := Parse(in, VND)
+		if err != nil || got.Amount != want {
+			t.Errorf("Parse(%q) = %v, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "-5", "1.5e3"} {
+		if _, err := Parse(in, VND); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Parse(%q): got %v, want ErrInvalidAmount", in, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	cases := map[Money]string{
+		FromVND(1500000): "1.500.000 VNĐ",
+		FromVND(-50000):  "-50.000 VNĐ",
+		FromJPY(1500):    "¥1,500",
+		FromVND(0):       "0 VNĐ",
+	}
+	for m, want := range cases {
+		if got := m.String(); got != want {
+			t.Errorf("%#v.String() = %q, want %q", m, got, want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	out, _ := json.Marshal(FromVND(150000))
+	if string(out) != `{"amount":150000,"currency":"VND"}` {
+		t.Errorf("marshal: %s", out)
+	}
+
+	cases := map[string]Money{
+		`{"amount":1500,"currency":"JPY"}`: FromJPY(1500),
+		`150000`:                           New(150000, ""),
+		`"150.000"`:                        New(150000, ""),
+		`99.6`:                             New(100, ""),
+	}
+	for in, want := range cases {
+		var got Money
+		if err := json.Unmarshal([]byte(in), &got); err != nil || got != want {
+			t.Errorf("unmarshal %s = %#v, %v; want %#v", in, got, err, want)
+		}
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`true`), &m); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("unmarshal true: got %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package orders
+
+import (
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+var ShippingFee = money.FromVND(50000)
+
+// ShippingFeeFor returns the flat domestic shipping fee; VIP 2 and above ship free.
+func ShippingFeeFor(vipLevel int) money.Money {
+	if vipLevel >= 2 {
+		return money.FromVND(0)
+	}
+	return ShippingFee
+}
+
+// SetAmountDue fixes the shipping fee and the amount to charge at checkout, so
+// a later change of VIP level does not change what the order costs.
+func SetAmountDue(order *models.Order, vipLevel int) error {
+	order.ShippingFee = ShippingFeeFor(vipLevel)
+	due, err := order.TotalAmount.Add(order.ShippingFee)
+	if err != nil {
+		return err
+	}
+	order.AmountDue = due
+	return nil
+}
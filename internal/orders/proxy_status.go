@@ -0,0 +1,32 @@
+package orders
+
+import "github.com/kaelCoding/toyBE/internal/models"
+
+var proxyTransitions = map[string][]string{
+	models.ProxyStatusPendingQuote:     {models.ProxyStatusQuoted, models.ProxyStatusCancelled},
+	models.ProxyStatusQuoted:           {models.ProxyStatusQuoted, models.ProxyStatusPaid, models.ProxyStatusPurchasedInJapan, models.ProxyStatusCancelled},
+	models.ProxyStatusPaid:             {models.ProxyStatusPurchasedInJapan},
+	models.ProxyStatusPurchasedInJapan: {models.ProxyStatusArrivedWarehouse},
+	models.ProxyStatusArrivedWarehouse: {models.ProxyStatusShippedToVN},
+	models.ProxyStatusShippedToVN:      {models.ProxyStatusDelivered},
+	models.ProxyStatusDelivered:        {},
+	models.ProxyStatusCancelled:        {},
+}
+
+func IsValidProxyStatus(status string) bool {
+	_, ok := proxyTransitions[status]
+	return ok
+}
+
+func AllowedProxyTransitions(from string) []string {
+	return proxyTransitions[from]
+}
+
+func CanProxyTransition(from, to string) bool {
+	for _, s := range proxyTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package orders
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/vouchers"
+	"gorm.io/gorm"
+)
+
+// SpinQualifyingStatus is the status at which an order earns its lucky spin.
+const SpinQualifyingStatus = models.OrderStatusDelivered
+
+func newSpinToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RevokeSpin voids an unused spin token and the unredeemed vouchers the order's
+// spin already won, once the order is refunded.
+func RevokeSpin(tx *gorm.DB, order *models.Order) error {
+	if err := tx.Model(order).Updates(map[string]interface{}{
+		"spin_token":           nil,
+		"spin_token_issued_at": nil,
+	}).Error; err != nil {
+		return err
+	}
+	order.SpinToken = nil
+	order.SpinTokenIssuedAt = nil
+	return vouchers.RevokeFromOrder(tx, order.ID)
+}
+
+// IssueSpinToken gives the order a one-off spin token unless it already has one.
+func IssueSpinToken(tx *gorm.DB, order *models.Order) error {
+	if order.SpinToken != nil {
+		return nil
+	}
+	token, err := newSpinToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := tx.Model(order).Updates(map[string]interface{}{
+		"spin_token":           token,
+		"spin_token_issued_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	order.SpinToken = &token
+	order.SpinTokenIssuedAt = &now
+	return nil
+}
@@ -0,0 +1,127 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kaelCoding/toyBE/internal/coupons"
+	"github.com/kaelCoding/toyBE/internal/inventory"
+	"github.com/kaelCoding/toyBE/internal/loyalty"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/vouchers"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+var transitions = map[string][]string{
+	models.OrderStatusPendingPayment: {models.OrderStatusPaid, models.OrderStatusCancelled},
+	models.OrderStatusPaid:           {models.OrderStatusPacking, models.OrderStatusCancelled, models.OrderStatusRefunded},
+	models.OrderStatusPacking:        {models.OrderStatusShipped, models.OrderStatusCancelled, models.OrderStatusRefunded},
+	models.OrderStatusShipped:        {models.OrderStatusDelivered},
+	models.OrderStatusDelivered:      {models.OrderStatusRefunded},
+	models.OrderStatusCancelled:      {},
+	models.OrderStatusRefunded:       {},
+}
+
+func IsValidStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+func AllowedTransitions(from string) []string {
+	return transitions[from]
+}
+
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordInitialStatus writes the first history row for a freshly created order.
+func RecordInitialStatus(tx *gorm.DB, order *models.Order, changedBy *uint) error {
+	history := models.OrderStatusHistory{
+		OrderID:     order.ID,
+		ToStatus:    order.Status,
+		ChangedByID: changedBy,
+	}
+	return tx.Create(&history).Error
+}
+
+// Transition moves the order to the given status inside tx, locking the order
+// row so concurrent updates cannot both pass the transition check. Payment
+// counts the order towards VIP progress; cancelling or refunding takes it back,
+// and cancelling also returns the stock and the coupon or voucher it used.
+// Refunding voids the order's spin and the vouchers it won but did not use.
+func Transition(tx *gorm.DB, orderID uint, to string, changedBy *uint, note string) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+
+	if !CanTransition(order.Status, to) {
+		return &order, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, order.Status, to)
+	}
+
+	if to == models.OrderStatusCancelled {
+		var items []models.OrderItem
+		if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		if err := inventory.ReleaseOrderItems(tx, items, order.ID); err != nil {
+			return nil, err
+		}
+		if err := coupons.Release(tx, order.ID); err != nil {
+			return nil, err
+		}
+		if err := vouchers.Release(tx, order.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	switch to {
+	case models.OrderStatusPaid:
+		if err := loyalty.AccrueOrder(tx, &order); err != nil {
+			return nil, err
+		}
+	case models.OrderStatusCancelled, models.OrderStatusRefunded:
+		if err := loyalty.ReverseOrder(tx, &order); err != nil {
+			return nil, err
+		}
+	}
+
+	if to == models.OrderStatusRefunded {
+		if err := RevokeSpin(tx, &order); err != nil {
+			return nil, err
+		}
+	}
+
+	history := models.OrderStatusHistory{
+		OrderID:     order.ID,
+		FromStatus:  order.Status,
+		ToStatus:    to,
+		ChangedByID: changedBy,
+		Note:        note,
+	}
+
+	order.Status = to
+	if err := tx.Model(&order).Update("status", to).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return nil, err
+	}
+
+	if to == SpinQualifyingStatus {
+		if err := IssueSpinToken(tx, &order); err != nil {
+			return nil, err
+		}
+	}
+
+	return &order, nil
+}
@@ -0,0 +1,70 @@
+package orders
+
+import (
+	"testing"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.OrderStatusPendingPayment, models.OrderStatusPaid, true},
+		{models.OrderStatusPendingPayment, models.OrderStatusCancelled, true},
+		{models.OrderStatusPendingPayment, models.OrderStatusShipped, false},
+		{models.OrderStatusPaid, models.OrderStatusPacking, true},
+		{models.OrderStatusPacking, models.OrderStatusShipped, true},
+		{models.OrderStatusShipped, models.OrderStatusDelivered, true},
+		{models.OrderStatusShipped, models.OrderStatusCancelled, false},
+		{models.OrderStatusDelivered, models.OrderStatusRefunded, true},
+		{models.OrderStatusDelivered, models.OrderStatusPaid, false},
+		{models.OrderStatusCancelled, models.OrderStatusPaid, false},
+		{models.OrderStatusRefunded, models.OrderStatusDelivered, false},
+		{"completed", models.OrderStatusDelivered, false},
+	}
+	for _, tc := range cases {
+		if got := CanTransition(tc.from, tc.to); got != tc.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+// Every status must be reachable from pending_payment and every non-final
+// status must have a way forward, so no order can get stuck.
+func TestEveryStatusIsReachableAndLive(t *testing.T) {
+	reached := map[string]bool{models.OrderStatusPendingPayment: true}
+	queue := []string{models.OrderStatusPendingPayment}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, to := range AllowedTransitions(from) {
+			if !reached[to] {
+				reached[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	for status := range transitions {
+		if !reached[status] {
+			t.Errorf("status %s cannot be reached", status)
+		}
+		final := status == models.OrderStatusCancelled || status == models.OrderStatusRefunded
+		if !final && len(AllowedTransitions(status)) == 0 {
+			t.Errorf("status %s has no way forward", status)
+		}
+	}
+}
+
+func TestCanProxyTransition(t *testing.T) {
+	if !CanProxyTransition(models.ProxyStatusPendingQuote, models.ProxyStatusQuoted) {
+		t.Error("a pending proxy order must be quotable")
+	}
+	if !CanProxyTransition(models.ProxyStatusQuoted, models.ProxyStatusQuoted) {
+		t.Error("a quoted proxy order must be re-quotable")
+	}
+	if CanProxyTransition(models.ProxyStatusDelivered, models.ProxyStatusCancelled) {
+		t.Error("a delivered proxy order cannot be cancelled")
+	}
+}
@@ -0,0 +1,185 @@
+package orders_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/orders"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+	"gorm.io/gorm"
+)
+
+type fixture struct {
+	user    models.User
+	product models.Product
+	coupon  models.Coupon
+	voucher models.UserVoucher
+	order   models.Order
+}
+
+// newOrder builds what checkout leaves behind: a pending order holding two
+// units of stock, one coupon redemption and one redeemed voucher.
+func newOrder(t *testing.T, db *gorm.DB) fixture {
+	t.Helper()
+	var f fixture
+	f.user = models.User{Username: "buyer", Email: "buyer@example.com", Password: "x"}
+	f.product = models.Product{Name: "Gundam", Price: money.FromVND(700000), Stock: 3}
+	f.coupon = models.Coupon{Code: "ONCE", Type: models.CouponTypeFixed, AmountOff: money.FromVND(50000), UsageLimit: 1, UsedCount: 1, Active: true}
+	reward := models.Reward{Name: "Gift", Quantity: -1, Probability: 1}
+	for _, row := range []any{&f.user, &f.product, &f.coupon, &reward} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f.order = models.Order{
+		UserID:      f.user.ID,
+		TotalAmount: money.FromVND(1350000),
+		Status:      models.OrderStatusPendingPayment,
+		CouponID:    &f.coupon.ID,
+		OrderItems:  []models.OrderItem{{ProductID: f.product.ID, Quantity: 2, Price: money.FromVND(700000)}},
+	}
+	if err := db.Create(&f.order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	f.voucher = models.UserVoucher{
+		UserID: f.user.ID, RewardID: reward.ID, Code: "SPIN-TEST", Type: models.VoucherTypeGift,
+		Status: models.VoucherStatusRedeemed, ExpiresAt: now.AddDate(0, 1, 0),
+		RedeemedOrderID: &f.order.ID, RedeemedAt: &now,
+	}
+	redemption := models.CouponRedemption{CouponID: f.coupon.ID, UserID: f.user.ID, OrderID: f.order.ID, Discount: money.FromVND(50000)}
+	for _, row := range []any{&f.voucher, &redemption} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func transition(t *testing.T, db *gorm.DB, orderID uint, to string) {
+	t.Helper()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := orders.Transition(tx, orderID, to, nil, "")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("transition to %s: %v", to, err)
+	}
+}
+
+func reload(t *testing.T, db *gorm.DB, dest any, id uint) {
+	t.Helper()
+	if err := db.First(dest, id).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPaymentAccruesLoyaltyOnce(t *testing.T) {
+	db := testdb.Open(t)
+	f := newOrder(t, db)
+
+	var user models.User
+	reload(t, db, &user, f.user.ID)
+	if !user.TotalSpent.IsZero() {
+		t.Fatalf("checkout must not accrue loyalty, total spent = %s", user.TotalSpent)
+	}
+
+	transition(t, db, f.order.ID, models.OrderStatusPaid)
+	transition(t, db, f.order.ID, models.OrderStatusPacking)
+
+	reload(t, db, &user, f.user.ID)
+	if user.TotalSpent.Amount != 1350000 {
+		t.Errorf("total spent = %s, want 1.350.000 VNĐ", user.TotalSpent)
+	}
+	if user.VIPLevel != 1 {
+		t.Errorf("VIP level = %d, want 1", user.VIPLevel)
+	}
+}
+
+func TestCancelReleasesEverythingTheOrderHeld(t *testing.T) {
+	db := testdb.Open(t)
+	f := newOrder(t, db)
+
+	transition(t, db, f.order.ID, models.OrderStatusPaid)
+	transition(t, db, f.order.ID, models.OrderStatusCancelled)
+
+	var user models.User
+	reload(t, db, &user, f.user.ID)
+	if !user.TotalSpent.IsZero() || user.VIPLevel != 0 || user.DiscountPercentage != 0 {
+		t.Errorf("loyalty not reversed: spent=%s level=%d discount=%v", user.TotalSpent, user.VIPLevel, user.DiscountPercentage)
+	}
+
+	var product models.Product
+	reload(t, db, &product, f.product.ID)
+	if product.Stock != 5 {
+		t.Errorf("stock = %d, want 5 after returning 2 units", product.Stock)
+	}
+
+	var coupon models.Coupon
+	reload(t, db, &coupon, f.coupon.ID)
+	var redemptions int64
+	db.Model(&models.CouponRedemption{}).Where("order_id = ?", f.order.ID).Count(&redemptions)
+	if coupon.UsedCount != 0 || redemptions != 0 {
+		t.Errorf("coupon not released: used=%d redemptions=%d", coupon.UsedCount, redemptions)
+	}
+
+	var voucher models.UserVoucher
+	reload(t, db, &voucher, f.voucher.ID)
+	if voucher.Status != models.VoucherStatusActive || voucher.RedeemedOrderID != nil {
+		t.Errorf("voucher not released: status=%s order=%v", voucher.Status, voucher.RedeemedOrderID)
+	}
+}
+
+func TestCancelUnpaidLegacyOrderReversesCheckoutAccrual(t *testing.T) {
+	db := testdb.Open(t)
+	f := newOrder(t, db)
+
+	// Đơn tạo bởi code cũ: điểm đã được cộng ngay lúc đặt hàng.
+	accrued := time.Now()
+	if err := db.Model(&models.User{}).Where("id = ?", f.user.ID).Update("total_spent_minor", 1350000).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&f.order).Update("loyalty_accrued_at", accrued).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	transition(t, db, f.order.ID, models.OrderStatusCancelled)
+
+	var user models.User
+	reload(t, db, &user, f.user.ID)
+	if !user.TotalSpent.IsZero() {
+		t.Errorf("total spent = %s, want 0", user.TotalSpent)
+	}
+}
+
+func TestRefundVoidsTheSpinAndItsUnusedVouchers(t *testing.T) {
+	db := testdb.Open(t)
+	f := newOrder(t, db)
+	for _, status := range []string{models.OrderStatusPaid, models.OrderStatusPacking, models.OrderStatusShipped, models.OrderStatusDelivered} {
+		transition(t, db, f.order.ID, status)
+	}
+
+	won := models.UserVoucher{
+		UserID: f.user.ID, RewardID: f.voucher.RewardID, SourceOrderID: f.order.ID, Code: "SPIN-WON",
+		Type: models.VoucherTypeGift, Status: models.VoucherStatusActive, ExpiresAt: time.Now().AddDate(0, 1, 0),
+	}
+	if err := db.Create(&won).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	transition(t, db, f.order.ID, models.OrderStatusRefunded)
+
+	var order models.Order
+	reload(t, db, &order, f.order.ID)
+	if order.SpinToken != nil {
+		t.Error("a refunded order must not keep its spin token")
+	}
+	reload(t, db, &won, won.ID)
+	if won.Status != models.VoucherStatusRevoked {
+		t.Errorf("voucher won with the refunded order is %s, want revoked", won.Status)
+	}
+}
@@ -0,0 +1,75 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+// FakeProvider behaves like a redirect gateway without talking to anyone.
+// Tests and local development use SignedCallback to simulate the IPN call.
+type FakeProvider struct {
+	Secret    string
+	ReturnURL string
+}
+
+func NewFakeProvider(secret, returnURL string) *FakeProvider {
+	return &FakeProvider{Secret: secret, ReturnURL: returnURL}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) RedirectURL(ctx context.Context, payment *models.Payment, orderInfo, clientIP string) (string, error) {
+	params := url.Values{}
+	params.Set("txn_ref", payment.TxnRef)
+	params.Set("amount", strconv.FormatInt(payment.Amount.Amount, 10))
+	params.Set("return_url", p.ReturnURL)
+	return "https://fake-gateway.local/pay?" + params.Encode(), nil
+}
+
+// SignedCallback builds the query a real gateway would send to the IPN endpoint.
+func (p *FakeProvider) SignedCallback(payment *models.Payment, success bool) url.Values {
+	params := url.Values{}
+	params.Set("txn_ref", payment.TxnRef)
+	params.Set("transaction_id", "FAKE-"+payment.TxnRef)
+	params.Set("amount", strconv.FormatInt(payment.Amount.Amount, 10))
+	if success {
+		params.Set("result", "00")
+	} else {
+		params.Set("result", "99")
+	}
+	params.Set("signature", hex.EncodeToString(sign(sha256.New, p.Secret, canonicalQuery(params))))
+	return params
+}
+
+func (p *FakeProvider) VerifyCallback(params url.Values) (*CallbackResult, error) {
+	received, err := hex.DecodeString(params.Get("signature"))
+	if err != nil || len(received) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	if !hmac.Equal(received, sign(sha256.New, p.Secret, canonicalQuery(params, "signature"))) {
+		return nil, ErrInvalidSignature
+	}
+
+	amount, err := strconv.ParseInt(params.Get("amount"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %w", err)
+	}
+
+	return &CallbackResult{
+		TxnRef:        params.Get("txn_ref"),
+		ProviderTxnID: params.Get("transaction_id"),
+		Amount:        money.FromVND(amount),
+		ResponseCode:  params.Get("result"),
+		Success:       params.Get("result") == "00",
+	}, nil
+}
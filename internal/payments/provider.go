@@ -0,0 +1,128 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid payment callback signature")
+	ErrUnknownProvider  = errors.New("unknown payment provider")
+	ErrAmountMismatch   = errors.New("payment amount does not match")
+	ErrPaymentNotFound  = errors.New("payment not found")
+	ErrPaymentPending   = errors.New("another payment for this order is still pending")
+	ErrAlreadyConfirmed = errors.New("payment was already confirmed")
+)
+
+// CallbackResult is the verified content of a provider IPN call.
+type CallbackResult struct {
+	TxnRef        string
+	ProviderTxnID string
+	Amount        money.Money
+	ResponseCode  string
+	Success       bool
+}
+
+// Provider is a redirect-style gateway: the customer is sent to RedirectURL
+// and the gateway later confirms the outcome with a signed IPN callback.
+type Provider interface {
+	Name() string
+	RedirectURL(ctx context.Context, payment *models.Payment, orderInfo, clientIP string) (string, error)
+	VerifyCallback(params url.Values) (*CallbackResult, error)
+}
+
+type Registry map[string]Provider
+
+func (r Registry) Get(name string) (Provider, error) {
+	provider, ok := r[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return provider, nil
+}
+
+// OfflineMethods are settled outside any gateway and confirmed by staff.
+var OfflineMethods = []string{"bank_transfer", "cod"}
+
+// NormalizeMethod is the stored form of a payment method: " VNPay" → "vnpay".
+func NormalizeMethod(method string) string {
+	return strings.ToLower(strings.TrimSpace(method))
+}
+
+// IsGatewayMethod reports whether an order's payment method is settled by a
+// registered gateway, in which case only a verified callback may mark it paid.
+func (r Registry) IsGatewayMethod(method string) bool {
+	_, ok := r[NormalizeMethod(method)]
+	return ok
+}
+
+// IsValidMethod accepts the offline methods and every registered gateway.
+func (r Registry) IsValidMethod(method string) bool {
+	method = NormalizeMethod(method)
+	for _, offline := range OfflineMethods {
+		if method == offline {
+			return true
+		}
+	}
+	return r.IsGatewayMethod(method)
+}
+
+// canonicalQuery sorts params by key and URL-encodes them, which is the
+// string VNPay-style gateways sign.
+func canonicalQuery(params url.Values, skip ...string) string {
+	skipped := make(map[string]bool, len(skip))
+	for _, k := range skip {
+		skipped[k] = true
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if !skipped[k] && params.Get(k) != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(params.Get(k)))
+	}
+	return strings.Join(parts, "&")
+}
+
+func sign(newHash func() hash.Hash, secret, data string) []byte {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// ProvidersFromConfig registers every gateway whose credentials are configured.
+// The fake gateway confirms any payment it signs, so it exists only in development.
+func ProvidersFromConfig(cfg config.PaymentsConfig, environment string) Registry {
+	registry := Registry{}
+
+	if cfg.VNPayTmnCode != "" && cfg.VNPayHashSecret != "" {
+		registry["vnpay"] = &VNPayProvider{
+			TmnCode:    cfg.VNPayTmnCode,
+			HashSecret: cfg.VNPayHashSecret,
+			PayURL:     cfg.VNPayPayURL,
+			ReturnURL:  cfg.ReturnURL,
+		}
+	}
+
+	if cfg.FakeSecret != "" && environment == config.EnvDevelopment {
+		registry["fake"] = NewFakeProvider(cfg.FakeSecret, cfg.ReturnURL)
+	}
+
+	return registry
+}
@@ -0,0 +1,64 @@
+package payments
+
+import (
+	"testing"
+
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+func TestMethodValidation(t *testing.T) {
+	registry := Registry{"vnpay": &VNPayProvider{}}
+
+	if got := NormalizeMethod("  VNPay "); got != "vnpay" {
+		t.Errorf("NormalizeMethod = %q", got)
+	}
+	for _, method := range []string{"vnpay", " VNPAY", "bank_transfer", "COD"} {
+		if !registry.IsValidMethod(method) {
+			t.Errorf("%q should be valid", method)
+		}
+	}
+	for _, method := range []string{"", "bitcoin", "fake"} {
+		if registry.IsValidMethod(method) {
+			t.Errorf("%q should be rejected", method)
+		}
+	}
+	if !registry.IsGatewayMethod("VNPay") || registry.IsGatewayMethod("bank_transfer") {
+		t.Error("gateway detection must ignore case and exclude offline methods")
+	}
+}
+
+func TestFakeProviderOnlyInDevelopment(t *testing.T) {
+	cfg := config.PaymentsConfig{FakeSecret: "secret"}
+	if _, err := ProvidersFromConfig(cfg, config.EnvProduction).Get("fake"); err == nil {
+		t.Error("the fake gateway must not be registered in production")
+	}
+	if _, err := ProvidersFromConfig(cfg, config.EnvDevelopment).Get("fake"); err != nil {
+		t.Errorf("the fake gateway should be registered in development: %v", err)
+	}
+}
+
+func TestFakeProviderCallbackSignature(t *testing.T) {
+	provider := NewFakeProvider("secret", "https://shop.local/return")
+	payment := &models.Payment{TxnRef: "abc123", Amount: money.FromVND(150000)}
+
+	result, err := provider.VerifyCallback(provider.SignedCallback(payment, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.TxnRef != "abc123" || result.Amount != money.FromVND(150000) {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	tampered := provider.SignedCallback(payment, true)
+	tampered.Set("amount", "1")
+	if _, err := provider.VerifyCallback(tampered); err != ErrInvalidSignature {
+		t.Errorf("tampered amount: got %v, want ErrInvalidSignature", err)
+	}
+
+	other := NewFakeProvider("other-secret", "")
+	if _, err := other.VerifyCallback(provider.SignedCallback(payment, true)); err != ErrInvalidSignature {
+		t.Errorf("wrong secret: got %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,183 @@
+package payments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kaelCoding/toyBE/internal/database"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/orders"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func newTxnRef() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// PendingTTL is how long a gateway payment link stays valid. Until then the
+// pending payment is reused; after it a new attempt replaces it.
+const PendingTTL = 15 * time.Minute
+
+// NewPayment records a pending payment for either an order or a proxy order.
+// An order has at most one pending payment (enforced by a partial unique index).
+func NewPayment(db *gorm.DB, provider Provider, userID uint, orderID, proxyOrderID *uint, amount money.Money) (*models.Payment, error) {
+	if amount.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrAmountMismatch)
+	}
+
+	var payment models.Payment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("status = ?", models.PaymentStatusPending)
+		if orderID != nil {
+			query = query.Where("order_id = ?", *orderID)
+		} else {
+			query = query.Where("proxy_order_id = ?", *proxyOrderID)
+		}
+
+		var pending models.Payment
+		err := query.First(&pending).Error
+		switch {
+		case err == nil:
+			fresh := time.Since(pending.CreatedAt) < PendingTTL
+			if fresh && pending.Provider == provider.Name() && pending.Amount.Amount == amount.Amount {
+				payment = pending
+				return nil
+			}
+			if fresh {
+				return ErrPaymentPending
+			}
+			if err := tx.Model(&pending).Update("status", models.PaymentStatusExpired).Error; err != nil {
+				return err
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		payment = models.Payment{
+			UserID:       userID,
+			OrderID:      orderID,
+			ProxyOrderID: proxyOrderID,
+			Provider:     provider.Name(),
+			TxnRef:       newTxnRef(),
+			Amount:       amount,
+			Status:       models.PaymentStatusPending,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			if _, ok := database.UniqueViolation(err); ok {
+				return ErrPaymentPending
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// HandleCallback applies a verified IPN call. Gateways retry callbacks, so a
+// payment that was already settled is returned unchanged with ErrAlreadyConfirmed.
+func HandleCallback(db *gorm.DB, provider Provider, params url.Values) (*models.Payment, error) {
+	result, err := provider.VerifyCallback(params)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, _ := json.Marshal(params)
+
+	var payment models.Payment
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("txn_ref = ? AND provider = ?", result.TxnRef, provider.Name()).
+			First(&payment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if payment.Status != models.PaymentStatusPending && payment.Status != models.PaymentStatusExpired {
+			return ErrAlreadyConfirmed
+		}
+
+		if result.Amount.Amount != payment.Amount.Amount {
+			return ErrAmountMismatch
+		}
+
+		payment.ProviderTxnID = result.ProviderTxnID
+		payment.ResponseCode = result.ResponseCode
+		payment.RawCallback = raw
+
+		if !result.Success {
+			payment.Status = models.PaymentStatusFailed
+			return tx.Save(&payment).Error
+		}
+
+		now := time.Now()
+		payment.Status = models.PaymentStatusSucceeded
+		payment.PaidAt = &now
+		if err := tx.Save(&payment).Error; err != nil {
+			return err
+		}
+
+		return markPaid(tx, &payment)
+	})
+	if errors.Is(err, ErrAlreadyConfirmed) {
+		return &payment, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// ExpirePendingProxyPayments expires the payment links of a proxy order whose
+// quote is about to change, so nobody can pay the old price.
+func ExpirePendingProxyPayments(tx *gorm.DB, proxyOrderID uint) error {
+	return tx.Model(&models.Payment{}).
+		Where("proxy_order_id = ? AND status = ?", proxyOrderID, models.PaymentStatusPending).
+		Update("status", models.PaymentStatusExpired).Error
+}
+
+func markPaid(tx *gorm.DB, payment *models.Payment) error {
+	note := fmt.Sprintf("Payment %s confirmed by %s", payment.TxnRef, payment.Provider)
+
+	if payment.OrderID != nil {
+		_, err := orders.Transition(tx, *payment.OrderID, models.OrderStatusPaid, nil, note)
+		if errors.Is(err, orders.ErrInvalidTransition) {
+			// The money is captured either way; keep the payment and let staff refund.
+			log.Printf("Payment %s succeeded but order %d could not move to paid: %v", payment.TxnRef, *payment.OrderID, err)
+			return nil
+		}
+		return err
+	}
+
+	if payment.ProxyOrderID != nil {
+		var proxyOrder models.ProxyOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&proxyOrder, *payment.ProxyOrderID).Error; err != nil {
+			return err
+		}
+		if !orders.CanProxyTransition(proxyOrder.Status, models.ProxyStatusPaid) {
+			log.Printf("Payment %s succeeded but proxy order %d is %s", payment.TxnRef, proxyOrder.ID, proxyOrder.Status)
+			return nil
+		}
+		// Báo giá có thể đã đổi sau khi link thanh toán được tạo.
+		if payment.Amount.Amount != proxyOrder.QuotedTotalVND.Amount {
+			log.Printf("Payment %s paid %d but proxy order %d is now quoted at %d", payment.TxnRef, payment.Amount.Amount, proxyOrder.ID, proxyOrder.QuotedTotalVND.Amount)
+			return nil
+		}
+		return tx.Model(&proxyOrder).Update("status", models.ProxyStatusPaid).Error
+	}
+
+	return nil
+}
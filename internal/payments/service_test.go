@@ -0,0 +1,116 @@
+package payments_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/payments"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+	"gorm.io/gorm"
+)
+
+func pendingOrder(t *testing.T, db *gorm.DB) models.Order {
+	t.Helper()
+	user := models.User{Username: "payer", Email: "payer@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{UserID: user.ID, TotalAmount: money.FromVND(300000), Status: models.OrderStatusPendingPayment, PaymentMethod: "fake"}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestOnePendingPaymentPerOrder(t *testing.T) {
+	db := testdb.Open(t)
+	order := pendingOrder(t, db)
+	fake := payments.NewFakeProvider("secret", "")
+	amount := money.FromVND(350000)
+
+	first, err := payments.NewPayment(db, fake, order.UserID, &order.ID, nil, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := payments.NewPayment(db, fake, order.UserID, &order.ID, nil, amount)
+	if err != nil || again.ID != first.ID {
+		t.Fatalf("a retry within the link lifetime should reuse payment %d, got %+v, %v", first.ID, again, err)
+	}
+	if _, err := payments.NewPayment(db, fake, order.UserID, &order.ID, nil, money.FromVND(1)); !errors.Is(err, payments.ErrPaymentPending) {
+		t.Fatalf("different amount while pending: got %v, want ErrPaymentPending", err)
+	}
+
+	// Giả lập link thanh toán đã hết hạn.
+	db.Model(first).Update("created_at", time.Now().Add(-payments.PendingTTL-time.Minute))
+	replacement, err := payments.NewPayment(db, fake, order.UserID, &order.ID, nil, amount)
+	if err != nil || replacement.ID == first.ID {
+		t.Fatalf("a lapsed pending payment should be replaced, got %+v, %v", replacement, err)
+	}
+	var expired models.Payment
+	db.First(&expired, first.ID)
+	if expired.Status != models.PaymentStatusExpired {
+		t.Errorf("replaced payment is %s, want expired", expired.Status)
+	}
+
+	duplicate := models.Payment{UserID: order.UserID, OrderID: &order.ID, Provider: "fake", TxnRef: "dup", Amount: amount, Status: models.PaymentStatusPending}
+	if err := db.Create(&duplicate).Error; err == nil {
+		t.Fatal("the partial unique index must refuse a second pending payment")
+	}
+}
+
+func TestCallbackMarksOrderPaidOnce(t *testing.T) {
+	db := testdb.Open(t)
+	order := pendingOrder(t, db)
+	fake := payments.NewFakeProvider("secret", "")
+
+	payment, err := payments.NewPayment(db, fake, order.UserID, &order.ID, nil, money.FromVND(350000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tooLittle := *payment
+	tooLittle.Amount = money.FromVND(1)
+	if _, err := payments.HandleCallback(db, fake, fake.SignedCallback(&tooLittle, true)); !errors.Is(err, payments.ErrAmountMismatch) {
+		t.Fatalf("underpaid callback: got %v, want ErrAmountMismatch", err)
+	}
+
+	callback := fake.SignedCallback(payment, true)
+	settled, err := payments.HandleCallback(db, fake, callback)
+	if err != nil || settled.Status != models.PaymentStatusSucceeded {
+		t.Fatalf("callback: %+v, %v", settled, err)
+	}
+	var paid models.Order
+	db.First(&paid, order.ID)
+	if paid.Status != models.OrderStatusPaid || paid.LoyaltyAccruedAt == nil {
+		t.Errorf("order is %s (accrued %v), want paid and accrued", paid.Status, paid.LoyaltyAccruedAt)
+	}
+
+	if _, err := payments.HandleCallback(db, fake, callback); !errors.Is(err, payments.ErrAlreadyConfirmed) {
+		t.Fatalf("repeated callback: got %v, want ErrAlreadyConfirmed", err)
+	}
+	var user models.User
+	db.First(&user, order.UserID)
+	if user.TotalSpent != money.FromVND(300000) {
+		t.Errorf("total spent %v; a repeated callback must not count twice", user.TotalSpent)
+	}
+}
+
+func TestLateCallbackOnExpiredPaymentIsHonoured(t *testing.T) {
+	db := testdb.Open(t)
+	order := pendingOrder(t, db)
+	fake := payments.NewFakeProvider("secret", "")
+
+	payment, err := payments.NewPayment(db, fake, order.UserID, &order.ID, nil, money.FromVND(350000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(payment).Update("status", models.PaymentStatusExpired)
+
+	settled, err := payments.HandleCallback(db, fake, fake.SignedCallback(payment, true))
+	if err != nil || settled.Status != models.PaymentStatusSucceeded {
+		t.Fatalf("late callback: %+v, %v", settled, err)
+	}
+}
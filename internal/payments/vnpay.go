@@ -0,0 +1,75 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+var vietnamTZ = time.FixedZone("ICT", 7*60*60)
+
+type VNPayProvider struct {
+	TmnCode    string
+	HashSecret string
+	PayURL     string
+	ReturnURL  string
+}
+
+func (p *VNPayProvider) Name() string {
+	return "vnpay"
+}
+
+func (p *VNPayProvider) RedirectURL(ctx context.Context, payment *models.Payment, orderInfo, clientIP string) (string, error) {
+	now := time.Now().In(vietnamTZ)
+	params := url.Values{}
+	params.Set("vnp_Version", "2.1.0")
+	params.Set("vnp_Command", "pay")
+	params.Set("vnp_TmnCode", p.TmnCode)
+	// VNPay expects the amount multiplied by 100.
+	params.Set("vnp_Amount", strconv.FormatInt(payment.Amount.Amount*100, 10))
+	params.Set("vnp_CurrCode", "VND")
+	params.Set("vnp_TxnRef", payment.TxnRef)
+	params.Set("vnp_OrderInfo", orderInfo)
+	params.Set("vnp_OrderType", "other")
+	params.Set("vnp_Locale", "vn")
+	params.Set("vnp_ReturnUrl", p.ReturnURL)
+	params.Set("vnp_IpAddr", clientIP)
+	params.Set("vnp_CreateDate", now.Format("20060102150405"))
+	params.Set("vnp_ExpireDate", now.Add(15*time.Minute).Format("20060102150405"))
+
+	query := canonicalQuery(params)
+	signature := hex.EncodeToString(sign(sha512.New, p.HashSecret, query))
+	return fmt.Sprintf("%s?%s&vnp_SecureHash=%s", p.PayURL, query, signature), nil
+}
+
+func (p *VNPayProvider) VerifyCallback(params url.Values) (*CallbackResult, error) {
+	received, err := hex.DecodeString(params.Get("vnp_SecureHash"))
+	if err != nil || len(received) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	expected := sign(sha512.New, p.HashSecret, canonicalQuery(params, "vnp_SecureHash", "vnp_SecureHashType"))
+	if !hmac.Equal(received, expected) {
+		return nil, ErrInvalidSignature
+	}
+
+	amount, err := strconv.ParseInt(params.Get("vnp_Amount"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vnp_Amount: %w", err)
+	}
+
+	return &CallbackResult{
+		TxnRef:        params.Get("vnp_TxnRef"),
+		ProviderTxnID: params.Get("vnp_TransactionNo"),
+		Amount:        money.FromVND(amount / 100),
+		ResponseCode:  params.Get("vnp_ResponseCode"),
+		Success:       params.Get("vnp_ResponseCode") == "00" && params.Get("vnp_TransactionStatus") == "00",
+	}, nil
+}
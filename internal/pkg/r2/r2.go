@@ -5,12 +5,12 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	appconfig "github.com/kaelCoding/toyBE/internal/config"
 )
 
 var (
@@ -19,16 +19,12 @@ var (
 	PublicURL  string
 )
 
-func Init() {
-	accountID := os.Getenv("R2_ACCOUNT_ID")
-	accessKey := os.Getenv("R2_ACCESS_KEY_ID")
-	secretKey := os.Getenv("R2_SECRET_ACCESS_KEY")
-	BucketName = os.Getenv("R2_BUCKET_NAME")
-	PublicURL = os.Getenv("R2_PUBLIC_URL")
-
-	if accountID == "" || accessKey == "" || secretKey == "" || BucketName == "" || PublicURL == "" {
-		log.Fatal("Missing R2 environment variables (R2_ACCOUNT_ID, R2_ACCESS_KEY_ID, R2_SECRET_ACCESS_KEY, R2_BUCKET_NAME, R2_PUBLIC_URL)")
-	}
+func Init(r2cfg appconfig.R2Config) {
+	accountID := r2cfg.AccountID
+	accessKey := r2cfg.AccessKeyID
+	secretKey := r2cfg.SecretAccessKey
+	BucketName = r2cfg.BucketName
+	PublicURL = r2cfg.PublicURL
 
 	r2Endpoint := fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID)
 
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreAllowsBurstThenRefills(t *testing.T) {
+	s := NewMemoryStore()
+	limit := PerMinute(3)
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		res, err := s.Take(ctx, "k", limit)
+		if err != nil || !res.Allowed || res.Remaining != i {
+			t.Fatalf("take %d: %+v, %v", 3-i, res, err)
+		}
+	}
+	res, _ := s.Take(ctx, "k", limit)
+	if res.Allowed {
+		t.Fatal("fourth request within the minute was allowed")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > 20*time.Second {
+		t.Errorf("RetryAfter = %v, want up to one refill interval (20s)", res.RetryAfter)
+	}
+
+	if res, _ := s.Take(ctx, "other", limit); !res.Allowed {
+		t.Error("a different key shares the exhausted bucket")
+	}
+
+	// Lùi thời điểm cập nhật để mô phỏng 20 giây trôi qua: hồi đúng một lượt.
+	s.buckets["k"].last = s.buckets["k"].last.Add(-20 * time.Second)
+	if res, _ := s.Take(ctx, "k", limit); !res.Allowed {
+		t.Fatal("no token came back after one refill interval")
+	}
+	if res, _ := s.Take(ctx, "k", limit); res.Allowed {
+		t.Error("more than one token came back after one refill interval")
+	}
+}
+
+func TestMemoryStoreSweepDropsIdleBuckets(t *testing.T) {
+	s := NewMemoryStore()
+	s.Take(context.Background(), "idle", PerMinute(1))
+	s.Take(context.Background(), "busy", PerMinute(1))
+	s.buckets["idle"].last = time.Now().Add(-2 * time.Minute)
+
+	s.sweep(time.Now())
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("idle bucket survived the sweep")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("recently used bucket was swept")
+	}
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc picks what a policy counts against, e.g. the client IP.
+type KeyFunc func(c *gin.Context) string
+
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser keys on the authenticated user, falling back to the IP. It must run
+// after AuthMiddleware to see the user.
+func ByUser(c *gin.Context) string {
+	if id, ok := c.Get("userID"); ok {
+		return fmt.Sprintf("user:%v", id)
+	}
+	return ByIP(c)
+}
+
+type Policy struct {
+	Name  string
+	Limit Limit
+	Key   KeyFunc
+}
+
+// Take counts one request from c against the policy.
+func (p Policy) Take(c *gin.Context, store Store) (Result, error) {
+	return store.Take(c.Request.Context(), p.Name+":"+p.Key(c), p.Limit)
+}
+
+// RetryAfterSeconds rounds up so clients never retry too early.
+func RetryAfterSeconds(seconds float64) string {
+	return strconv.Itoa(int(math.Ceil(seconds)))
+}
+
+// Middleware enforces policy against store. When the store itself fails the
+// request is let through: an outage of the limiter must not take the API down.
+func Middleware(store Store, policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := policy.Take(c, store)
+		if err != nil {
+			log.Printf("Rate limiter error for %s: %v", policy.Name, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(policy.Limit.Requests))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			c.Header("Retry-After", RetryAfterSeconds(result.RetryAfter.Seconds()))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests. Please try again later."})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("store down")
+}
+
+func limitedRouter(store Store, limit Limit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", Middleware(store, Policy{Name: "test", Limit: limit, Key: ByIP}), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+func get(r http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareRejectsWithRetryAfter(t *testing.T) {
+	r := limitedRouter(NewMemoryStore(), Limit{Requests: 2, Per: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if w := get(r, "10.0.0.1:1234"); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d: %d", i+1, w.Code)
+		}
+	}
+	w := get(r, "10.0.0.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+		t.Errorf("X-RateLimit-Limit = %q, want 2", got)
+	}
+
+	if w := get(r, "10.0.0.2:1234"); w.Code != http.StatusNoContent {
+		t.Errorf("another client was limited: %d", w.Code)
+	}
+}
+
+// Limiter hỏng thì vẫn cho request đi qua.
+func TestMiddlewareFailsOpen(t *testing.T) {
+	r := limitedRouter(failingStore{}, PerMinute(1))
+	if w := get(r, "10.0.0.1:1234"); w.Code != http.StatusNoContent {
+		t.Fatalf("got %d, want the request to pass when the store fails", w.Code)
+	}
+}
+
+func TestRetryAfterSecondsRoundsUp(t *testing.T) {
+	for in, want := range map[float64]string{0.2: "1", 1: "1", 29.01: "30"} {
+		if got := RetryAfterSeconds(in); got != want {
+			t.Errorf("RetryAfterSeconds(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
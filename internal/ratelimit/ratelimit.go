@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/config"
+)
+
+// Limit allows Requests per Per on average, with bursts up to Requests.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func PerMinute(n int) Limit { return Limit{Requests: n, Per: time.Minute} }
+func PerHour(n int) Limit   { return Limit{Requests: n, Per: time.Hour} }
+
+// refillInterval is how long one token takes to come back.
+func (l Limit) refillInterval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Store is a token bucket backend. MemoryStore suits a single instance;
+// RedisStore shares buckets between instances.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// StoreFromConfig builds the configured bucket store.
+func StoreFromConfig(cfg config.RateLimitConfig) (Store, error) {
+	switch cfg.Store {
+	case "", config.RateLimitStoreMemory:
+		return NewMemoryStore(), nil
+	case config.RateLimitStoreRedis:
+		client, err := NewRedisClient(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStore(client, "ratelimit:"), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q", cfg.Store)
+	}
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RedisEvaler is the one call RedisStore needs, satisfied by a thin wrapper
+// around any Redis-compatible client (go-redis, rueidis, KeyDB, ...).
+type RedisEvaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// The bucket is a hash of {tokens, last_ms}; the script refills, takes one
+// token if possible and returns {allowed, remaining, retry_after_ms}.
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local refill_ms = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl_ms = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or capacity
+local last = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + (now - last) / refill_ms)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) * refill_ms)
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', now)
+redis.call('PEXPIRE', KEYS[1], ttl_ms)
+return {allowed, math.floor(tokens), retry}
+`
+
+type RedisStore struct {
+	client RedisEvaler
+	prefix string
+}
+
+func NewRedisStore(client RedisEvaler, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := s.client.Eval(ctx, tokenBucketScript, []string{s.prefix + key},
+		limit.Requests,
+		limit.refillInterval().Milliseconds(),
+		time.Now().UnixMilli(),
+		limit.Per.Milliseconds(),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 3 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected redis reply %v", reply)
+	}
+	nums := make([]int64, 3)
+	for i, v := range values {
+		n, ok := v.(int64)
+		if !ok {
+			return Result{}, fmt.Errorf("ratelimit: unexpected redis reply %v", reply)
+		}
+		nums[i] = n
+	}
+
+	return Result{
+		Allowed:    nums[0] == 1,
+		Remaining:  int(nums[1]),
+		RetryAfter: time.Duration(nums[2]) * time.Millisecond,
+	}, nil
+}
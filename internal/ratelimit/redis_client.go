@@ -0,0 +1,211 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redisTimeout = time.Second
+
+// RedisClient is a minimal RESP client that speaks just enough of the
+// protocol for RedisStore: EVAL plus AUTH and SELECT on connect. It keeps one
+// connection, redialling after any error.
+type RedisClient struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// NewRedisClient parses a redis:// or rediss:// URL such as
+// redis://:password@localhost:6379/0. It does not connect until first use.
+func NewRedisClient(rawURL string) (*RedisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	if (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, fmt.Errorf("REDIS_URL must be a redis:// or rediss:// URL")
+	}
+
+	c := &RedisClient{addr: u.Host, useTLS: u.Scheme == "rediss"}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if c.db, err = strconv.Atoi(path); err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL database %q", path)
+		}
+	}
+	return c, nil
+}
+
+func (c *RedisClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	cmd := make([]string, 0, 3+len(keys)+len(args))
+	cmd = append(cmd, "EVAL", script, strconv.Itoa(len(keys)))
+	cmd = append(cmd, keys...)
+	for _, arg := range args {
+		cmd = append(cmd, fmt.Sprint(arg))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reply, err := c.do(ctx, cmd)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// Lỗi mạng: bỏ kết nối, lần sau kết nối lại.
+		c.close()
+	}
+	return reply, err
+}
+
+func (c *RedisClient) do(ctx context.Context, cmd []string) (interface{}, error) {
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	writeCommand(c.rw.Writer, cmd)
+	if err := c.rw.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(c.rw.Reader)
+}
+
+func (c *RedisClient) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if c.useTLS {
+		host, _, _ := net.SplitHostPort(c.addr)
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	if c.password != "" {
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := c.do(ctx, auth); err != nil {
+			c.close()
+			return fmt.Errorf("redis AUTH: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.do(ctx, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			c.close()
+			return fmt.Errorf("redis SELECT: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *RedisClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+		c.rw = nil
+	}
+}
+
+// redisError is an error reply from the server; the connection is still fine.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// writeCommand buffers cmd as a RESP array; write errors surface on Flush.
+func writeCommand(w *bufio.Writer, cmd []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(cmd))
+	for _, arg := range cmd {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+// readReply decodes one RESP2 reply. Integers come back as int64, bulk and
+// simple strings as string, arrays as []interface{} and nil as nil.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
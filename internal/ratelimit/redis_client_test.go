@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers every command with the next canned reply and records
+// the commands it saw.
+func fakeRedis(t *testing.T, replies ...string) (addr string, seen chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	seen = make(chan []string, len(replies))
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for _, reply := range replies {
+			cmd, err := readReply(r)
+			if err != nil {
+				return
+			}
+			var args []string
+			for _, v := range cmd.([]interface{}) {
+				args = append(args, v.(string))
+			}
+			seen <- args
+			conn.Write([]byte(reply))
+		}
+	}()
+	return ln.Addr().String(), seen
+}
+
+func TestRedisStoreTakeOverRESP(t *testing.T) {
+	addr, seen := fakeRedis(t, "+OK\r\n", "*3\r\n:0\r\n:0\r\n:1500\r\n")
+	client, err := NewRedisClient("redis://:secret@" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewRedisStore(client, "test:")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	result, err := store.Take(ctx, "login:ip:1.2.3.4", PerMinute(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.RetryAfter != 1500*time.Millisecond {
+		t.Errorf("got %+v, want denied with 1.5s retry", result)
+	}
+
+	if auth := <-seen; strings.Join(auth, " ") != "AUTH secret" {
+		t.Errorf("first command %v, want AUTH", auth)
+	}
+	eval := <-seen
+	if eval[0] != "EVAL" || eval[2] != "1" || eval[3] != "test:login:ip:1.2.3.4" || eval[4] != "10" {
+		t.Errorf("unexpected EVAL %v", eval[2:])
+	}
+}
+
+func TestRedisClientSurfacesErrorReplies(t *testing.T) {
+	addr, _ := fakeRedis(t, "-NOSCRIPT no such script\r\n")
+	client, err := NewRedisClient("redis://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Eval(context.Background(), "return 1", nil); err == nil || !strings.Contains(err.Error(), "NOSCRIPT") {
+		t.Errorf("got %v, want the server's error", err)
+	}
+	if client.conn == nil {
+		t.Error("an error reply should not drop the connection")
+	}
+}
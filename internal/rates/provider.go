@@ -0,0 +1,108 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/kaelCoding/toyBE/internal/config"
+)
+
+// Provider fetches the market JPY→VND rate from an external source.
+type Provider interface {
+	Name() string
+	FetchJPYToVND(ctx context.Context) (float64, error)
+}
+
+type ratesPayload struct {
+	Rates map[string]float64 `json:"rates"`
+}
+
+func (p ratesPayload) vnd() (float64, error) {
+	value, ok := p.Rates["VND"]
+	if !ok {
+		return 0, fmt.Errorf("VND rate missing from payload")
+	}
+	return value, nil
+}
+
+// HTTPProvider reads a JSON document of the form {"rates": {"VND": 170.5}}
+// whose base currency is JPY, e.g. https://open.er-api.com/v6/latest/JPY.
+type HTTPProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p *HTTPProvider) Name() string {
+	return "http"
+}
+
+func (p *HTTPProvider) FetchJPYToVND(ctx context.Context) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching exchange rate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("exchange rate provider returned status %d", resp.StatusCode)
+	}
+
+	var payload ratesPayload
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return 0, fmt.Errorf("error decoding exchange rate response: %w", err)
+	}
+	return payload.vnd()
+}
+
+// FileProvider reads the same payload from a local file. It is meant for
+// development and as a deterministic fixture in tests.
+type FileProvider struct {
+	Path string
+}
+
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+func (p *FileProvider) FetchJPYToVND(ctx context.Context) (float64, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	var payload ratesPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return 0, fmt.Errorf("error decoding exchange rate file: %w", err)
+	}
+	return payload.vnd()
+}
+
+// ProviderFromConfig builds the configured provider, or returns nil when
+// automatic refreshing is disabled.
+func ProviderFromConfig(cfg config.ExchangeRateConfig) (Provider, error) {
+	switch kind := cfg.Provider; kind {
+	case "":
+		return nil, nil
+	case "http":
+		return &HTTPProvider{URL: cfg.URL}, nil
+	case "file":
+		if cfg.File == "" {
+			return nil, fmt.Errorf("EXCHANGE_RATE_FILE must be set for the file provider")
+		}
+		return &FileProvider{Path: cfg.File}, nil
+	default:
+		return nil, fmt.Errorf("unknown EXCHANGE_RATE_PROVIDER %q", kind)
+	}
+}
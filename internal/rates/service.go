@@ -0,0 +1,86 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultJPYToVND          = 185.0
+	DefaultServiceFeePercent = 0.05
+)
+
+// ErrNoRate means the exchange_rates table has no rate in effect. The
+// migrations seed a default row, so this only happens if it was deleted.
+var ErrNoRate = errors.New("no JPY→VND exchange rate in effect")
+
+// Current returns the newest JPY→VND rate that is already in effect. It only
+// reads; the default rate is seeded by the migrations.
+func Current(db *gorm.DB) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := db.Where("base_currency = ? AND quote_currency = ? AND effective_at <= ?", "JPY", "VND", time.Now()).
+		Order("effective_at desc, id desc").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoRate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// Refresh pulls the latest rate from provider and stores it as a new history
+// row, keeping the current service fee. Unchanged rates are not duplicated.
+func Refresh(ctx context.Context, db *gorm.DB, provider Provider) (*models.ExchangeRate, error) {
+	value, err := provider.FetchJPYToVND(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, errors.New("provider returned an invalid rate")
+	}
+
+	feePercent := DefaultServiceFeePercent
+	current, err := Current(db)
+	switch {
+	case errors.Is(err, ErrNoRate):
+	case err != nil:
+		return nil, err
+	case math.Abs(current.Rate-value) < 1e-9:
+		return current, nil
+	default:
+		feePercent = current.ServiceFeePercent
+	}
+
+	rate := models.ExchangeRate{
+		BaseCurrency:      "JPY",
+		QuoteCurrency:     "VND",
+		Rate:              value,
+		ServiceFeePercent: feePercent,
+		Source:            provider.Name(),
+		EffectiveAt:       time.Now(),
+	}
+	if err := db.Create(&rate).Error; err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func RunScheduledRefresh(db *gorm.DB, provider Provider) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rate, err := Refresh(ctx, db, provider)
+	if err != nil {
+		log.Printf("Exchange rate refresh from %s failed: %v", provider.Name(), err)
+		return
+	}
+	log.Printf("Exchange rate JPY→VND is now %.4f (source: %s)", rate.Rate, rate.Source)
+}
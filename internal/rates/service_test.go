@@ -0,0 +1,70 @@
+package rates_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/rates"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+)
+
+func TestFileProvider(t *testing.T) {
+	rate, err := (&rates.FileProvider{Path: "testdata/jpy_vnd.json"}).FetchJPYToVND(context.Background())
+	if err != nil || rate != 172.35 {
+		t.Fatalf("got %v, %v; want 172.35", rate, err)
+	}
+
+	if _, err := (&rates.FileProvider{Path: "testdata/missing_vnd.json"}).FetchJPYToVND(context.Background()); err == nil {
+		t.Fatal("a payload without VND must be rejected")
+	}
+}
+
+func TestCurrentIsReadOnly(t *testing.T) {
+	db := testdb.Open(t)
+
+	seeded, err := rates.Current(db)
+	if err != nil {
+		t.Fatalf("migrations should seed a default rate: %v", err)
+	}
+	if seeded.Source != models.RateSourceDefault || seeded.Rate != rates.DefaultJPYToVND {
+		t.Errorf("unexpected seed %+v", seeded)
+	}
+
+	db.Exec("DELETE FROM exchange_rates")
+	if _, err := rates.Current(db); !errors.Is(err, rates.ErrNoRate) {
+		t.Fatalf("empty table: got %v, want ErrNoRate", err)
+	}
+	var count int64
+	db.Model(&models.ExchangeRate{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Current inserted %d rows", count)
+	}
+}
+
+func TestRefreshFromFileProvider(t *testing.T) {
+	db := testdb.Open(t)
+	provider := &rates.FileProvider{Path: "testdata/jpy_vnd.json"}
+
+	rate, err := rates.Refresh(context.Background(), db, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate.Rate != 172.35 || rate.Source != "file" || rate.ServiceFeePercent != rates.DefaultServiceFeePercent {
+		t.Errorf("unexpected refreshed rate %+v", rate)
+	}
+
+	again, err := rates.Refresh(context.Background(), db, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != rate.ID {
+		t.Error("an unchanged rate must not add a history row")
+	}
+
+	current, _ := rates.Current(db)
+	if current.ID != rate.ID {
+		t.Errorf("current rate is %d, want refreshed row %d", current.ID, rate.ID)
+	}
+}
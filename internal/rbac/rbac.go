@@ -0,0 +1,102 @@
+package rbac
+
+import (
+	"sort"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	PermUsersRead         = "users:read"
+	PermUsersManageRoles  = "users:manage_roles"
+	PermProductsWrite     = "products:write"
+	PermInventoryManage   = "inventory:manage"
+	PermOrdersRead        = "orders:read"
+	PermOrdersUpdate      = "orders:update_status"
+	PermProxyOrdersManage = "proxy_orders:manage"
+	PermPaymentsRead      = "payments:read"
+	PermRatesManage       = "exchange_rates:manage"
+	PermCouponsManage     = "coupons:manage"
+	PermRewardsManage     = "rewards:manage"
+	PermChatRespond       = "chat:respond"
+	PermJobsManage        = "jobs:manage"
+	PermEmailsPreview     = "emails:preview"
+)
+
+const (
+	RoleAdmin         = "admin"
+	RoleWarehouse     = "warehouse"
+	RoleSupport       = "support"
+	RoleContentEditor = "content_editor"
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermUsersRead, PermUsersManageRoles, PermProductsWrite, PermInventoryManage,
+		PermOrdersRead, PermOrdersUpdate, PermProxyOrdersManage, PermPaymentsRead,
+		PermRatesManage, PermCouponsManage, PermRewardsManage, PermChatRespond,
+		PermJobsManage, PermEmailsPreview,
+	},
+	RoleWarehouse:     {PermInventoryManage, PermOrdersRead, PermOrdersUpdate, PermProxyOrdersManage},
+	RoleSupport:       {PermUsersRead, PermOrdersRead, PermProxyOrdersManage, PermPaymentsRead, PermChatRespond},
+	RoleContentEditor: {PermProductsWrite},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Roles lists every role with its permissions, for the admin UI.
+func Roles() map[string][]string {
+	return rolePermissions
+}
+
+func Has(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RolesFor returns the user's assigned roles. The legacy Admin flag still
+// counts as the admin role.
+func RolesFor(db *gorm.DB, user *models.User) ([]string, error) {
+	var roles []string
+	if err := db.Model(&models.UserRole{}).Where("user_id = ?", user.ID).Pluck("role", &roles).Error; err != nil {
+		return nil, err
+	}
+	if user.Admin && !contains(roles, RoleAdmin) {
+		roles = append(roles, RoleAdmin)
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+// SetRoles replaces the user's roles. The admin role is mirrored into
+// User.Admin so code that still reads the flag stays consistent.
+func SetRoles(tx *gorm.DB, userID uint, roles []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserRole{}).Error; err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if err := tx.Create(&models.UserRole{UserID: userID, Role: role}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).Update("admin", contains(roles, RoleAdmin)).Error
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
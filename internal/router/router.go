@@ -2,13 +2,22 @@ package router
 
 import (
 	"html/template"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/config"
 	"github.com/kaelCoding/toyBE/internal/database"
 	"github.com/kaelCoding/toyBE/internal/handlers"
 	"github.com/kaelCoding/toyBE/internal/chat"
+	"github.com/kaelCoding/toyBE/internal/payments"
+	"github.com/kaelCoding/toyBE/internal/ratelimit"
+	"github.com/kaelCoding/toyBE/internal/rates"
+	"github.com/kaelCoding/toyBE/internal/rbac"
+	"github.com/kaelCoding/toyBE/internal/services"
 )
 
 type Data struct {
@@ -29,35 +38,51 @@ func handler(c *gin.Context) {
 	}
 }
 
-func SetupRouter(hub *chat.Hub) *gin.Engine {
+func SetupRouter(cfg *config.Config, tokens *auth.Tokens, emails *services.Emailer, hub *chat.Hub, rateProvider rates.Provider, paymentProviders payments.Registry, limiter ratelimit.Store) *gin.Engine {
 	r := gin.Default()
 	db := database.DB
 
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{
-		"http://localhost:5173",
-		"https://tunitoku.netlify.app",
-		"https://tunitoku.store",
+	// ClientIP drives rate limiting and lockouts, so X-Forwarded-For is only
+	// believed from configured proxies. An empty list trusts none.
+	var trustedProxies []string
+	if len(cfg.Server.TrustedProxies) > 0 {
+		trustedProxies = cfg.Server.TrustedProxies
 	}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Accept", "X-Requested-With"}
-	config.AllowCredentials = true
-	r.Use(cors.New(config))
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.Server.CORSOrigins
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Accept", "X-Requested-With"}
+	corsConfig.ExposeHeaders = []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining"}
+	corsConfig.AllowCredentials = true
+	r.Use(cors.New(corsConfig))
 
 	r.GET("/", handler)
 
+	limit := func(name string, l ratelimit.Limit, key ratelimit.KeyFunc) gin.HandlerFunc {
+		return ratelimit.Middleware(limiter, ratelimit.Policy{Name: name, Limit: l, Key: key})
+	}
+
 	api := r.Group("/api/v1")
 	{
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", handlers.RegisterUser(db))
-			auth.POST("/login", handlers.LoginUser(db))
+			auth.POST("/register", limit("register", ratelimit.PerHour(10), ratelimit.ByIP), handlers.RegisterUser(db, emails))
+			auth.POST("/login", limit("login", ratelimit.PerMinute(10), ratelimit.ByIP), handlers.LoginUser(db, tokens))
+			auth.POST("/login/2fa", limit("login-2fa", ratelimit.PerMinute(10), ratelimit.ByIP), handlers.LoginTwoFactor(db, tokens))
+			auth.POST("/refresh", limit("refresh", ratelimit.PerMinute(30), ratelimit.ByIP), handlers.RefreshToken(db, tokens))
+			auth.POST("/verify-email", limit("verify-email", ratelimit.PerMinute(10), ratelimit.ByIP), handlers.VerifyEmail(db))
+			auth.POST("/forgot-password", limit("forgot-password", ratelimit.PerHour(5), ratelimit.ByIP), handlers.ForgotPassword(db, emails))
+			auth.POST("/reset-password", limit("reset-password", ratelimit.PerMinute(10), ratelimit.ByIP), handlers.ResetPassword(db))
 		}
 
 		proxyGroup := api.Group("/proxy")
 		{
-			proxyGroup.POST("/fetch", handlers.FetchMercariData)
-            proxyGroup.POST("/search", handlers.SearchMercariData)
+			proxyGroup.POST("/fetch", limit("proxy-fetch", ratelimit.PerMinute(10), ratelimit.ByIP), handlers.FetchMercariData)
+			proxyGroup.POST("/search", limit("proxy-search", ratelimit.PerMinute(20), ratelimit.ByIP), handlers.SearchMercariData)
 		}
 
 		api.GET("/products", handlers.GetProducts)
@@ -70,48 +95,109 @@ func SetupRouter(hub *chat.Hub) *gin.Engine {
 		api.GET("/categories/:id/products", handlers.GetProductsByCategory)
 		api.GET("/categories/:id/products/limit", handlers.GetProductsByCategoryIDWithLimit)
 
-		api.POST("/spin", handlers.SpinByShippingCode(db))
 		api.GET("/rewards", handlers.GetRewards(db))
-		api.POST("/feedback", handlers.SendFeedbackHandler)
+		api.POST("/feedback", handlers.SendFeedbackHandler(emails))
+		api.GET("/exchange-rate", handlers.GetCurrentExchangeRate(db))
+		api.GET("/payments/:provider/ipn", handlers.PaymentIPN(db, paymentProviders))
+		api.POST("/payments/:provider/ipn", handlers.PaymentIPN(db, paymentProviders))
 
 		api.GET("/sitemap/products", handlers.GetSitemapProducts(db))
         api.GET("/sitemap/categories", handlers.GetSitemapCategories(db))
 
 		protected := api.Group("/")
-		protected.Use(handlers.AuthMiddleware())
+		protected.Use(handlers.AuthMiddleware(db, tokens))
 		{
 			protected.GET("/profile", handlers.GetUser(db))
+			protected.POST("/auth/logout", handlers.Logout(db))
+			protected.POST("/auth/logout-all", handlers.LogoutAllDevices(db))
+			protected.POST("/auth/resend-verification", handlers.ResendVerificationEmail(db, emails))
+			protected.POST("/auth/2fa/setup", handlers.SetupTwoFactor(db))
+			protected.POST("/auth/2fa/enable", limit("2fa-enable", ratelimit.PerMinute(10), ratelimit.ByUser), handlers.EnableTwoFactor(db, tokens))
+			protected.POST("/auth/2fa/disable", limit("2fa-disable", ratelimit.PerMinute(10), ratelimit.ByUser), handlers.DisableTwoFactor(db, tokens, cfg.Auth))
+			protected.POST("/auth/2fa/backup-codes", limit("2fa-backup-codes", ratelimit.PerMinute(10), ratelimit.ByUser), handlers.RegenerateBackupCodes(db))
+			protected.GET("/me/vouchers", handlers.GetMyVouchers(db))
+			protected.POST("/spin", limit("spin", ratelimit.PerMinute(10), ratelimit.ByUser), handlers.SpinOrder(db, limiter, ratelimit.Policy{
+				Name: "spin-failures", Limit: ratelimit.Limit{Requests: 5, Per: 15 * time.Minute}, Key: ratelimit.ByUser,
+			}))
 			// protected.POST("/orders", handlers.CreateOrderHandler)
-			protected.POST("/proxy/order", handlers.CreateProxyOrder(db)) 
-			protected.POST("/cart/checkout", handlers.CreateOrderFromCart)
+			protected.POST("/proxy/order", handlers.CreateProxyOrder(db, paymentProviders, emails)) 
+			protected.GET("/proxy/orders", handlers.GetMyProxyOrders(db))
+			protected.GET("/proxy/orders/:id", handlers.GetMyProxyOrderByID(db))
+			protected.GET("/orders", handlers.GetMyOrders(db))
+			protected.GET("/orders/:id", handlers.GetMyOrderByID(db))
+			protected.POST("/orders/:id/pay", handlers.CreateOrderPayment(db, paymentProviders))
+			protected.POST("/proxy/orders/:id/pay", handlers.CreateProxyOrderPayment(db, paymentProviders))
+			protected.POST("/cart/checkout", handlers.CreateOrderFromCart(db, paymentProviders, emails))
 			protected.GET("/cart", handlers.GetCart(db))
+			protected.POST("/cart/apply-coupon", handlers.ApplyCoupon(db))
             protected.POST("/cart", handlers.AddToCart(db))
             protected.PUT("/cart/items/:id", handlers.UpdateCartItemQuantity(db))
             protected.DELETE("/cart/items/:id", handlers.DeleteCartItem(db))
-			protected.GET("/ws", handlers.ChatEndpoint(hub, db))
-            protected.GET("/chat/history", handlers.GetChatHistory(db))
+			protected.GET("/ws", handlers.StaffMFAMiddleware(cfg.Auth), handlers.ChatEndpoint(hub, db))
+            protected.GET("/chat/history", handlers.StaffMFAMiddleware(cfg.Auth), handlers.GetChatHistory(db))
 			protected.GET("/admin-info", handlers.GetAdminInfo(db))
 		}
 
 		admin := api.Group("/admin")
-		admin.Use(handlers.AuthMiddleware())
-		admin.Use(handlers.AdminOnlyMiddleware())
+		admin.Use(handlers.AuthMiddleware(db, tokens))
+		admin.Use(handlers.StaffOnlyMiddleware(cfg.Auth))
 		{
-			admin.GET("/users", handlers.GetAllUsers(db))
-
-			admin.POST("/products", handlers.AddProduct)
-			admin.PUT("/products/:id", handlers.UpdateProduct)
-			admin.DELETE("/products/:id", handlers.DeleteProduct)
-
-			admin.POST("/categories", handlers.AddCategory)
-			admin.PUT("/categories/:id", handlers.UpdateCategory)
-			admin.DELETE("/categories/:id", handlers.DeleteCategory)
-
-			admin.GET("/orders", handlers.GetAllOrders(db))
-    		admin.PUT("/orders/:id/shipping-code", handlers.UpdateShippingCode(db))
-			admin.POST("/rewards", handlers.AddReward(db))
-			admin.PUT("/rewards/:id", handlers.UpdateReward(db))
-			admin.DELETE("/rewards/:id", handlers.DeleteReward(db))
+			can := handlers.RequirePermission
+
+			admin.GET("/users", can(rbac.PermUsersRead), handlers.GetAllUsers(db))
+			admin.GET("/roles", can(rbac.PermUsersManageRoles), handlers.GetRoles())
+			admin.GET("/users/:id/roles", can(rbac.PermUsersManageRoles), handlers.GetUserRoles(db))
+			admin.PUT("/users/:id/roles", can(rbac.PermUsersManageRoles), handlers.AssignUserRoles(db))
+
+			admin.POST("/products", can(rbac.PermProductsWrite), handlers.AddProduct)
+			admin.PUT("/products/:id", can(rbac.PermProductsWrite), handlers.UpdateProduct)
+			admin.DELETE("/products/:id", can(rbac.PermProductsWrite), handlers.DeleteProduct)
+			admin.POST("/products/:id/stock", can(rbac.PermInventoryManage), handlers.AdjustProductStock(db))
+			admin.GET("/products/:id/stock", can(rbac.PermInventoryManage), handlers.GetStockAdjustments(db))
+
+			admin.POST("/categories", can(rbac.PermProductsWrite), handlers.AddCategory)
+			admin.PUT("/categories/:id", can(rbac.PermProductsWrite), handlers.UpdateCategory)
+			admin.DELETE("/categories/:id", can(rbac.PermProductsWrite), handlers.DeleteCategory)
+
+			admin.GET("/orders", can(rbac.PermOrdersRead), handlers.GetAllOrders(db))
+			admin.PUT("/orders/:id/status", can(rbac.PermOrdersUpdate), handlers.UpdateOrderStatus(db, paymentProviders))
+			admin.GET("/orders/:id/status-history", can(rbac.PermOrdersRead), handlers.GetOrderStatusHistory(db))
+			admin.PUT("/orders/:id/shipping-code", can(rbac.PermOrdersUpdate), handlers.UpdateShippingCode(db))
+			admin.GET("/proxy-orders", can(rbac.PermProxyOrdersManage), handlers.GetAllProxyOrders(db))
+			admin.GET("/proxy-orders/:id", can(rbac.PermProxyOrdersManage), handlers.GetProxyOrderByID(db))
+			admin.PUT("/proxy-orders/:id/quote", can(rbac.PermProxyOrdersManage), handlers.QuoteProxyOrder(db, emails))
+			admin.PUT("/proxy-orders/:id/status", can(rbac.PermProxyOrdersManage), handlers.UpdateProxyOrderStatus(db, paymentProviders, emails))
+			admin.GET("/payments", can(rbac.PermPaymentsRead), handlers.GetAllPayments(db))
+			admin.GET("/exchange-rates", can(rbac.PermRatesManage), handlers.GetExchangeRateHistory(db))
+			admin.POST("/exchange-rates", can(rbac.PermRatesManage), handlers.AddExchangeRate(db))
+			admin.POST("/exchange-rates/refresh", can(rbac.PermRatesManage), handlers.RefreshExchangeRate(db, rateProvider))
+			admin.PUT("/exchange-rates/:id", can(rbac.PermRatesManage), handlers.UpdateExchangeRate(db))
+			admin.DELETE("/exchange-rates/:id", can(rbac.PermRatesManage), handlers.DeleteExchangeRate(db))
+			admin.GET("/coupons", can(rbac.PermCouponsManage), handlers.GetCoupons(db))
+			admin.POST("/coupons", can(rbac.PermCouponsManage), handlers.AddCoupon(db))
+			admin.PUT("/coupons/:id", can(rbac.PermCouponsManage), handlers.UpdateCoupon(db))
+			admin.DELETE("/coupons/:id", can(rbac.PermCouponsManage), handlers.DeleteCoupon(db))
+			admin.GET("/coupons/:id/redemptions", can(rbac.PermCouponsManage), handlers.GetCouponRedemptions(db))
+			admin.GET("/vouchers", can(rbac.PermRewardsManage), handlers.GetAllVouchers(db))
+			admin.GET("/vouchers/summary", can(rbac.PermRewardsManage), handlers.GetVoucherSummary(db))
+			admin.GET("/spin-campaigns", can(rbac.PermRewardsManage), handlers.GetSpinCampaigns(db))
+			admin.POST("/spin-campaigns", can(rbac.PermRewardsManage), handlers.AddSpinCampaign(db))
+			admin.PUT("/spin-campaigns/:id", can(rbac.PermRewardsManage), handlers.UpdateSpinCampaign(db))
+			admin.DELETE("/spin-campaigns/:id", can(rbac.PermRewardsManage), handlers.DeleteSpinCampaign(db))
+			admin.GET("/spin-campaigns/:id/preview", can(rbac.PermRewardsManage), handlers.PreviewSpinOdds(db))
+			admin.GET("/spin-analytics/daily", can(rbac.PermRewardsManage), handlers.GetDailySpins(db))
+			admin.GET("/spin-analytics/rewards", can(rbac.PermRewardsManage), handlers.GetRewardDistribution(db))
+			admin.POST("/rewards", can(rbac.PermRewardsManage), handlers.AddReward(db))
+			admin.PUT("/rewards/:id", can(rbac.PermRewardsManage), handlers.UpdateReward(db))
+			admin.DELETE("/rewards/:id", can(rbac.PermRewardsManage), handlers.DeleteReward(db))
+
+			admin.GET("/jobs", can(rbac.PermJobsManage), handlers.GetJobs(db))
+			admin.GET("/jobs/summary", can(rbac.PermJobsManage), handlers.GetJobSummary(db))
+			admin.GET("/jobs/:id", can(rbac.PermJobsManage), handlers.GetJobByID(db))
+			admin.POST("/jobs/:id/retry", can(rbac.PermJobsManage), handlers.RetryJob(db))
+
+			admin.GET("/email-templates", can(rbac.PermEmailsPreview), handlers.GetEmailTemplates())
+			admin.GET("/email-templates/:name/preview", can(rbac.PermEmailsPreview), handlers.PreviewEmailTemplate(emails))
 		}
 	}
 
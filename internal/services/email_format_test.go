@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+func TestFormatRefusesOtherCurrencies(t *testing.T) {
+	if got, err := formatVND(money.FromVND(150000)); err != nil || got != "150.000 VNĐ" {
+		t.Errorf("formatVND = %q, %v", got, err)
+	}
+	if _, err := formatVND(money.FromJPY(1500)); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("formatVND(JPY): got %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := formatJPY(money.FromVND(1500)); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("formatJPY(VND): got %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestRenderFailsOnMislabelledAmount(t *testing.T) {
+	data, err := NewEmailer(config.EmailConfig{}, nil).SampleEmailData(TemplateOrderInvoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RenderEmail(TemplateOrderInvoice, data); err != nil {
+		t.Fatalf("sample invoice should render: %v", err)
+	}
+
+	order := data.(orderEmailData)
+	order.Order.OrderItems = append([]models.OrderItem(nil), order.Order.OrderItems...)
+	order.Order.OrderItems[0].Price = money.FromJPY(3500)
+	if _, err := RenderEmail(TemplateOrderInvoice, order); err == nil {
+		t.Fatal("an invoice line priced in JPY must not render as VND")
+	}
+}
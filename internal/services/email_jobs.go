@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/jobs"
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+)
+
+// Each email is its own job so a retry never resends one that already went out.
+const (
+	JobOrderAdminNotification      = "email.order_admin_notification"
+	JobOrderCustomerInvoice        = "email.order_customer_invoice"
+	JobProxyOrderAdminNotification = "email.proxy_order_admin_notification"
+	JobProxyOrderCustomerInvoice   = "email.proxy_order_customer_invoice"
+	JobProxyQuoteEmail             = "email.proxy_quote"
+	JobProxyStatusEmail            = "email.proxy_status_update"
+	JobVerificationEmail           = "email.verify_email"
+	JobPasswordResetEmail          = "email.password_reset"
+)
+
+type OrderEmailPayload struct {
+	OrderID uint `json:"orderId"`
+}
+
+// ProxyStatusEmailPayload carries the status being announced, so a job that
+// runs after a later change still describes the one it was queued for.
+type ProxyStatusEmailPayload struct {
+	OrderID uint   `json:"orderId"`
+	Status  string `json:"status"`
+}
+
+// EnqueueOrderEmails schedules the admin notification, when RECIPIENT_EMAIL
+// is set, and the invoice, when the order has a customer email. Call it
+// inside the checkout transaction.
+func (e *Emailer) EnqueueOrderEmails(tx *gorm.DB, order models.Order) error {
+	payload := OrderEmailPayload{OrderID: order.ID}
+	if e.cfg.RecipientEmail != "" {
+		if _, err := jobs.Enqueue(tx, JobOrderAdminNotification, payload); err != nil {
+			return err
+		}
+	}
+	if order.CustomerEmail != "" {
+		if _, err := jobs.Enqueue(tx, JobOrderCustomerInvoice, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Emailer) EnqueueProxyOrderEmails(tx *gorm.DB, order models.ProxyOrder) error {
+	payload := OrderEmailPayload{OrderID: order.ID}
+	if e.cfg.RecipientEmail != "" {
+		if _, err := jobs.Enqueue(tx, JobProxyOrderAdminNotification, payload); err != nil {
+			return err
+		}
+	}
+	if order.CustomerEmail != "" {
+		if _, err := jobs.Enqueue(tx, JobProxyOrderCustomerInvoice, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnqueueProxyQuoteEmail schedules the quote for the customer. Call it in the
+// transaction that saves the quote; the order must have a customer email.
+func (e *Emailer) EnqueueProxyQuoteEmail(tx *gorm.DB, order models.ProxyOrder) error {
+	_, err := jobs.Enqueue(tx, JobProxyQuoteEmail, OrderEmailPayload{OrderID: order.ID})
+	return err
+}
+
+// EnqueueProxyStatusEmail tells the customer about order.Status.
+func (e *Emailer) EnqueueProxyStatusEmail(tx *gorm.DB, order models.ProxyOrder) error {
+	_, err := jobs.Enqueue(tx, JobProxyStatusEmail, ProxyStatusEmailPayload{OrderID: order.ID, Status: order.Status})
+	return err
+}
+
+// AccountEmailPayload never carries the emailed token: it is issued when the
+// job runs, so the raw value is not left sitting in the jobs table.
+type AccountEmailPayload struct {
+	UserID uint   `json:"userId,omitempty"`
+	Email  string `json:"email,omitempty"`
+}
+
+// EnqueueVerificationEmail schedules a fresh verification link for the user.
+func (e *Emailer) EnqueueVerificationEmail(tx *gorm.DB, userID uint) error {
+	_, err := jobs.Enqueue(tx, JobVerificationEmail, AccountEmailPayload{UserID: userID})
+	return err
+}
+
+// EnqueuePasswordReset schedules a reset link for whoever owns email, if
+// anyone. The account lookup happens in the job, so the caller does the same
+// work whether or not the address is registered.
+func (e *Emailer) EnqueuePasswordReset(tx *gorm.DB, email string) error {
+	_, err := jobs.Enqueue(tx, JobPasswordResetEmail, AccountEmailPayload{Email: email})
+	return err
+}
+
+func loadOrder(db *gorm.DB, payload json.RawMessage) (models.Order, error) {
+	var p OrderEmailPayload
+	var order models.Order
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return order, err
+	}
+	err := db.Preload("User").Preload("OrderItems.Product").First(&order, p.OrderID).Error
+	return order, err
+}
+
+func loadProxyOrder(db *gorm.DB, payload json.RawMessage) (models.ProxyOrder, error) {
+	var p OrderEmailPayload
+	var order models.ProxyOrder
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return order, err
+	}
+	err := db.Preload("User").First(&order, p.OrderID).Error
+	return order, err
+}
+
+// RegisterEmailJobs wires the email job types into the job queue; every job
+// sends through e.
+func RegisterEmailJobs(e *Emailer) {
+	jobs.Register(JobOrderAdminNotification, func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		order, err := loadOrder(db, payload)
+		if err != nil {
+			return err
+		}
+		return e.SendOrderConfirmationEmail(ctx, order)
+	})
+	jobs.Register(JobOrderCustomerInvoice, func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		order, err := loadOrder(db, payload)
+		if err != nil {
+			return err
+		}
+		if order.CustomerEmail == "" {
+			return errors.New("order has no customer email")
+		}
+		return e.SendInvoiceToCustomer(ctx, order, order.CustomerEmail)
+	})
+	jobs.Register(JobProxyOrderAdminNotification, func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		order, err := loadProxyOrder(db, payload)
+		if err != nil {
+			return err
+		}
+		return e.SendProxyOrderConfirmationEmail(ctx, order)
+	})
+	jobs.Register(JobProxyOrderCustomerInvoice, func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		order, err := loadProxyOrder(db, payload)
+		if err != nil {
+			return err
+		}
+		if order.CustomerEmail == "" {
+			return errors.New("proxy order has no customer email")
+		}
+		return e.SendProxyInvoiceToCustomer(ctx, order, order.CustomerEmail)
+	})
+	jobs.Register(JobProxyQuoteEmail, func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		order, err := loadProxyOrder(db, payload)
+		if err != nil {
+			return err
+		}
+		if order.CustomerEmail == "" {
+			return errors.New("proxy order has no customer email")
+		}
+		return e.SendProxyQuoteEmail(ctx, order)
+	})
+	jobs.Register(JobProxyStatusEmail, func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		var p ProxyStatusEmailPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		order, err := loadProxyOrder(db, payload)
+		if err != nil {
+			return err
+		}
+		if order.CustomerEmail == "" {
+			return errors.New("proxy order has no customer email")
+		}
+		order.Status = p.Status
+		return e.SendProxyStatusUpdateEmail(ctx, order)
+	})
+	jobs.Register(JobVerificationEmail, func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		var p AccountEmailPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		var user models.User
+		if err := db.First(&user, p.UserID).Error; err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		token, err := auth.CreateUserToken(db, user.ID, models.UserTokenVerifyEmail, auth.VerifyEmailTTL)
+		if err != nil {
+			return err
+		}
+		return e.SendVerificationEmail(ctx, user, token)
+	})
+	jobs.Register(JobPasswordResetEmail, func(ctx context.Context, db *gorm.DB, payload json.RawMessage) error {
+		var p AccountEmailPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		var user models.User
+		err := db.Where("LOWER(email) = LOWER(?)", p.Email).Order("id asc").First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Không có tài khoản nào với email này: không gửi gì cả.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		token, err := auth.CreateUserToken(db, user.ID, models.UserTokenResetPassword, auth.ResetPasswordTTL)
+		if err != nil {
+			return err
+		}
+		return e.SendPasswordResetEmail(ctx, user, token)
+	})
+}
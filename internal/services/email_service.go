@@ -1,386 +1,171 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
-	"strconv"
-	"strings"
 
+	"github.com/kaelCoding/toyBE/internal/config"
+	"github.com/kaelCoding/toyBE/internal/mail"
 	"github.com/kaelCoding/toyBE/internal/models"
-	"github.com/resend/resend-go/v2"
+	"github.com/kaelCoding/toyBE/internal/money"
 )
 
-const shippingFee = 50000 
-const proxyShippingNote = "195.000 VNĐ/kg" 
-
-func formatVND(amount float64) string {
-	roundedAmount := int(amount + 0.5)
-	s := strconv.Itoa(roundedAmount)
-	n := len(s)
-	if n <= 3 {
-		return s + " VNĐ"
-	}
+// Emailer renders the shop's emails and hands them to the configured
+// transport. Build one at startup and pass it to whatever sends mail.
+type Emailer struct {
+	cfg    config.EmailConfig
+	mailer mail.Mailer
+}
 
-	var result strings.Builder
-	for i, r := range s {
-		result.WriteRune(r)
-		if (n-1-i)%3 == 0 && i != n-1 {
-			result.WriteRune('.')
-		}
-	}
-	return result.String() + " VNĐ"
+func NewEmailer(cfg config.EmailConfig, m mail.Mailer) *Emailer {
+	return &Emailer{cfg: cfg, mailer: m}
 }
 
-func formatJPY(amount float64) string {
-    roundedAmount := int(amount + 0.5)
-    s := strconv.Itoa(roundedAmount)
-    n := len(s)
-    if n <= 3 {
-        return "¥" + s
-    }
+var ProxyShippingRatePerKg = money.FromVND(195000)
+
+var proxyShippingNote = ProxyShippingRatePerKg.String() + "/kg"
 
-    var result strings.Builder
-    result.WriteRune('¥')
-    for i, r := range s {
-        result.WriteRune(r)
-        if (n-1-i)%3 == 0 && i != n-1 {
-            result.WriteRune(',')
-        }
-    }
-    return result.String()
+var proxyStatusLabels = map[string]string{
+	models.ProxyStatusQuoted:           "Đã báo giá",
+	models.ProxyStatusPaid:             "Đã thanh toán",
+	models.ProxyStatusPurchasedInJapan: "Đã mua hàng tại Nhật",
+	models.ProxyStatusArrivedWarehouse: "Hàng đã về kho Nhật",
+	models.ProxyStatusShippedToVN:      "Đang vận chuyển về Việt Nam",
+	models.ProxyStatusDelivered:        "Đã giao hàng",
+	models.ProxyStatusCancelled:        "Đã hủy",
 }
 
+// formatVND and formatJPY refuse amounts in another currency instead of
+// relabelling them; the template then fails to render and nothing is sent.
+func formatVND(amount money.Money) (string, error) {
+	return formatIn(amount, money.VND)
+}
 
-func sendEmail(to, subject, htmlBody string) error {
-	apiKey := os.Getenv("RESEND_API_KEY")
-	fromEmail := os.Getenv("RESEND_FROM_EMAIL")
+func formatJPY(amount money.Money) (string, error) {
+	return formatIn(amount, money.JPY)
+}
 
-	if apiKey == "" || fromEmail == "" {
-		return fmt.Errorf("RESEND_API_KEY and RESEND_FROM_EMAIL must be set")
+func formatIn(amount money.Money, currency money.Currency) (string, error) {
+	if amount.Currency != "" && amount.Currency != currency {
+		return "", fmt.Errorf("%w: expected %s, got %s", money.ErrCurrencyMismatch, currency, amount.Currency)
 	}
+	return money.New(amount.Amount, currency).String(), nil
+}
 
-	client := resend.NewClient(apiKey)
-
-	params := &resend.SendEmailRequest{
-		From:    fromEmail,
-		To:      []string{to},
-		Subject: subject,
-		Html:    htmlBody,
+func formatCoupon(order models.Order) (string, error) {
+	if order.CouponCode == "" {
+		return formatVND(money.FromVND(0))
 	}
-
-	sent, err := client.Emails.Send(params)
+	amount, err := formatVND(order.CouponDiscount)
 	if err != nil {
-		return fmt.Errorf("error sending email: %w", err)
+		return "", err
 	}
-
-	log.Printf("Email sent successfully to %s, ID: %s\n", to, sent.Id)
-	return nil
+	return fmt.Sprintf("%s (%s)", amount, order.CouponCode), nil
 }
 
-func SendOrderConfirmationEmail(order models.Order) error {
-	if len(order.OrderItems) == 0 {
-		return fmt.Errorf("order %d has no items", order.ID)
+func formatVoucher(order models.Order) (string, error) {
+	if order.VoucherCode == "" {
+		return formatVND(money.FromVND(0))
+	}
+	if order.VoucherDiscount.IsZero() {
+		return fmt.Sprintf("Quà tặng (%s)", order.VoucherCode), nil
 	}
+	amount, err := formatVND(order.VoucherDiscount)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (%s)", amount, order.VoucherCode), nil
+}
 
-	var currentShippingFee float64
-	if order.User.VIPLevel >= 2 {
-		currentShippingFee = 0 
-	} else {
-		currentShippingFee = float64(shippingFee)
+func (e *Emailer) sendEmail(ctx context.Context, to, templateName string, data any) error {
+	if e.mailer == nil {
+		return fmt.Errorf("email is not configured")
 	}
-	
-	finalTotal := order.TotalAmount + currentShippingFee 
-	recipientEmail := os.Getenv("RECIPIENT_EMAIL")
 
-	var itemsHTML strings.Builder
-	itemsHTML.WriteString(`
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse; width: 100%;">
-            <tr style="background-color: #f2f2f2;">
-                <th>Sản phẩm</th>
-                <th>Số lượng</th>
-                <th>Đơn giá</th>
-                <th>Tổng</th>
-            </tr>
-    `)
-	for _, item := range order.OrderItems {
-		itemsHTML.WriteString(fmt.Sprintf(`
-            <tr>
-                <td>%s</td>
-                <td>%d</td>
-                <td>%s</td>
-                <td>%s</td>
-            </tr>
-        `, item.Product.Name, item.Quantity, formatVND(item.Price), formatVND(item.Price*float64(item.Quantity))))
+	// Resend và SMTP luôn có FromEmail (đã kiểm tra trong config); outbox thì không cần.
+	from := e.cfg.FromEmail
+	if from == "" {
+		from = "no-reply@localhost"
 	}
-	itemsHTML.WriteString("</table>")
 
-	body := fmt.Sprintf(`
-        <h1>🎉 Bạn có đơn hàng mới!</h1>
-        <p>Thông tin chi tiết đơn hàng:</p>
-        %s 
-        <br>
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse; width: 100%;">
-            <tr><td style="background-color: #f2f2f2;"><strong>Thành tiền</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Giảm giá VIP</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Phí ship</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Tổng thanh toán</strong></td><td><strong>%s</strong></td></tr>
-            <tr><td colspan="2" style="background-color: #f2f2f2; text-align: center;"><strong>Thông tin khách hàng</strong></td></tr>
-            <tr><td><strong>Họ và tên</strong></td><td>%s</td></tr>
-            <tr><td><strong>Số điện thoại</strong></td><td>%s</td></tr>
-            <tr><td><strong>Email</strong></td><td>%s</td></tr>
-            <tr><td><strong>Địa chỉ</strong></td><td>%s</td></tr>
-            <tr><td><strong>Phương thức thanh toán</strong></td><td>%s</td></tr>
-        </table>
-        <p>Vui lòng liên hệ khách hàng để xác nhận và xử lý đơn hàng.</p>
-    `,
-		itemsHTML.String(),                    
-		formatVND(order.OriginalAmount),       
-		formatVND(order.OriginalAmount),        
-		formatVND(order.DiscountApplied),      
-		formatVND(currentShippingFee),         
-		formatVND(finalTotal),                 
-		order.CustomerName,                    
-		order.CustomerPhone,                   
-		order.CustomerEmail,                   
-		order.CustomerAddress,                 
-		order.PaymentMethod)                   
+	rendered, err := RenderEmail(templateName, data)
+	if err != nil {
+		return err
+	}
 
-	subject := fmt.Sprintf("Đơn hàng mới #%d từ %s", order.ID, order.CustomerName)
+	msg := mail.Message{
+		From:    from,
+		To:      []string{to},
+		Subject: rendered.Subject,
+		HTML:    rendered.HTML,
+		Text:    rendered.Text,
+	}
+	if err := e.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
 
-	return sendEmail(recipientEmail, subject, body)
+	log.Printf("Email %s sent to %s via %s\n", templateName, to, e.mailer.Name())
+	return nil
 }
 
-func SendInvoiceToCustomer(order models.Order, customerEmail string) error {
+func (e *Emailer) SendOrderConfirmationEmail(ctx context.Context, order models.Order) error {
 	if len(order.OrderItems) == 0 {
 		return fmt.Errorf("order %d has no items", order.ID)
 	}
 
-	var currentShippingFee float64
-	if order.User.VIPLevel >= 2 {
-		currentShippingFee = 0 
-	} else {
-		currentShippingFee = float64(shippingFee)
-	}
-
-	finalTotal := order.TotalAmount + currentShippingFee
-	qrImageUrl := "https://pub-be6c7e6475cd42219bb9999d8fbb5743.r2.dev/products/image.png"
+	return e.sendEmail(ctx, e.cfg.RecipientEmail, TemplateOrderAdminNotification, newOrderEmailData(order, "Thông tin khách hàng"))
+}
 
-	var itemsHTML strings.Builder
-	itemsHTML.WriteString(`
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse; width: 100%;">
-            <tr style="background-color: #f2f2f2;">
-                <th>Sản phẩm</th>
-                <th>Số lượng</th>
-                <th>Đơn giá</th>
-                <th>Tổng</th>
-            </tr>
-    `)
-	for _, item := range order.OrderItems {
-		itemsHTML.WriteString(fmt.Sprintf(`
-            <tr>
-                <td>%s</td>
-                <td>%d</td>
-                <td>%s</td>
-                <td>%s</td>
-            </tr>
-        `, item.Product.Name, item.Quantity, formatVND(item.Price), formatVND(item.Price*float64(item.Quantity))))
+func (e *Emailer) SendInvoiceToCustomer(ctx context.Context, order models.Order, customerEmail string) error {
+	if len(order.OrderItems) == 0 {
+		return fmt.Errorf("order %d has no items", order.ID)
 	}
-	itemsHTML.WriteString("</table>")
 
-	body := fmt.Sprintf(`
-        <h1>Cảm ơn bạn đã đặt hàng tại TUNI TOKU!</h1>
-        <p>Chào <b>%s</b>,</p>
-        <p>Đơn hàng của bạn đã được tiếp nhận thành công. Chúng tôi sẽ sớm liên hệ với bạn để xác nhận và tiến hành giao hàng.</p>
-        <h2>Chi tiết đơn hàng:</h2>
-        %s
-        <br>
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse; width: 100%;">
-            <tr><td style="background-color: #f2f2f2;"><strong>Thành tiền</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Giảm giá VIP</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Phí ship</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Tổng thanh toán</strong></td><td><strong>%s</strong></td></tr>
-            <tr><td colspan="2" style="background-color: #f2f2f2; text-align: center;"><strong>Thông tin nhận hàng</strong></td></tr>
-            <tr><td><strong>Họ và tên</strong></td><td>%s</td></tr>
-            <tr><td><strong>Số điện thoại</strong></td><td>%s</td></tr>
-            <tr><td><strong>Email</strong></td><td>%s</td></tr>
-            <tr><td><strong>Địa chỉ</strong></td><td>%s</td></tr>
-            <tr><td><strong>Phương thức thanh toán</strong></td><td>%s</td></tr>
-        </table>
-        <h3>Mã QR code chuyển khoản:</h3>
-        <p>Quét mã QR bên dưới để thanh toán(Tiền ship/Tổng tiền đơn hàng).</p>
-        <img src="%s" style="width: 250px; height: 250px;" alt="QR Code">
-        <p>Cảm ơn bạn đã tin tưởng và mua sắm tại TUNI TOKU!</p>
-    `,
-		order.CustomerName,                     
-		itemsHTML.String(),                     
-		formatVND(order.OriginalAmount),        
-		formatVND(order.OriginalAmount),        
-		formatVND(order.DiscountApplied),       
-		formatVND(currentShippingFee),          
-		formatVND(finalTotal),                  
-		order.CustomerName,                     
-		order.CustomerPhone,                    
-		order.CustomerEmail,                    
-		order.CustomerAddress,                 
-		order.PaymentMethod,                   
-		qrImageUrl)                           
-
-	subject := fmt.Sprintf("Xác nhận đơn hàng #%d từ TUNI TOKU", order.ID)
-
-	return sendEmail(customerEmail, subject, body)
+	return e.sendEmail(ctx, customerEmail, TemplateOrderInvoice, newOrderEmailData(order, "Thông tin nhận hàng"))
 }
 
-func SendFeedbackEmail(feedback models.Feedback) error {
-    recipientEmail := os.Getenv("RECIPIENT_EMAIL")
-
-    body := fmt.Sprintf(`
-        <h1>💡 Bạn có một góp ý mới từ người dùng!</h1>
-        <p>Thông tin chi tiết góp ý:</p>
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse;">
-            <tr><td style="background-color: #f2f2f2;"><strong>Tên người gửi</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Email</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Nội dung góp ý</strong></td><td>%s</td></tr>
-        </table>
-        <p>Vui lòng xem xét góp ý này để cải thiện dịch vụ.</p>
-    `, feedback.Name, feedback.Email, feedback.Content)
-
-    subject := fmt.Sprintf("Góp ý mới từ: %s", feedback.Name)
-    
-    return sendEmail(recipientEmail, subject, body)
+func (e *Emailer) SendFeedbackEmail(ctx context.Context, feedback models.Feedback) error {
+	return e.sendEmail(ctx, e.cfg.RecipientEmail, TemplateFeedback, feedbackEmailData{Feedback: feedback})
 }
 
-func SendProxyOrderConfirmationEmail(order models.ProxyOrder) error {
-	recipientEmail := os.Getenv("RECIPIENT_EMAIL")
-	singleItemTotal := order.TotalAmountVND / float64(order.Quantity)
-	basePriceVND := singleItemTotal - order.ServiceFee
-	
-	itemsHTML := fmt.Sprintf(`
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse; width: 100%;">
-            <tr style="background-color: #f2f2f2;">
-                <th>Sản phẩm</th>
-                <th>Giá gốc (JPY)</th>
-                <th>Số lượng</th>
-                <th>Tổng (VND)</th>
-            </tr>
-             <tr>
-                <td>%s</td>
-                <td>%s</td>
-                <td>%d</td>
-                <td>%s</td>
-            </tr>
-        </table>
-    `, 
-    order.ProductName, 
-    order.ProductName, 
-    formatJPY(order.ProductPriceJPY), 
-    order.Quantity, 
-    formatVND(order.TotalAmountVND))
+func (e *Emailer) SendProxyOrderConfirmationEmail(ctx context.Context, order models.ProxyOrder) error {
+	data, err := newProxyOrderEmailData(order, "Thông tin khách hàng")
+	if err != nil {
+		return err
+	}
+	return e.sendEmail(ctx, e.cfg.RecipientEmail, TemplateProxyOrderAdminNotification, data)
+}
 
-	body := fmt.Sprintf(`
-        <h1>🎉 Bạn có đơn hàng đặt hộ Mercari mới!</h1>
-        <p>Link gốc sản phẩm: <a href="%s">%s</a></p>
-        <p>Thông tin chi tiết đơn hàng:</p>
-        %s
-        <br>
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse; width: 100%;">
-            <tr><td style="background-color: #f2f2f2;"><strong>Giá quy đổi (1 sp)</strong></td><td%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Phí dịch vụ (1 sp)</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Thành tiền</strong></td><td><strong>%s</strong></td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Phí ship (dự kiến)</strong></td><td>%s (Sẽ báo sau khi hàng về kho)</td></tr>
-            <tr><td colspan="2" style="background-color: #f2f2f2; text-align: center;"><strong>Thông tin khách hàng</strong></td></tr>
-            <tr><td><strong>Họ và tên</strong></td><td>%s</td></tr>
-            <tr><td><strong>Số điện thoại</strong></td><td>%s</td></tr>
-            <tr><td><strong>Email</strong></td><td>%s</td></tr>
-            <tr><td><strong>Địa chỉ</strong></td><td>%s</td></tr>
-            <tr><td><strong>Phương thức thanh toán</strong></td><td>%s</td></tr>
-        </table>
-        <p>Vui lòng liên hệ khách hàng để xác nhận và xử lý đơn hàng.</p>
-    `,
-		order.MercariURL,                
-		order.MercariURL,                
-		itemsHTML,                       
-		formatVND(basePriceVND),         
-		formatVND(basePriceVND),         
-		formatVND(order.ServiceFee),     
-		formatVND(order.TotalAmountVND), 
-		proxyShippingNote,               
-		order.CustomerName,              
-		order.CustomerPhone,             
-		order.CustomerEmail,             
-		order.CustomerAddress,           
-		order.PaymentMethod)             
+func (e *Emailer) SendProxyInvoiceToCustomer(ctx context.Context, order models.ProxyOrder, customerEmail string) error {
+	data, err := newProxyOrderEmailData(order, "Thông tin nhận hàng")
+	if err != nil {
+		return err
+	}
+	return e.sendEmail(ctx, customerEmail, TemplateProxyOrderInvoice, data)
+}
 
-	subject := fmt.Sprintf("Đơn hàng đặt hộ Mercari MỚI #%d từ %s", order.ID, order.CustomerName)
-	
-	return sendEmail(recipientEmail, subject, body)
+func (e *Emailer) SendProxyQuoteEmail(ctx context.Context, order models.ProxyOrder) error {
+	data := proxyQuoteEmailData{Order: order, ShippingNote: proxyShippingNote}
+	return e.sendEmail(ctx, order.CustomerEmail, TemplateProxyQuote, data)
 }
 
-func SendProxyInvoiceToCustomer(order models.ProxyOrder, customerEmail string) error {
-	qrImageUrl := "https://pub-be6c7e6475cd42219bb9999d8fbb5743.r2.dev/products/image.png"
-	singleItemTotal := order.TotalAmountVND / float64(order.Quantity)
-	basePriceVND := singleItemTotal - order.ServiceFee
-	
-	itemsHTML := fmt.Sprintf(`
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse; width: 100%;">
-            <tr style="background-color: #f2f2f2;">
-                <th>Sản phẩm</th>
-                <th>Giá gốc (JPY)</th>
-                <th>Số lượng</th>
-                <th>Tổng (VND)</th>
-            </tr>
-             <tr>
-                <td>%s</td>
-                <td>%s</td>
-                <td>%d</td>
-                <td>%s</td>
-            </tr>
-        </table>
-    `, 
-    order.ProductName, 
-    order.ProductName, 
-    formatJPY(order.ProductPriceJPY), 
-    order.Quantity, 
-    formatVND(order.TotalAmountVND))
+func (e *Emailer) SendProxyStatusUpdateEmail(ctx context.Context, order models.ProxyOrder) error {
+	data := proxyStatusEmailData{Order: order, StatusLabel: proxyStatusLabel(order.Status)}
+	return e.sendEmail(ctx, order.CustomerEmail, TemplateProxyStatusUpdate, data)
+}
 
-	body := fmt.Sprintf(`
-        <h1>Cảm ơn bạn đã đặt hàng hộ tại TUNI TOKU!</h1>
-        <p>Chào <b>%s</b>,</p>
-        <p>Đơn hàng đặt hộ của bạn đã được tiếp nhận thành công. Chúng tôi sẽ sớm liên hệ với bạn để xác nhận và tiến hành giao hàng.</p>
-        <h2>Chi tiết đơn hàng:</h2>
-        %s
-        <br>
-        <table border="1" cellpadding="10" cellspacing="0" style="border-collapse: collapse; width: 100%;">
-            <tr><td style="background-color: #f2f2f2;"><strong>Giá quy đổi (1 sp)</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Phí dịch vụ (1 sp)</strong></td><td>%s</td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Thành tiền</strong></td><td><strong>%s</strong></td></tr>
-            <tr><td style="background-color: #f2f2f2;"><strong>Phí ship (dự kiến)</strong></td><td>%s (Sẽ báo sau khi hàng về kho)</td></tr>
-            <tr><td colspan="2" style="background-color: #f2f2f2; text-align: center;"><strong>Thông tin nhận hàng</strong></td></tr>
-            <tr><td><strong>Họ và tên</strong></td><td>%s</td></tr>
-            <tr><td><strong>Số điện thoại</strong></td><td>%s</td></tr>
-            <tr><td><strong>Email</strong></td><td>%s</td></tr>
-            <tr><td><strong>Địa chỉ</strong></td><td>%s</td></tr>
-            <tr><td><strong>Phương thức thanh toán</strong></td><td>%s</td></tr>
-        </table>
-        <>Mã QR code chuyển khoản:</    <p>Quét mã QR bên dưới để thanh toán (Tổng tiền đơn hàng). Phí ship sẽ được thanh toán khi hàng về.</p>
-        <img src="%s" style="width: 250px; height: 250px;" alt="QR Code">
-        <p>Cảm ơn bạn đã tin tưởng và mua sắm tại TUNI TOKU!</p>
-    `,
-		order.CustomerName,              
-		itemsHTML,                        
-		formatVND(basePriceVND),         
-		formatVND(basePriceVND),         
-		formatVND(order.ServiceFee),     
-		formatVND(order.TotalAmountVND), 
-		proxyShippingNote,
-		order.CustomerName,              
-		order.CustomerPhone,             
-		order.CustomerEmail,             
-		order.CustomerAddress,            
-		order.PaymentMethod,
-		qrImageUrl)
+// FrontendURL is where emailed links point, e.g. https://tunitoku.store/verify-email?token=...
+func (e *Emailer) FrontendURL() string {
+	return e.cfg.FrontendURL
+}
 
-	subject := fmt.Sprintf("Xác nhận đơn hàng đặt hộ Mercari #%d từ TUNI TOKU", order.ID)
+func (e *Emailer) SendVerificationEmail(ctx context.Context, user models.User, token string) error {
+	link := fmt.Sprintf("%s/verify-email?token=%s", e.FrontendURL(), token)
+	return e.sendEmail(ctx, user.Email, TemplateVerifyEmail, accountEmailData{User: user, Link: link})
+}
 
-	return sendEmail(customerEmail, subject, body)
-}
\ No newline at end of file
+func (e *Emailer) SendPasswordResetEmail(ctx context.Context, user models.User, token string) error {
+	link := fmt.Sprintf("%s/reset-password?token=%s", e.FrontendURL(), token)
+	return e.sendEmail(ctx, user.Email, TemplatePasswordReset, accountEmailData{User: user, Link: link})
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+)
+
+// Mỗi email gồm <tên>.html.tmpl và <tên>.txt.tmpl; file txt định nghĩa thêm "subject".
+//
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+const (
+	TemplateOrderAdminNotification      = "order_admin_notification"
+	TemplateOrderInvoice                = "order_invoice"
+	TemplateFeedback                    = "feedback"
+	TemplateProxyOrderAdminNotification = "proxy_order_admin_notification"
+	TemplateProxyOrderInvoice           = "proxy_order_invoice"
+	TemplateProxyQuote                  = "proxy_quote"
+	TemplateProxyStatusUpdate           = "proxy_status_update"
+	TemplateVerifyEmail                 = "verify_email"
+	TemplatePasswordReset               = "password_reset"
+)
+
+const invoiceQRImageURL = "https://pub-be6c7e6475cd42219bb9999d8fbb5743.r2.dev/products/image.png"
+
+var templateFuncs = map[string]any{
+	"vnd":     formatVND,
+	"jpy":     formatJPY,
+	"coupon":  formatCoupon,
+	"voucher": formatVoucher,
+	"lineTotal": func(item models.OrderItem) money.Money {
+		return item.Price.Mul(int64(item.Quantity))
+	},
+}
+
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// Parse một lần khi khởi động; template lỗi là lỗi build nên panic ngay.
+var emailTemplates = mustParseEmailTemplates()
+
+func mustParseEmailTemplates() map[string]emailTemplate {
+	names := []string{
+		TemplateOrderAdminNotification, TemplateOrderInvoice, TemplateFeedback,
+		TemplateProxyOrderAdminNotification, TemplateProxyOrderInvoice, TemplateProxyQuote,
+		TemplateProxyStatusUpdate, TemplateVerifyEmail, TemplatePasswordReset,
+	}
+
+	parsed := make(map[string]emailTemplate, len(names))
+	for _, name := range names {
+		html := htmltemplate.Must(htmltemplate.New(name).Funcs(templateFuncs).ParseFS(templateFS,
+			"templates/partials.html.tmpl", "templates/"+name+".html.tmpl"))
+		text := texttemplate.Must(texttemplate.New(name).Funcs(templateFuncs).ParseFS(templateFS,
+			"templates/partials.txt.tmpl", "templates/"+name+".txt.tmpl"))
+		if text.Lookup("subject") == nil {
+			panic(fmt.Sprintf("email template %s.txt.tmpl does not define a subject", name))
+		}
+		parsed[name] = emailTemplate{html: html, text: text}
+	}
+	return parsed
+}
+
+type RenderedEmail struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// EmailTemplateNames lists the templates in alphabetical order.
+func EmailTemplateNames() []string {
+	names := make([]string, 0, len(emailTemplates))
+	for name := range emailTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func RenderEmail(name string, data any) (RenderedEmail, error) {
+	tmpl, ok := emailTemplates[name]
+	if !ok {
+		return RenderedEmail{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, html, text bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("render %s subject: %w", name, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, name+".html.tmpl", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("render %s html: %w", name, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("render %s text: %w", name, err)
+	}
+
+	return RenderedEmail{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
+
+type customerInfo struct {
+	Title           string
+	CustomerName    string
+	CustomerPhone   string
+	CustomerEmail   string
+	CustomerAddress string
+	PaymentMethod   string
+}
+
+type orderEmailData struct {
+	Order       models.Order
+	ShippingFee money.Money
+	FinalTotal  money.Money
+	Customer    customerInfo
+	QRImageURL  string
+}
+
+type proxyOrderEmailData struct {
+	Order        models.ProxyOrder
+	BasePrice    money.Money
+	ShippingNote string
+	Customer     customerInfo
+	QRImageURL   string
+}
+
+type feedbackEmailData struct {
+	Feedback models.Feedback
+}
+
+type proxyQuoteEmailData struct {
+	Order        models.ProxyOrder
+	ShippingNote string
+}
+
+type proxyStatusEmailData struct {
+	Order       models.ProxyOrder
+	StatusLabel string
+}
+
+type accountEmailData struct {
+	User models.User
+	Link string
+}
+
+func newOrderEmailData(order models.Order, customerTitle string) orderEmailData {
+	return orderEmailData{
+		Order:       order,
+		ShippingFee: order.ShippingFee,
+		FinalTotal:  order.AmountDue,
+		Customer: customerInfo{
+			Title:           customerTitle,
+			CustomerName:    order.CustomerName,
+			CustomerPhone:   order.CustomerPhone,
+			CustomerEmail:   order.CustomerEmail,
+			CustomerAddress: order.CustomerAddress,
+			PaymentMethod:   order.PaymentMethod,
+		},
+		QRImageURL: invoiceQRImageURL,
+	}
+}
+
+func newProxyOrderEmailData(order models.ProxyOrder, customerTitle string) (proxyOrderEmailData, error) {
+	singleItemTotal := order.TotalAmountVND.Div(int64(order.Quantity))
+	basePrice, err := singleItemTotal.Sub(order.ServiceFee)
+	if err != nil {
+		return proxyOrderEmailData{}, err
+	}
+	return proxyOrderEmailData{
+		Order:        order,
+		BasePrice:    basePrice,
+		ShippingNote: proxyShippingNote,
+		Customer: customerInfo{
+			Title:           customerTitle,
+			CustomerName:    order.CustomerName,
+			CustomerPhone:   order.CustomerPhone,
+			CustomerEmail:   order.CustomerEmail,
+			CustomerAddress: order.CustomerAddress,
+			PaymentMethod:   order.PaymentMethod,
+		},
+		QRImageURL: invoiceQRImageURL,
+	}, nil
+}
+
+func proxyStatusLabel(status string) string {
+	if label, ok := proxyStatusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+// SampleEmailData returns fixed example data for the admin preview, so a
+// template can be checked without touching real orders.
+func (e *Emailer) SampleEmailData(name string) (any, error) {
+	model := gorm.Model{ID: 1024, CreatedAt: time.Now()}
+	user := models.User{Model: model, Username: "nguyenvana", Email: "khachhang@example.com"}
+
+	order := models.Order{
+		Model:           model,
+		User:            user,
+		TotalAmount:     money.FromVND(430000),
+		ShippingFee:     money.FromVND(50000),
+		AmountDue:       money.FromVND(480000),
+		OriginalAmount:  money.FromVND(500000),
+		DiscountApplied: money.FromVND(20000),
+		CouponCode:      "TUNI10",
+		CouponDiscount:  money.FromVND(50000),
+		CustomerName:    "Nguyễn Văn A",
+		CustomerPhone:   "0901234567",
+		CustomerAddress: "12 Lê Lợi, Quận 1, TP.HCM",
+		CustomerEmail:   "khachhang@example.com",
+		PaymentMethod:   "bank_transfer",
+		OrderItems: []models.OrderItem{
+			{Product: models.Product{Name: "Mô hình Gundam RX-78-2"}, Quantity: 1, Price: money.FromVND(350000)},
+			{Product: models.Product{Name: "Móc khóa Pikachu"}, Quantity: 2, Price: money.FromVND(75000)},
+		},
+	}
+
+	proxy := models.ProxyOrder{
+		Model:                    model,
+		User:                     user,
+		MercariURL:               "https://jp.mercari.com/item/m12345678901",
+		ProductName:              "ポケモンカード 151 BOX",
+		ProductPriceJPY:          money.FromJPY(8000),
+		CustomerName:             "Nguyễn Văn A",
+		CustomerPhone:            "0901234567",
+		CustomerAddress:          "12 Lê Lợi, Quận 1, TP.HCM",
+		CustomerEmail:            "khachhang@example.com",
+		ExchangeRate:             175,
+		ServiceFee:               money.FromVND(70000),
+		TotalAmountVND:           money.FromVND(1470000),
+		Status:                   models.ProxyStatusQuoted,
+		Quantity:                 1,
+		PaymentMethod:            "bank_transfer",
+		ActualPriceJPY:           money.FromJPY(7800),
+		DomesticShippingJPY:      money.FromJPY(750),
+		WeightKg:                 0.8,
+		InternationalShippingVND: money.FromVND(156000),
+		QuotedTotalVND:           money.FromVND(1722250),
+		AdminNote:                "Hàng còn nguyên seal.",
+	}
+
+	link := e.FrontendURL() + "/verify-email?token=sample-token"
+
+	switch name {
+	case TemplateOrderAdminNotification:
+		return newOrderEmailData(order, "Thông tin khách hàng"), nil
+	case TemplateOrderInvoice:
+		return newOrderEmailData(order, "Thông tin nhận hàng"), nil
+	case TemplateFeedback:
+		return feedbackEmailData{Feedback: models.Feedback{
+			Name: "Nguyễn Văn A", Email: "khachhang@example.com", Content: "Shop nên thêm nhiều mẫu Gundam hơn.",
+		}}, nil
+	case TemplateProxyOrderAdminNotification:
+		return newProxyOrderEmailData(proxy, "Thông tin khách hàng")
+	case TemplateProxyOrderInvoice:
+		return newProxyOrderEmailData(proxy, "Thông tin nhận hàng")
+	case TemplateProxyQuote:
+		return proxyQuoteEmailData{Order: proxy, ShippingNote: proxyShippingNote}, nil
+	case TemplateProxyStatusUpdate:
+		return proxyStatusEmailData{Order: proxy, StatusLabel: proxyStatusLabel(proxy.Status)}, nil
+	case TemplateVerifyEmail:
+		return accountEmailData{User: user, Link: link}, nil
+	case TemplatePasswordReset:
+		return accountEmailData{User: user, Link: e.FrontendURL() + "/reset-password?token=sample-token"}, nil
+	default:
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+}
@@ -1,55 +1,93 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
-	"math/rand"
-	"time"
 
 	"github.com/kaelCoding/toyBE/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func SpinWheel(db *gorm.DB) (*models.Reward, error) {
+var ErrNoRewardsAvailable = errors.New("no rewards available to spin")
+
+// SpinResult keeps what is needed to replay a spin when a customer disputes it:
+// the random seed, the roll derived from it and the weight total it was scaled by.
+type SpinResult struct {
+	Reward      models.Reward
+	Seed        string
+	Roll        float64
+	TotalWeight float64
+}
+
+// rollFromSeed maps 8 random bytes to a uniform float in [0, 1).
+func rollFromSeed(seed []byte) float64 {
+	return float64(binary.BigEndian.Uint64(seed)>>11) / (1 << 53)
+}
+
+func rewardAvailable(r models.Reward) bool {
+	return (r.Quantity > 0 || r.Quantity == -1) && r.Probability > 0
+}
+
+// SpinWheel draws from the campaign's pool, or the default pool when
+// campaignID is nil. It must run inside a transaction. Reward rows are locked in ID order
+// and the limited prize is decremented conditionally, so concurrent spins can
+// never hand out more of a reward than is in stock.
+func SpinWheel(tx *gorm.DB, campaignID *uint) (*SpinResult, error) {
 	var allRewards []models.Reward
-	if err := db.Find(&allRewards).Error; err != nil {
+	if err := rewardPool(tx, campaignID).Clauses(clause.Locking{Strength: "UPDATE"}).Order("id asc").Find(&allRewards).Error; err != nil {
 		return nil, err
 	}
 
 	var availableRewards []models.Reward
 	var totalProbability float64
 	for _, r := range allRewards {
-		if r.Quantity > 0 || r.Quantity == -1 {
+		if rewardAvailable(r) {
 			availableRewards = append(availableRewards, r)
 			totalProbability += r.Probability
 		}
 	}
 
 	if len(availableRewards) == 0 {
-		return nil, errors.New("no rewards available to spin")
+		return nil, ErrNoRewardsAvailable
 	}
 
-	if totalProbability == 0 {
-		return nil, errors.New("total probability of available rewards is zero")
+	seed := make([]byte, 8)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
 	}
+	roll := rollFromSeed(seed)
 
-	source := rand.NewSource(time.Now().UnixNano())
-    rng := rand.New(source)
-	
-	random := rng.Float64() * totalProbability
+	random := roll * totalProbability
+	chosen := availableRewards[len(availableRewards)-1]
 	cumulativeProbability := 0.0
-
 	for _, reward := range availableRewards {
 		cumulativeProbability += reward.Probability
 		if random < cumulativeProbability {
-			if reward.Quantity != -1 {
-				reward.Quantity--
-				if err := db.Save(&reward).Error; err != nil {
-					return nil, err
-				}
-			}
-			return &reward, nil
+			chosen = reward
+			break
+		}
+	}
+
+	if chosen.Quantity != -1 {
+		result := tx.Model(&models.Reward{}).
+			Where("id = ? AND quantity > 0", chosen.ID).
+			Update("quantity", gorm.Expr("quantity - 1"))
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, ErrNoRewardsAvailable
 		}
+		chosen.Quantity--
 	}
 
-	return nil, errors.New("failed to select a reward, please check probabilities")
-}
\ No newline at end of file
+	return &SpinResult{
+		Reward:      chosen,
+		Seed:        hex.EncodeToString(seed),
+		Roll:        roll,
+		TotalWeight: totalProbability,
+	}, nil
+}
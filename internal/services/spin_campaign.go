@@ -0,0 +1,107 @@
+package services
+
+import (
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func campaignCoversOrder(campaign models.SpinCampaign, order models.Order) bool {
+	// Ngưỡng khác loại tiền với đơn thì không so được, coi như không đủ điều kiện.
+	if cmp, err := order.TotalAmount.Cmp(campaign.MinOrderValue); err != nil || cmp < 0 {
+		return false
+	}
+	if len(campaign.Categories) == 0 {
+		return true
+	}
+	for _, item := range order.OrderItems {
+		for _, productCategory := range item.Product.Categories {
+			for _, campaignCategory := range campaign.Categories {
+				if productCategory.ID == campaignCategory.ID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// SelectCampaign picks the highest-priority running campaign the order and its
+// owner qualify for, or nil for the default pool. The order must have
+// OrderItems.Product.Categories loaded. Matching campaigns are locked so the
+// per-user spin limit holds under concurrent spins.
+func SelectCampaign(tx *gorm.DB, order models.Order) (*models.SpinCampaign, error) {
+	now := time.Now()
+	var campaigns []models.SpinCampaign
+	err := tx.Preload("Categories").
+		Where("active = ?", true).
+		Where("starts_at IS NULL OR starts_at <= ?", now).
+		Where("ends_at IS NULL OR ends_at >= ?", now).
+		Order("priority desc, id asc").
+		Find(&campaigns).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, campaign := range campaigns {
+		if !campaignCoversOrder(campaign, order) {
+			continue
+		}
+		if campaign.MaxSpinsPerUser > 0 {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.SpinCampaign{}, campaign.ID).Error; err != nil {
+				return nil, err
+			}
+			var spins int64
+			if err := tx.Model(&models.SpinLog{}).Where("campaign_id = ? AND user_id = ?", campaign.ID, order.UserID).Count(&spins).Error; err != nil {
+				return nil, err
+			}
+			if spins >= int64(campaign.MaxSpinsPerUser) {
+				continue
+			}
+		}
+		selected := campaign
+		return &selected, nil
+	}
+	return nil, nil
+}
+
+func rewardPool(db *gorm.DB, campaignID *uint) *gorm.DB {
+	if campaignID == nil {
+		return db.Where("campaign_id IS NULL")
+	}
+	return db.Where("campaign_id = ?", *campaignID)
+}
+
+// PreviewOdds returns the distribution SpinWheel would draw from right now for
+// the given pool, with out-of-stock rewards shown at zero.
+func PreviewOdds(db *gorm.DB, campaignID *uint) ([]models.RewardOdds, error) {
+	var rewards []models.Reward
+	if err := rewardPool(db, campaignID).Order("id asc").Find(&rewards).Error; err != nil {
+		return nil, err
+	}
+
+	var total float64
+	for _, r := range rewards {
+		if rewardAvailable(r) {
+			total += r.Probability
+		}
+	}
+
+	odds := make([]models.RewardOdds, 0, len(rewards))
+	for _, r := range rewards {
+		entry := models.RewardOdds{
+			RewardID:  r.ID,
+			Name:      r.Name,
+			Quantity:  r.Quantity,
+			Weight:    r.Probability,
+			Available: rewardAvailable(r),
+		}
+		if entry.Available && total > 0 {
+			entry.EffectiveProbability = r.Probability / total
+		}
+		odds = append(odds, entry)
+	}
+	return odds, nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+)
+
+func TestRollFromSeedIsInUnitInterval(t *testing.T) {
+	cases := [][]byte{
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{0x80, 0, 0, 0, 0, 0, 0, 0},
+	}
+	for _, seed := range cases {
+		roll := rollFromSeed(seed)
+		if roll < 0 || roll >= 1 {
+			t.Errorf("rollFromSeed(%x) = %v, want [0, 1)", seed, roll)
+		}
+	}
+	if got := rollFromSeed([]byte{0x80, 0, 0, 0, 0, 0, 0, 0}); got != 0.5 {
+		t.Errorf("midpoint seed rolled %v, want 0.5", got)
+	}
+}
+
+func TestRewardAvailable(t *testing.T) {
+	cases := []struct {
+		reward models.Reward
+		want   bool
+	}{
+		{models.Reward{Quantity: 3, Probability: 1}, true},
+		{models.Reward{Quantity: -1, Probability: 1}, true},
+		{models.Reward{Quantity: 0, Probability: 1}, false},
+		{models.Reward{Quantity: 3, Probability: 0}, false},
+	}
+	for _, tc := range cases {
+		if got := rewardAvailable(tc.reward); got != tc.want {
+			t.Errorf("rewardAvailable(qty=%d, p=%v) = %v, want %v", tc.reward.Quantity, tc.reward.Probability, got, tc.want)
+		}
+	}
+}
@@ -25,6 +25,15 @@ type HashParams struct {
   KeyLength   uint32
 }
 
+// DefaultHashParams is what new password hashes are created with.
+var DefaultHashParams = &HashParams{
+  Memory:      64 * 1024,
+  Iterations:  4,
+  Parallelism: 2,
+  SaltLength:  16,
+  KeyLength:   32,
+}
+
 func GenerateFromPassword(password string, params *HashParams) (string, error) {
   if password == "" {
     return "", ErrEmptyPassword
@@ -43,6 +52,17 @@ func GenerateFromPassword(password string, params *HashParams) (string, error) {
   return encodedHash, nil
 }
 
+// NeedsRehash reports whether encodedHash was made with parameters other
+// than params. Unparseable hashes also need replacing.
+func NeedsRehash(encodedHash string, params *HashParams) bool {
+  p, _, _, err := decodeHash(encodedHash)
+  if err != nil {
+    return true
+  }
+  return p.Memory != params.Memory || p.Iterations != params.Iterations || p.Parallelism != params.Parallelism ||
+    p.SaltLength != params.SaltLength || p.KeyLength != params.KeyLength
+}
+
 func generateRandomBytes(n uint32) ([]byte, error) {
   b := make([]byte, n)
   _, err := rand.Read(b)
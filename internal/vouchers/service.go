@@ -0,0 +1,194 @@
+package vouchers
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFound  = errors.New("voucher not found")
+	ErrNotUsable = errors.New("voucher has already been used or has expired")
+)
+
+// Unambiguous alphabet: no 0/O or 1/I for codes customers may type by hand.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func generateCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return "SPIN-" + string(b), nil
+}
+
+// Issue turns a spin win into a voucher owned by the spinning user.
+func Issue(tx *gorm.DB, spinLog models.SpinLog, reward models.Reward) (*models.UserVoucher, error) {
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+
+	validDays := reward.ValidDays
+	if validDays <= 0 {
+		validDays = 30
+	}
+	voucherType := reward.VoucherType
+	if voucherType == "" {
+		voucherType = models.VoucherTypeGift
+	}
+
+	voucher := models.UserVoucher{
+		UserID:        spinLog.UserID,
+		RewardID:      reward.ID,
+		SpinLogID:     spinLog.ID,
+		SourceOrderID: spinLog.OrderID,
+		Code:          code,
+		Type:          voucherType,
+		PercentOff:    reward.PercentOff,
+		AmountOff:     reward.AmountOff,
+		MaxDiscount:   reward.MaxDiscount,
+		Status:        models.VoucherStatusActive,
+		ExpiresAt:     time.Now().AddDate(0, 0, validDays),
+	}
+	if err := tx.Create(&voucher).Error; err != nil {
+		return nil, err
+	}
+	voucher.Reward = reward
+	return &voucher, nil
+}
+
+// ExpireStale flips active vouchers past their expiry to expired. It runs
+// from cron; reads use FilterStatus and ShowExpiry so they never wait on it.
+func ExpireStale(db *gorm.DB) error {
+	return db.Model(&models.UserVoucher{}).
+		Where("status = ? AND expires_at < ?", models.VoucherStatusActive, time.Now()).
+		Update("status", models.VoucherStatusExpired).Error
+}
+
+// FilterStatus restricts a user_vouchers query to statuses as customers see
+// them: an active voucher past its expiry already counts as expired.
+func FilterStatus(statuses []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(statuses) == 0 {
+			return db
+		}
+		now := time.Now()
+		var conds []string
+		var args []interface{}
+		for _, status := range statuses {
+			switch status {
+			case models.VoucherStatusActive:
+				conds = append(conds, "(status = ? AND expires_at > ?)")
+				args = append(args, models.VoucherStatusActive, now)
+			case models.VoucherStatusExpired:
+				conds = append(conds, "(status = ? OR (status = ? AND expires_at <= ?))")
+				args = append(args, models.VoucherStatusExpired, models.VoucherStatusActive, now)
+			default:
+				conds = append(conds, "status = ?")
+				args = append(args, status)
+			}
+		}
+		return db.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+}
+
+// ShowExpiry reports active vouchers that are past their expiry as expired.
+func ShowExpiry(list []models.UserVoucher) {
+	now := time.Now()
+	for i := range list {
+		if list[i].Status == models.VoucherStatusActive && !now.Before(list[i].ExpiresAt) {
+			list[i].Status = models.VoucherStatusExpired
+		}
+	}
+}
+
+// FindUsable loads an active, unexpired voucher belonging to userID.
+func FindUsable(db *gorm.DB, userID uint, code string) (*models.UserVoucher, error) {
+	var voucher models.UserVoucher
+	err := db.Preload("Reward").Where("code = ? AND user_id = ?", strings.ToUpper(strings.TrimSpace(code)), userID).First(&voucher).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if voucher.Status != models.VoucherStatusActive || time.Now().After(voucher.ExpiresAt) {
+		return nil, ErrNotUsable
+	}
+	return &voucher, nil
+}
+
+// Discount is the voucher's value against what is left to pay after VIP and
+// coupon discounts. Gift vouchers carry no discount; the gift ships with the order.
+func Discount(voucher *models.UserVoucher, remaining money.Money) (money.Money, error) {
+	var discount money.Money
+	var err error
+	switch voucher.Type {
+	case models.VoucherTypePercentage:
+		discount = remaining.Percent(voucher.PercentOff)
+		if !voucher.MaxDiscount.IsZero() {
+			if discount, err = money.Min(discount, voucher.MaxDiscount); err != nil {
+				return money.Money{}, err
+			}
+		}
+	case models.VoucherTypeFixed:
+		discount = voucher.AmountOff
+	default:
+		return money.FromVND(0), nil
+	}
+	if discount, err = money.Min(discount, remaining); err != nil {
+		return money.Money{}, err
+	}
+	return money.FromVND(discount.Amount), nil
+}
+
+// Redeem marks the voucher used by orderID inside the checkout transaction.
+func Redeem(tx *gorm.DB, voucherID, userID, orderID uint) error {
+	var voucher models.UserVoucher
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND user_id = ?", voucherID, userID).First(&voucher).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if voucher.Status != models.VoucherStatusActive || time.Now().After(voucher.ExpiresAt) {
+		return ErrNotUsable
+	}
+
+	now := time.Now()
+	return tx.Model(&voucher).Updates(map[string]interface{}{
+		"status":            models.VoucherStatusRedeemed,
+		"redeemed_order_id": orderID,
+		"redeemed_at":       now,
+	}).Error
+}
+
+// RevokeFromOrder takes back the unused vouchers won with a refunded order's spin.
+func RevokeFromOrder(tx *gorm.DB, orderID uint) error {
+	return tx.Model(&models.UserVoucher{}).
+		Where("source_order_id = ? AND status = ?", orderID, models.VoucherStatusActive).
+		Update("status", models.VoucherStatusRevoked).Error
+}
+
+// Release hands back the voucher a cancelled order used. It keeps its
+// original expiry, so a voucher that ran out in the meantime stays unusable.
+func Release(tx *gorm.DB, orderID uint) error {
+	return tx.Model(&models.UserVoucher{}).
+		Where("redeemed_order_id = ? AND status = ?", orderID, models.VoucherStatusRedeemed).
+		Updates(map[string]interface{}{
+			"status":            models.VoucherStatusActive,
+			"redeemed_order_id": nil,
+			"redeemed_at":       nil,
+		}).Error
+}
@@ -0,0 +1,90 @@
+package vouchers_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/money"
+	"github.com/kaelCoding/toyBE/internal/testdb"
+	"github.com/kaelCoding/toyBE/internal/vouchers"
+)
+
+func TestDiscount(t *testing.T) {
+	percent := &models.UserVoucher{Type: models.VoucherTypePercentage, PercentOff: 0.2, MaxDiscount: money.FromVND(30000)}
+	if got, _ := vouchers.Discount(percent, money.FromVND(100000)); got != money.FromVND(20000) {
+		t.Errorf("percentage: got %v", got)
+	}
+	if got, _ := vouchers.Discount(percent, money.FromVND(500000)); got != money.FromVND(30000) {
+		t.Errorf("capped percentage: got %v", got)
+	}
+
+	fixed := &models.UserVoucher{Type: models.VoucherTypeFixed, AmountOff: money.FromVND(80000)}
+	if got, _ := vouchers.Discount(fixed, money.FromVND(50000)); got != money.FromVND(50000) {
+		t.Errorf("fixed above remaining: got %v", got)
+	}
+	if got, _ := vouchers.Discount(&models.UserVoucher{Type: models.VoucherTypeGift}, money.FromVND(50000)); !got.IsZero() {
+		t.Errorf("gift: got %v", got)
+	}
+}
+
+func TestShowExpiry(t *testing.T) {
+	list := []models.UserVoucher{
+		{Status: models.VoucherStatusActive, ExpiresAt: time.Now().Add(-time.Minute)},
+		{Status: models.VoucherStatusActive, ExpiresAt: time.Now().Add(time.Hour)},
+		{Status: models.VoucherStatusRedeemed, ExpiresAt: time.Now().Add(-time.Minute)},
+	}
+	vouchers.ShowExpiry(list)
+	want := []string{models.VoucherStatusExpired, models.VoucherStatusActive, models.VoucherStatusRedeemed}
+	for i, v := range list {
+		if v.Status != want[i] {
+			t.Errorf("voucher %d: %s, want %s", i, v.Status, want[i])
+		}
+	}
+}
+
+func TestExpiryWithoutSweep(t *testing.T) {
+	db := testdb.Open(t)
+	user := models.User{Username: "holder", Email: "holder@example.com", Password: "x"}
+	reward := models.Reward{Name: "Gift", Quantity: -1, Probability: 1}
+	db.Create(&user)
+	db.Create(&reward)
+
+	stale := models.UserVoucher{UserID: user.ID, RewardID: reward.ID, Code: "SPIN-STALE", Type: models.VoucherTypeGift,
+		Status: models.VoucherStatusActive, ExpiresAt: time.Now().Add(-time.Hour)}
+	fresh := models.UserVoucher{UserID: user.ID, RewardID: reward.ID, Code: "SPIN-FRESH", Type: models.VoucherTypeGift,
+		Status: models.VoucherStatusActive, ExpiresAt: time.Now().Add(time.Hour)}
+	for _, v := range []*models.UserVoucher{&stale, &fresh} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	codes := func(status string) []string {
+		var got []string
+		db.Model(&models.UserVoucher{}).Scopes(vouchers.FilterStatus([]string{status})).Order("code").Pluck("code", &got)
+		return got
+	}
+	if got := codes(models.VoucherStatusActive); len(got) != 1 || got[0] != "SPIN-FRESH" {
+		t.Errorf("active: %v", got)
+	}
+	if got := codes(models.VoucherStatusExpired); len(got) != 1 || got[0] != "SPIN-STALE" {
+		t.Errorf("expired: %v", got)
+	}
+
+	if err := vouchers.Redeem(db, stale.ID, user.ID, 1); !errors.Is(err, vouchers.ErrNotUsable) {
+		t.Errorf("redeem stale: got %v, want ErrNotUsable", err)
+	}
+	if err := vouchers.Redeem(db, fresh.ID, user.ID+1, 1); !errors.Is(err, vouchers.ErrNotFound) {
+		t.Errorf("redeem someone else's voucher: got %v, want ErrNotFound", err)
+	}
+
+	if err := vouchers.ExpireStale(db); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&stale, stale.ID)
+	if stale.Status != models.VoucherStatusExpired {
+		t.Errorf("sweep left status %s", stale.Status)
+	}
+}
@@ -1,57 +1,181 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/kaelCoding/toyBE/internal/auth"
+	"github.com/kaelCoding/toyBE/internal/config"
 	"github.com/kaelCoding/toyBE/internal/database"
-	"github.com/kaelCoding/toyBE/internal/models"
+	"github.com/kaelCoding/toyBE/internal/jobs"
+	"github.com/kaelCoding/toyBE/internal/mail"
+	"github.com/kaelCoding/toyBE/internal/migrations"
 	"github.com/kaelCoding/toyBE/internal/router"
 	"github.com/kaelCoding/toyBE/internal/pkg/r2"
 	"github.com/kaelCoding/toyBE/internal/chat"
 	"github.com/kaelCoding/toyBE/internal/loyalty"
+	"github.com/kaelCoding/toyBE/internal/payments"
+	"github.com/kaelCoding/toyBE/internal/ratelimit"
+	"github.com/kaelCoding/toyBE/internal/rates"
+	"github.com/kaelCoding/toyBE/internal/services"
+	"github.com/kaelCoding/toyBE/internal/vouchers"
     "github.com/robfig/cron/v3"
 )
 
+// shutdownTimeout bounds how long SIGTERM waits for requests, chat clients,
+// cron jobs and emails to finish.
+const shutdownTimeout = 30 * time.Second
+
+const (
+	jobWorkers         = 2
+	succeededJobMaxAge = 30 * 24 * time.Hour
+)
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Printf("Cảnh báo: Không tìm thấy file .env, sẽ sử dụng biến môi trường hệ thống. Lỗi: %v\n", err)
 	}
 
-	r2.Init()
-	database.ConnectDB()
+	// CONFIG_FILE trỏ tới file JSON tùy chọn; biến môi trường luôn được ưu tiên hơn.
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	mailer, err := mail.FromConfig(cfg.Email)
+	if err != nil {
+		log.Fatalf("Invalid email configuration: %v", err)
+	}
+	emails := services.NewEmailer(cfg.Email, mailer)
+	database.ConnectDB(cfg.Database.URL)
 	db := database.GetDB()
 
-	fmt.Println("Migrating database schemas...")
+	if len(os.Args) > 1 {
+		if err := runCommand(db, emails, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	err = database.DB.AutoMigrate(&models.User{}, &models.Product{}, &models.Category{}, &models.Message{}, &models.Order{}, &models.OrderItem{}, &models.Reward{}, &models.SpinLog{}, &models.ProxyOrder{}, &models.Cart{}, &models.CartItem{})
-	if err != nil {
-		log.Fatal("Error migrating schema:", err)
+	if err := cfg.R2.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
-	fmt.Println("Database migration successful.")
+	r2.Init(cfg.R2)
 
-	// database.CreateInitialAdmin(database.DB)
+	if cfg.Database.AutoMigrate {
+		ran, err := migrations.Up(db)
+		if err != nil {
+			log.Fatal("Error migrating schema: ", err)
+		}
+		fmt.Printf("Applied %d migration(s).\n", len(ran))
+	}
+	if err := migrations.CheckCurrent(db); err != nil {
+		log.Fatalf("Refusing to start: %v. Run `%s migrate up` first.", err, os.Args[0])
+	}
 
 	c := cron.New()
 	c.AddFunc("0 1 * * *", func() { loyalty.CheckAndApplyDemotions(db) })
-	c.Start()
 	log.Println("Cron job for VIP demotion checks scheduled.")
+	c.AddFunc("5 * * * *", func() {
+		if err := vouchers.ExpireStale(db); err != nil {
+			log.Printf("Failed to expire vouchers: %v", err)
+		}
+	})
+	c.AddFunc("30 3 * * *", func() {
+		if err := auth.PurgeExpired(db); err != nil {
+			log.Printf("Failed to purge expired tokens: %v", err)
+		}
+		if err := jobs.PurgeSucceeded(db, succeededJobMaxAge); err != nil {
+			log.Printf("Failed to purge finished jobs: %v", err)
+		}
+	})
+
+	rateProvider, err := rates.ProviderFromConfig(cfg.ExchangeRate)
+	if err != nil {
+		log.Fatalf("Invalid exchange rate provider configuration: %v", err)
+	}
+	if rateProvider != nil {
+		schedule := cfg.ExchangeRate.Cron
+		if _, err := c.AddFunc(schedule, func() { rates.RunScheduledRefresh(db, rateProvider) }); err != nil {
+			log.Fatalf("Invalid EXCHANGE_RATE_CRON schedule: %v", err)
+		}
+		log.Printf("Exchange rate refresh from %s provider scheduled (%s).", rateProvider.Name(), schedule)
+	}
+
+	c.Start()
+
+	services.RegisterEmailJobs(emails)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	worker := jobs.NewWorker(db, jobWorkers)
+	worker.Start(workerCtx)
 
-	hub := chat.NewHub()
+	hub := chat.NewHub(cfg.Server.CORSOrigins)
 	go hub.Run()
 
-	r := router.SetupRouter(hub)
+	limiter, err := ratelimit.StoreFromConfig(cfg.RateLimit)
+	if err != nil {
+		log.Fatalf("Invalid rate limit configuration: %v", err)
+	}
+	log.Printf("Rate limits are kept in the %s store.", cfg.RateLimit.Store)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	r := router.SetupRouter(cfg, auth.NewTokens(cfg.Auth), emails, hub, rateProvider, payments.ProvidersFromConfig(cfg.Payments, cfg.Server.Environment), limiter)
+
+	port := cfg.Server.Port
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
 	}
 
-	fmt.Printf("Server is running on http://localhost:%s\n", port)
-	if err := r.Run(":" + port); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Server is running on http://localhost:%s\n", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
 		log.Fatalf("Error starting server: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+	log.Println("Shutting down...")
+
+	// Tắt theo thứ tự: ngừng nhận request, đóng chat, chờ job queue, cron và email đang chạy.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server did not drain in time: %v", err)
+	}
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Chat hub did not close all clients in time: %v", err)
+	}
+	stopWorkers()
+	if err := worker.Wait(shutdownCtx); err != nil {
+		log.Printf("Job workers still running at shutdown: %v", err)
+	}
+	select {
+	case <-c.Stop().Done():
+	case <-shutdownCtx.Done():
+		log.Printf("Cron jobs still running at shutdown: %v", shutdownCtx.Err())
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
 	}
+	log.Println("Server stopped.")
 }